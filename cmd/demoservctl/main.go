@@ -0,0 +1,697 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"demoserv/internal/archive"
+	"demoserv/internal/config"
+	"demoserv/internal/encryption"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/orderfile"
+	"demoserv/internal/postgress"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `demoservctl - служебные команды demoserv
+
+Использование:
+  demoservctl encrypt [-batch N]                    зашифровать активным ключом все строки delivery
+  demoservctl rotate-key [-encrypt] [-batch N]      создать новый ключ данных и, с -encrypt, перешифровать им строки
+  demoservctl export-customer -customer ID [-o F]   выгрузить все заказы клиента в JSON (по умолчанию в stdout)
+  demoservctl anonymize-customer -customer ID -yes  обезличить данные доставки клиента и убрать его заказы из кэша сервиса
+  demoservctl cache-evict -orders UID,UID           убрать заказы из кэша сервиса
+  demoservctl rollup-backfill [-from D] [-to D] [-days N]
+                                                    пересчитать сводные таблицы продаж по дням [from, to), по N дней за транзакцию
+  demoservctl rollup-check [-from D] [-to D]        сверить сводные таблицы с сырыми, код 1 при расхождениях
+  demoservctl partition-prepare [-rollback]          создать секционированные копии таблиц заказов и триггеры синхронизации
+                                                    или, с -rollback, удалить их (только до подмены)
+  demoservctl partition-migrate [-batch N] [-swap] [-lock-timeout D]
+                                                    перенести заказы в секционированные таблицы и, с -swap, подменить ими старые
+  demoservctl archive [-days N] [-batch N]          выгрузить в архив и удалить из базы заказы старше N дней (по умолчанию из конфига)
+  demoservctl archive-list [-limit N]               последние файлы архива из манифеста
+  demoservctl archive-restore -manifest ID | -orders UID,UID
+                                                    вернуть в базу все заказы файла архива или заказы по order_uid
+  demoservctl import [-batch N] [-workers N] [-strict] FILE...
+                                                    загрузить заказы из JSON массива, NDJSON или .gz (- для stdin), отчет в stdout
+  demoservctl export [-from D] [-to D] [-customer ID] [-delivery-service S] [-limit N] [-format ndjson|csv] [-o F]
+                                                    выгрузить заказы в NDJSON или CSV (по умолчанию в stdout, .gz в -o - со сжатием)
+
+anonymize-customer и archive меняют заказы в обход сервиса и убирают их из кэша через POST /admin/cache/evict:
+адрес сервиса — DEMOSERV_URL (по умолчанию http://HTTP_SERVER.ADDRESS), ключ роли admin — DEMOSERV_API_KEY или JWT — DEMOSERV_TOKEN
+`
+
+// log сообщения команд в stderr, чтобы не смешивать с выгрузкой в stdout
+var log = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.New()
+	if err != nil {
+		fatal("config error", sl.Err(err))
+	}
+
+	switch os.Args[1] {
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		batch := fs.Int("batch", 500, "строк в одной транзакции")
+		fs.Parse(os.Args[2:])
+
+		pool, cipher := connect(ctx, cfg, true)
+		defer pool.Close()
+		encryptAll(ctx, pool, cipher, *batch)
+
+	case "rotate-key":
+		fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+		reencrypt := fs.Bool("encrypt", false, "перешифровать строки новым ключом")
+		batch := fs.Int("batch", 500, "строк в одной транзакции")
+		fs.Parse(os.Args[2:])
+
+		pool, cipher := connect(ctx, cfg, true)
+		defer pool.Close()
+		id, err := postgress.CreateDataKey(ctx, pool, cipher)
+		if err != nil {
+			fatal("rotate key", sl.Err(err))
+		}
+		log.Info("new data key is active", slog.String("data_key", id))
+		if *reencrypt {
+			encryptAll(ctx, pool, cipher, *batch)
+		}
+
+	case "export-customer":
+		fs := flag.NewFlagSet("export-customer", flag.ExitOnError)
+		customerID := fs.String("customer", "", "customer_id клиента")
+		out := fs.String("o", "", "файл для выгрузки")
+		fs.Parse(os.Args[2:])
+		if *customerID == "" {
+			fatal("-customer is required")
+		}
+
+		pool, cipher := connect(ctx, cfg, false)
+		defer pool.Close()
+		exportCustomer(ctx, pool, cipher, *customerID, *out)
+
+	case "anonymize-customer":
+		fs := flag.NewFlagSet("anonymize-customer", flag.ExitOnError)
+		customerID := fs.String("customer", "", "customer_id клиента")
+		yes := fs.Bool("yes", false, "подтвердить необратимое обезличивание")
+		fs.Parse(os.Args[2:])
+		if *customerID == "" {
+			fatal("-customer is required")
+		}
+		if !*yes {
+			fatal("anonymization cannot be undone, run with -yes to confirm")
+		}
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		uids, err := postgress.AnonymizeCustomer(ctx, pool, *customerID, actor())
+		if err != nil {
+			fatal("anonymize customer", sl.Err(err))
+		}
+		if len(uids) == 0 {
+			fatal("customer not found", slog.String("customer_id", *customerID))
+		}
+		log.Info("customer anonymized", slog.String("customer_id", *customerID), slog.Int("orders", len(uids)))
+		evictOrders(ctx, cfg, uids)
+
+	case "cache-evict":
+		fs := flag.NewFlagSet("cache-evict", flag.ExitOnError)
+		orders := fs.String("orders", "", "order_uid через запятую")
+		fs.Parse(os.Args[2:])
+		if *orders == "" {
+			fatal("-orders is required")
+		}
+		evictOrders(ctx, cfg, strings.Split(*orders, ","))
+
+	case "rollup-backfill":
+		fs := flag.NewFlagSet("rollup-backfill", flag.ExitOnError)
+		from := fs.String("from", "", "первый день YYYY-MM-DD, по умолчанию день первого заказа")
+		to := fs.String("to", "", "день после последнего YYYY-MM-DD, по умолчанию после последнего заказа")
+		days := fs.Int("days", 7, "дней в одной транзакции")
+		fs.Parse(os.Args[2:])
+		if *days <= 0 {
+			fatal("-days must be positive")
+		}
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		backfillRollups(ctx, pool, *from, *to, *days)
+
+	case "rollup-check":
+		fs := flag.NewFlagSet("rollup-check", flag.ExitOnError)
+		from := fs.String("from", "", "первый день YYYY-MM-DD, по умолчанию день первого заказа")
+		to := fs.String("to", "", "день после последнего YYYY-MM-DD, по умолчанию после последнего заказа")
+		fs.Parse(os.Args[2:])
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		checkRollups(ctx, pool, *from, *to)
+
+	case "partition-prepare":
+		fs := flag.NewFlagSet("partition-prepare", flag.ExitOnError)
+		rollback := fs.Bool("rollback", false, "удалить секционированные копии и триггеры")
+		fs.Parse(os.Args[2:])
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		preparePartitions(ctx, pool, *rollback)
+
+	case "partition-migrate":
+		fs := flag.NewFlagSet("partition-migrate", flag.ExitOnError)
+		batch := fs.Int("batch", 1000, "заказов в одной транзакции")
+		swap := fs.Bool("swap", false, "после переноса подменить старые таблицы секционированными")
+		lockTimeout := fs.Duration("lock-timeout", 5*time.Second, "сколько ждать блокировку таблиц при подмене")
+		fs.Parse(os.Args[2:])
+		if *batch <= 0 {
+			fatal("-batch must be positive")
+		}
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		migratePartitions(ctx, pool, *batch, *swap, *lockTimeout)
+
+	case "archive":
+		fs := flag.NewFlagSet("archive", flag.ExitOnError)
+		days := fs.Int("days", cfg.Archive.RetentionDays, "срок хранения заказов в базе, дней")
+		batch := fs.Int("batch", cfg.Archive.BatchSize, "заказов в одном файле")
+		fs.Parse(os.Args[2:])
+		if *days <= 0 || *batch <= 0 {
+			fatal("-days and -batch must be positive")
+		}
+		cfg.Archive.RetentionDays = *days
+		cfg.Archive.BatchSize = *batch
+
+		pool, cipher := connect(ctx, cfg, false)
+		defer pool.Close()
+		archiveOrders(ctx, pool, cipher, cfg)
+
+	case "archive-list":
+		fs := flag.NewFlagSet("archive-list", flag.ExitOnError)
+		limit := fs.Int("limit", 20, "сколько последних файлов показать")
+		fs.Parse(os.Args[2:])
+
+		pool, _ := connect(ctx, cfg, false)
+		defer pool.Close()
+		manifests, err := postgress.ListArchiveManifests(ctx, pool, *limit)
+		if err != nil {
+			fatal("list archive", sl.Err(err))
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, m := range manifests {
+			if err := enc.Encode(m); err != nil {
+				fatal("write archive list", sl.Err(err))
+			}
+		}
+
+	case "archive-restore":
+		fs := flag.NewFlagSet("archive-restore", flag.ExitOnError)
+		manifestID := fs.Int64("manifest", 0, "id файла архива из archive-list")
+		orders := fs.String("orders", "", "order_uid через запятую")
+		fs.Parse(os.Args[2:])
+		var uids []string
+		if *orders != "" {
+			uids = strings.Split(*orders, ",")
+		}
+		if (*manifestID > 0) == (len(uids) > 0) {
+			fatal("either -manifest or -orders is required")
+		}
+
+		pool, cipher := connect(ctx, cfg, false)
+		defer pool.Close()
+		restoreOrders(ctx, pool, cipher, cfg.Archive, *manifestID, uids)
+
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		batch := fs.Int("batch", 100, "заказов в одной транзакции")
+		workers := fs.Int("workers", 4, "параллельных транзакций")
+		strict := fs.Bool("strict", false, "неизвестные поля заказа считать ошибкой")
+		fs.Parse(os.Args[2:])
+		if fs.NArg() == 0 {
+			fatal("no files to import")
+		}
+		if *batch <= 0 || *workers <= 0 {
+			fatal("-batch and -workers must be positive")
+		}
+
+		pool, cipher := connect(ctx, cfg, false)
+		defer pool.Close()
+		importOrders(ctx, pool, cipher, fs.Args(), *batch, *workers, *strict)
+
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		from := fs.String("from", "", "заказы с этого дня YYYY-MM-DD")
+		to := fs.String("to", "", "заказы до этого дня YYYY-MM-DD, не включая его")
+		customerID := fs.String("customer", "", "customer_id клиента")
+		deliveryService := fs.String("delivery-service", "", "служба доставки")
+		limit := fs.Int("limit", 0, "сколько заказов выгрузить, 0 - все")
+		format := fs.String("format", orderfile.FormatNDJSON, "ndjson или csv")
+		out := fs.String("o", "", "файл для выгрузки")
+		fs.Parse(os.Args[2:])
+
+		filter := models.OrderFilter{CustomerID: *customerID, DeliveryService: *deliveryService, Limit: *limit}
+		var err error
+		if *from != "" {
+			if filter.From, err = time.Parse(time.DateOnly, *from); err != nil {
+				fatal("invalid -from", sl.Err(err))
+			}
+		}
+		if *to != "" {
+			if filter.To, err = time.Parse(time.DateOnly, *to); err != nil {
+				fatal("invalid -to", sl.Err(err))
+			}
+		}
+
+		pool, cipher := connect(ctx, cfg, false)
+		defer pool.Close()
+		exportOrders(ctx, pool, cipher, filter, *format, *out)
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// connect подключается к базе (с миграциями) и, если шифрование включено, загружает ключи данных.
+// requireEncryption - команда не имеет смысла без шифрования
+func connect(ctx context.Context, cfg *config.Config, requireEncryption bool) (*pgxpool.Pool, *encryption.Cipher) {
+	if requireEncryption && !cfg.Encryption.Enabled {
+		fatal("encryption is disabled in config")
+	}
+	pool, err := postgress.New(ctx, cfg.Postgres, log)
+	if err != nil {
+		fatal("unable to connect to database", sl.Err(err))
+	}
+	if !cfg.Encryption.Enabled {
+		return pool, nil
+	}
+
+	cipher, err := encryption.New(cfg.Encryption)
+	if err != nil {
+		fatal("encryption config error", sl.Err(err))
+	}
+	if err := postgress.LoadDataKeys(ctx, pool, cipher); err != nil {
+		fatal("unable to load data keys", sl.Err(err))
+	}
+	return pool, cipher
+}
+
+func encryptAll(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, batch int) {
+	n, err := postgress.EncryptDeliveries(ctx, pool, cipher, batch)
+	if err != nil {
+		fatal("encrypt failed", slog.Int("rows", n), sl.Err(err))
+	}
+	log.Info("rows encrypted", slog.Int("rows", n))
+}
+
+func exportCustomer(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, customerID, out string) {
+	orders, err := postgress.GetCustomerOrders(ctx, pool, cipher, customerID)
+	if err != nil {
+		fatal("export customer", sl.Err(err))
+	}
+	if len(orders) == 0 {
+		fatal("customer not found", slog.String("customer_id", customerID))
+	}
+	if err := postgress.LogCustomerExport(ctx, pool, customerID, actor(), orders); err != nil {
+		fatal("audit export", sl.Err(err))
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			fatal("unable to create export file", slog.String("file", out), sl.Err(err))
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(models.CustomerExport{
+		CustomerID: customerID,
+		ExportedAt: time.Now().UTC(),
+		Orders:     orders,
+	})
+	if err != nil {
+		fatal("write export", sl.Err(err))
+	}
+	log.Info("customer exported", slog.String("customer_id", customerID), slog.Int("orders", len(orders)))
+}
+
+func backfillRollups(ctx context.Context, pool *pgxpool.Pool, fromFlag, toFlag string, days int) {
+	from, to, ok := rollupPeriod(ctx, pool, fromFlag, toFlag)
+	if !ok {
+		log.Info("no orders, nothing to backfill")
+		return
+	}
+	// по частям, чтобы не держать блокировку сводных таблиц на весь период
+	step := time.Duration(days) * 24 * time.Hour
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(to) {
+			end = to
+		}
+		if err := postgress.RefreshRollups(ctx, pool, start, end); err != nil {
+			fatal("backfill rollups", slog.Time("from", start), sl.Err(err))
+		}
+		log.Info("rollups backfilled", slog.String("from", start.Format(time.DateOnly)), slog.String("to", end.Format(time.DateOnly)))
+	}
+}
+
+func checkRollups(ctx context.Context, pool *pgxpool.Pool, fromFlag, toFlag string) {
+	from, to, ok := rollupPeriod(ctx, pool, fromFlag, toFlag)
+	if !ok {
+		log.Info("no orders, nothing to check")
+		return
+	}
+	mismatches, err := postgress.CheckRollups(ctx, pool, from, to)
+	if err != nil {
+		fatal("check rollups", sl.Err(err))
+	}
+	for _, m := range mismatches {
+		log.Warn("rollup mismatch",
+			slog.String("table", m.Table), slog.String("day", m.Day.Format(time.DateOnly)),
+			slog.String("key", m.Key), slog.String("currency", m.Currency),
+			slog.Any("rollup", m.Rollup), slog.Any("raw", m.Raw))
+	}
+	if len(mismatches) > 0 {
+		fatal("rollups differ from raw tables, run rollup-backfill for these days", slog.Int("mismatches", len(mismatches)))
+	}
+	log.Info("rollups are consistent", slog.String("from", from.Format(time.DateOnly)), slog.String("to", to.Format(time.DateOnly)))
+}
+
+func preparePartitions(ctx context.Context, pool *pgxpool.Pool, rollback bool) {
+	if rollback {
+		err := postgress.RollbackPartitions(ctx, pool)
+		if errors.Is(err, postgress.ErrPartitionsSwapped) {
+			fatal("order tables are already partitioned, rollback is not possible")
+		}
+		if err != nil {
+			fatal("rollback order partitions", sl.Err(err))
+		}
+		log.Info("partitioned copies and sync triggers are removed")
+		return
+	}
+
+	err := postgress.PreparePartitions(ctx, pool)
+	switch {
+	case errors.Is(err, postgress.ErrPartitionsSwapped):
+		log.Info("order tables are already partitioned")
+	case errors.Is(err, postgress.ErrPartitionsPrepared):
+		log.Info("order partitions are already prepared, run partition-migrate")
+	case err != nil:
+		fatal("prepare order partitions", sl.Err(err))
+	default:
+		log.Info("order partitions are prepared, run partition-migrate and set PARTITIONS.ENABLED: true")
+	}
+}
+
+func migratePartitions(ctx context.Context, pool *pgxpool.Pool, batch int, swap bool, lockTimeout time.Duration) {
+	batches := 0
+	err := postgress.BackfillOrderPartitions(ctx, pool, batch, func(lastUID string) {
+		if batches++; batches%100 == 0 {
+			log.Info("orders copied", slog.Int("batches", batches), slog.String("last_order_uid", lastUID))
+		}
+	})
+	if errors.Is(err, postgress.ErrPartitionsSwapped) {
+		log.Info("order tables are already partitioned")
+		return
+	}
+	if errors.Is(err, postgress.ErrPartitionsNotPrepared) {
+		fatal("order partitions are not prepared, run partition-prepare first")
+	}
+	if err != nil {
+		fatal("copy orders", sl.Err(err))
+	}
+
+	status, err := postgress.GetPartitionMigration(ctx, pool)
+	if err != nil {
+		fatal("partition migration status", sl.Err(err))
+	}
+	for _, t := range status.Tables {
+		log.Info("table copied", slog.String("table", t.Table), slog.Int64("rows", t.Rows), slog.Int64("copied", t.Copied))
+	}
+	if !swap {
+		log.Info("orders copied, run with -swap to switch to the partitioned tables")
+		return
+	}
+
+	if err := postgress.SwapOrderPartitions(ctx, pool, lockTimeout); err != nil {
+		fatal("swap order tables", sl.Err(err))
+	}
+	log.Info("order tables are partitioned, old tables are kept as *_legacy")
+}
+
+func archiveOrders(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, cfg *config.Config) {
+	store, err := archive.NewStore(cfg.Archive)
+	if err != nil {
+		fatal("archive config error", sl.Err(err))
+	}
+	var archived []string
+	archiver := archive.New(cfg.Archive, func(ctx context.Context, cutoff time.Time, limit int, upload archive.UploadFunc) (int, error) {
+		uids, err := postgress.ArchiveOrders(ctx, pool, cipher, cutoff, limit, upload)
+		archived = append(archived, uids...)
+		return len(uids), err
+	}, store, log)
+	n, err := archiver.Archive(ctx)
+	// удаленные до ошибки заказы тоже убираются из кэша
+	evictOrders(ctx, cfg, archived)
+	if err != nil {
+		fatal("archive orders", slog.Int("archived", n), sl.Err(err))
+	}
+}
+
+// maxEvictOrders сколько заказов убирается из кэша одним запросом, как в обработчике
+const maxEvictOrders = 1000
+
+// evictOrders убирает заказы из кэша сервиса через POST /admin/cache/evict.
+// Заказы в базе к этому моменту уже изменены, поэтому при ошибке команда
+// завершается с подсказкой, как повторить
+func evictOrders(ctx context.Context, cfg *config.Config, uids []string) {
+	base := os.Getenv("DEMOSERV_URL")
+	if base == "" {
+		base = "http://" + cfg.HttpServer.Address
+	}
+	url := strings.TrimSuffix(base, "/") + "/admin/cache/evict"
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for start := 0; start < len(uids); start += maxEvictOrders {
+		chunk := uids[start:min(start+maxEvictOrders, len(uids))]
+		if err := evictChunk(ctx, client, url, chunk); err != nil {
+			fatal("orders changed in database, but service cache was not updated; retry with demoservctl cache-evict -orders "+
+				strings.Join(uids[start:], ","), slog.String("url", url), sl.Err(err))
+		}
+	}
+	if len(uids) > 0 {
+		log.Info("orders evicted from service cache", slog.Int("orders", len(uids)))
+	}
+}
+
+func evictChunk(ctx context.Context, client *http.Client, url string, uids []string) error {
+	body, err := json.Marshal(struct {
+		OrderUIDs []string `json:"order_uids"`
+	}{uids})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := os.Getenv("DEMOSERV_API_KEY"); key != "" {
+		req.Header.Set("X-API-Key", key)
+	} else if token := os.Getenv("DEMOSERV_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func restoreOrders(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, cfg models.ArchiveConfig, manifestID int64, uids []string) {
+	store, err := archive.NewStore(cfg)
+	if err != nil {
+		fatal("archive config error", sl.Err(err))
+	}
+	var manifests []models.ArchiveManifest
+	if manifestID > 0 {
+		m, err := postgress.GetArchiveManifest(ctx, pool, manifestID)
+		if err != nil {
+			fatal("archive manifest", sl.Err(err))
+		}
+		manifests = append(manifests, m)
+	} else if manifests, err = postgress.FindArchiveManifests(ctx, pool, uids); err != nil {
+		fatal("find archive manifests", sl.Err(err))
+	}
+
+	report, err := archive.Restore(ctx, store, manifests, uids,
+		func(ctx context.Context, order models.ArchivedOrder, manifestID int64) (bool, bool, error) {
+			return postgress.RestoreOrder(ctx, pool, cipher, order, manifestID, actor())
+		})
+	if err == nil {
+		err = postgress.UpdateArchiveStatus(ctx, pool, report.Manifests)
+	}
+	// отчет пишется и при ошибке: восстановленные до нее заказы уже в базе
+	if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+		fatal("write restore report", sl.Err(err))
+	}
+	if err != nil {
+		fatal("restore orders", slog.Int("restored", len(report.Restored)), sl.Err(err))
+	}
+	log.Info("orders restored", slog.Int("restored", len(report.Restored)),
+		slog.Int("skipped", len(report.Skipped)), slog.Int("not_found", len(report.NotFound)))
+}
+
+// fileReport отчет загрузки одного файла
+type fileReport struct {
+	File string `json:"file"`
+	models.ImportReport
+}
+
+func importOrders(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, files []string, batch, workers int, strict bool) {
+	insert := func(ctx context.Context, orders []models.Order) (int, error) {
+		return postgress.InsertOrders(ctx, pool, cipher, orders)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	failed := false
+	for _, file := range files {
+		start := time.Now()
+		report, err := importFile(ctx, file, batch, workers, strict, insert)
+		if encErr := enc.Encode(fileReport{File: file, ImportReport: report}); encErr != nil {
+			fatal("write import report", sl.Err(encErr))
+		}
+		if err != nil {
+			fatal("import orders", slog.String("file", file), sl.Err(err))
+		}
+		log.Info("orders imported", slog.String("file", file), slog.Int("inserted", report.Inserted),
+			slog.Int("duplicates", report.Duplicates), slog.Int("invalid", report.Invalid),
+			slog.Int("failed", report.Failed), slog.Duration("took", time.Since(start)))
+		failed = failed || report.Invalid > 0 || report.Failed > 0
+	}
+	if failed {
+		fatal("some orders were not imported, see errors in the report")
+	}
+}
+
+func importFile(ctx context.Context, file string, batch, workers int, strict bool, insert orderfile.InsertFunc) (models.ImportReport, error) {
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return models.ImportReport{}, err
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := orderfile.NewReader(in, strict)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	defer r.Close()
+	return orderfile.Import(ctx, r, batch, workers, insert)
+}
+
+func exportOrders(ctx context.Context, pool *pgxpool.Pool, cipher *encryption.Cipher, filter models.OrderFilter, format, out string) {
+	var dst io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			fatal("unable to create export file", slog.String("file", out), sl.Err(err))
+		}
+		defer f.Close()
+		dst = f
+	}
+	w, err := orderfile.NewWriter(dst, format, strings.HasSuffix(out, ".gz"))
+	if err != nil {
+		fatal("export orders", sl.Err(err))
+	}
+	n, err := postgress.ExportOrders(ctx, pool, cipher, filter, w.Write)
+	if err != nil {
+		fatal("export orders", slog.Int("exported", n), sl.Err(err))
+	}
+	if err := w.Close(); err != nil {
+		fatal("write export", sl.Err(err))
+	}
+	log.Info("orders exported", slog.Int("orders", n), slog.String("format", format))
+}
+
+// rollupPeriod период из флагов, пустые границы берутся по заказам в базе. ok=false если заказов нет
+func rollupPeriod(ctx context.Context, pool *pgxpool.Pool, fromFlag, toFlag string) (from, to time.Time, ok bool) {
+	from, to, ok, err := postgress.OrdersPeriod(ctx, pool)
+	if err != nil {
+		fatal("orders period", sl.Err(err))
+	}
+	if fromFlag != "" {
+		if from, err = time.Parse(time.DateOnly, fromFlag); err != nil {
+			fatal("invalid -from", sl.Err(err))
+		}
+	}
+	if toFlag != "" {
+		if to, err = time.Parse(time.DateOnly, toFlag); err != nil {
+			fatal("invalid -to", sl.Err(err))
+		}
+	}
+	if fromFlag != "" && toFlag != "" {
+		ok = true
+	}
+	if ok && !from.Before(to) {
+		fatal("-from must be before -to")
+	}
+	// заказы раньше границы архивации удалены из базы, но учтены в сводных таблицах,
+	// поэтому эти дни не пересчитываются и не сверяются
+	if ok {
+		cutoff, archived, err := postgress.ArchivedBefore(ctx, pool)
+		if err != nil {
+			fatal("archive cutoff", sl.Err(err))
+		}
+		if archived && from.Before(cutoff) {
+			log.Warn("skipping archived days", slog.String("from", from.Format(time.DateOnly)),
+				slog.String("archived_before", cutoff.Format(time.DateOnly)))
+			from = cutoff
+			ok = from.Before(to)
+		}
+	}
+	return from, to, ok
+}
+
+// fatal пишет ошибку и завершает команду
+func fatal(msg string, args ...any) {
+	log.Error(msg, args...)
+	os.Exit(1)
+}
+
+// actor имя оператора для privacy_audit
+func actor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
@@ -1,19 +1,27 @@
 package main
 
 import (
+	"demoserv/internal/archive"
+	"demoserv/internal/audit"
 	"demoserv/internal/cache"
 	"demoserv/internal/config"
-	"demoserv/internal/http-server/handlers/getOrder"
+	"demoserv/internal/encryption"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/router"
 	"demoserv/internal/kafka"
+	"demoserv/internal/lib/logger"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/mask"
+	"demoserv/internal/partition"
 	"demoserv/internal/postgress"
+	"demoserv/internal/rollup"
+	"demoserv/internal/tracing"
 	
 	"net/http"
 	"context"
-	"log"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"os"
+	"time"
 )
 
 func main() {
@@ -21,66 +29,148 @@ func main() {
 	// читаем конфиг
 	cfg, err := config.New()
 	if err != nil {
-		log.Fatalf("config error: %v", err)
+		fatal(slog.Default(), "config error", err)
+	}
+
+	// логгер из конфига, стандартный log тоже пишет через него
+	log, err := logger.New(cfg.Log, os.Stdout)
+	if err != nil {
+		fatal(slog.Default(), "logger config error", err)
+	}
+	slog.SetDefault(log)
+	log.Info("config read", slog.String("log_level", cfg.Log.Level))
+
+	// трассировка: OTLP в коллектор или stdout для локальной отладки
+	shutdownTracing, err := tracing.New(ctx, cfg.Tracing, os.Stdout)
+	if err != nil {
+		fatal(log, "tracing config error", err)
+	}
+	defer shutdownTracing(context.Background())
+	if cfg.Tracing.Enabled {
+		log.Info("tracing enabled", slog.String("exporter", cfg.Tracing.Exporter), slog.String("endpoint", cfg.Tracing.Endpoint))
 	}
-	log.Println("config read")
 
 	// подключаемся к базе
-	pool, err := postgress.New(ctx, cfg.Postgres)
+	pool, err := postgress.New(ctx, cfg.Postgres, log.With(slog.String("component", "postgres")))
 	if err != nil {
-		log.Fatalf("unable to connect to database: %v", err)
+		fatal(log, "unable to connect to database", err)
 	}
-	log.Println("database connected")
+	log.Info("database connected")
 	defer pool.Close()
 
+	// шифрование персональных данных
+	var cipher *encryption.Cipher
+	if cfg.Encryption.Enabled {
+		cipher, err = encryption.New(cfg.Encryption)
+		if err != nil {
+			fatal(log, "encryption config error", err)
+		}
+		if err := postgress.LoadDataKeys(ctx, pool, cipher); err != nil {
+			fatal(log, "unable to load data keys", err)
+		}
+		log.Info("encryption enabled", slog.String("data_key", cipher.ActiveKeyID()))
+	}
+	repo := postgress.NewRepository(pool, cipher)
+
 	// инициализируем кэш
 	ordersCache := cache.NewCache(1000)
-	if err := cache.InitCacheFromDB(ctx, pool, ordersCache); err != nil {
-		log.Fatalf("unable to init cache from database: %v", err)
+	if err := cache.InitCacheFromDB(ctx, repo, ordersCache, log.With(slog.String("component", "cache"))); err != nil {
+		fatal(log, "unable to init cache from database", err)
+	}
+
+	// журнал доступа к заказам
+	var auditLog *audit.Logger
+	if cfg.Audit.Enabled {
+		auditLog = audit.New(cfg.Audit, repo.InsertAuditEntries, log.With(slog.String("component", "audit")))
+		go auditLog.Run(ctx)
+	}
+
+	// месячные секции таблиц заказов на следующие месяцы
+	if cfg.Partitions.Enabled {
+		partitions := partition.New(cfg.Partitions, func(ctx context.Context, from time.Time, months int) (int, error) {
+			return postgress.CreateOrderPartitions(ctx, pool, from, months)
+		}, log.With(slog.String("component", "partitions")))
+		go partitions.Run(ctx)
+	}
+
+	// пересчет сводных таблиц за последние дни
+	if cfg.Rollups.RefreshInterval > 0 {
+		refresher := rollup.New(cfg.Rollups, func(ctx context.Context, from, to time.Time) error {
+			return postgress.RefreshRollups(ctx, pool, from, to)
+		}, log.With(slog.String("component", "rollups")))
+		go refresher.Run(ctx)
+	}
+
+	// архивация заказов старше срока хранения
+	if cfg.Archive.Enabled {
+		store, err := archive.NewStore(cfg.Archive)
+		if err != nil {
+			fatal(log, "archive config error", err)
+		}
+		archiver := archive.New(cfg.Archive, func(ctx context.Context, cutoff time.Time, limit int, upload archive.UploadFunc) (int, error) {
+			uids, err := repo.ArchiveOrders(ctx, cutoff, limit, upload)
+			ordersCache.Remove(uids...)
+			return len(uids), err
+		}, store, log.With(slog.String("component", "archive")))
+		go archiver.Run(ctx)
 	}
-	log.Println("cache initialized")
+
+	// монитор отставания и зависания консьюмера
+	consumerLog := log.With(slog.String("component", "consumer"))
+	monitor := kafka.NewMonitor(cfg.Kafka, kafka.GroupOffsets(cfg.Kafka), consumerLog)
+	go monitor.Run(ctx)
+	control := kafka.NewControl(kafka.GroupMembers(cfg.Kafka))
 
 	// инициализируем kafka consumer
-	log.Println("Starting Kafka consumer in background...")
+	log.Info("starting kafka consumer in background")
 	go func() {
-		kafka.NewConsumer(ctx, cfg, pool, ordersCache)
+		kafka.NewConsumer(ctx, cfg, repo, ordersCache, auditLog, monitor, control, consumerLog)
 	}()
 
 	go func() {
-		kafka.NewProducer(cfg)
+		kafka.NewProducer(cfg, log.With(slog.String("component", "producer")))
 	}()
 
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		fatal(log, "auth config error", err)
+	}
+	if !cfg.Auth.Enabled {
+		log.Warn("authentication is disabled", slog.Any("anonymous_roles", cfg.Auth.AnonymousRoles))
+	}
 
+	policy, err := mask.NewPolicy(cfg.Masking.Rules)
+	if err != nil {
+		fatal(log, "masking config error", err)
+	}
 
+	httpRouter := router.New(ctx, log, cfg, router.Deps{
+		Cache:         ordersCache,
+		Repo:          repo,
+		Authenticator: authenticator,
+		Policy:        policy,
+		AuditLog:      auditLog,
+		Monitor:       monitor,
+		Control:       control,
+	})
 
-	router := chi.NewRouter()
-	router.Use(cors.Handler(cors.Options{
-        AllowedOrigins:   []string{"*", "null"}, 
-        AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-        AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-        ExposedHeaders:   []string{"Link"},
-        AllowCredentials: false,
-        MaxAge:           300, 
-    }))
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
-
-	
-
-	router.Get("/order/{order_uid}", getorder.New(ctx, ordersCache, pool))
-
-	log.Printf("starting server on %s", cfg.HttpServer.Address)
+	log.Info("starting server", slog.String("address", cfg.HttpServer.Address))
 
 	srv := &http.Server{
 		Addr:        cfg.HttpServer.Address,
-		Handler:     router,
+		Handler:     httpRouter,
 		ReadTimeout: cfg.HttpServer.Timeout,
+		ErrorLog:    slog.NewLogLogger(log.Handler(), slog.LevelError),
 	}
 
 	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("failed to start server: %s", err.Error())
+		fatal(log, "failed to start server", err)
 	}
 
 }
+
+// fatal пишет ошибку и завершает процесс
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, sl.Err(err))
+	os.Exit(1)
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS archive_manifest;
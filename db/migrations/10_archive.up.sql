@@ -0,0 +1,24 @@
+-- Файлы архива старых заказов. Заказ удаляется из базы в той же транзакции,
+-- в которой записывается строка манифеста, поэтому по манифесту всегда можно
+-- найти файл, в котором лежит удаленный заказ
+CREATE TABLE archive_manifest (
+    id BIGSERIAL PRIMARY KEY,
+    object_key TEXT NOT NULL UNIQUE,
+    storage VARCHAR(20) NOT NULL,
+    location TEXT NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    cutoff TIMESTAMP NOT NULL,
+    orders INT NOT NULL,
+    min_date_created TIMESTAMP,
+    max_date_created TIMESTAMP,
+    order_uids TEXT[] NOT NULL,
+    customer_ids TEXT[] NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_archive_manifest_order_uids ON archive_manifest USING GIN (order_uids);
+CREATE INDEX idx_archive_manifest_customer_ids ON archive_manifest USING GIN (customer_ids);
+CREATE INDEX idx_archive_manifest_cutoff ON archive_manifest (cutoff);
@@ -0,0 +1,8 @@
+-- Заказы, восстановленные из архива. Пока с восстановления не прошел срок хранения,
+-- заказ не архивируется повторно
+CREATE TABLE archive_restores (
+    order_uid VARCHAR(255) PRIMARY KEY,
+    manifest_id BIGINT NOT NULL REFERENCES archive_manifest(id),
+    actor VARCHAR(255) NOT NULL,
+    restored_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,17 @@
+-- Синхронизация и перенос в delivery_part копируют столбцы delivery без name_index
+DO $migration$
+BEGIN
+    IF to_regclass('delivery_part') IS NULL THEN
+        RETURN;
+    END IF;
+
+    CREATE OR REPLACE FUNCTION delivery_part_columns() RETURNS TEXT[] AS $$
+        SELECT ARRAY['name', 'phone', 'zip', 'city', 'address', 'region', 'email', 'key_id']
+    $$ LANGUAGE sql STABLE;
+
+    ALTER TABLE delivery_part DROP COLUMN IF EXISTS name_index;
+END
+$migration$;
+
+DROP INDEX IF EXISTS idx_delivery_name_index;
+ALTER TABLE delivery DROP COLUMN IF EXISTS name_index;
@@ -0,0 +1,24 @@
+-- Слепой индекс имени получателя: HMAC слов имени, вычисленные ключом из мастер-ключа.
+-- Зашифрованное имя не попадает в search_vector, по name_index его находят целыми словами.
+-- NULL — индекс не построен: строки открытым текстом или зашифрованные до этой миграции,
+-- их дополняет demoservctl encrypt
+ALTER TABLE delivery ADD COLUMN name_index TEXT[];
+CREATE INDEX idx_delivery_name_index ON delivery USING GIN (name_index);
+
+-- Если секционирование подготовлено (demoservctl partition-prepare), а таблицы
+-- еще не переключены, name_index нужен и в delivery_part, а синхронизация и перенос
+-- должны его копировать
+DO $migration$
+BEGIN
+    IF to_regclass('delivery_part') IS NULL THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE delivery_part ADD COLUMN IF NOT EXISTS name_index TEXT[];
+    CREATE INDEX IF NOT EXISTS idx_delivery_part_name_index ON delivery_part USING GIN (name_index);
+
+    CREATE OR REPLACE FUNCTION delivery_part_columns() RETURNS TEXT[] AS $$
+        SELECT ARRAY['name', 'phone', 'zip', 'city', 'address', 'region', 'email', 'key_id', 'name_index']
+    $$ LANGUAGE sql STABLE;
+END
+$migration$;
@@ -0,0 +1,2 @@
+ALTER TABLE delivery DROP COLUMN IF EXISTS key_id;
+DROP TABLE IF EXISTS data_keys;
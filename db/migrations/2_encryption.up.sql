@@ -0,0 +1,15 @@
+-- Ключи данных, зашифрованные мастер-ключом
+CREATE TABLE data_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    master_key_id VARCHAR(64) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Зашифрованные значения длиннее исходных
+ALTER TABLE delivery
+    ALTER COLUMN name TYPE TEXT,
+    ALTER COLUMN phone TYPE TEXT,
+    ALTER COLUMN address TYPE TEXT,
+    ALTER COLUMN email TYPE TEXT,
+    ADD COLUMN key_id VARCHAR(64) REFERENCES data_keys(id);
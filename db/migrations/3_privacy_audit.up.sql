@@ -0,0 +1,12 @@
+-- Журнал выгрузок и обезличивания данных клиентов
+CREATE TABLE privacy_audit (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(20) NOT NULL,
+    customer_id VARCHAR(255) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    order_uids TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX idx_privacy_audit_customer_id ON privacy_audit (customer_id);
@@ -0,0 +1,28 @@
+-- Журнал доступа к заказам и их изменений
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    principal VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    order_uid VARCHAR(255),
+    status INT NOT NULL,
+    source_ip VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+CREATE INDEX idx_audit_log_principal ON audit_log (principal, created_at);
+CREATE INDEX idx_audit_log_order_uid ON audit_log (order_uid, created_at);
+
+-- Журнал только дополняется
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
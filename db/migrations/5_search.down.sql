@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_delivery_search;
+ALTER TABLE delivery DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_items_search;
+ALTER TABLE items DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_orders_search;
+ALTER TABLE orders DROP COLUMN IF EXISTS search_vector;
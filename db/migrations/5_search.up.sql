@@ -0,0 +1,24 @@
+-- Полнотекстовый поиск заказов. Сгенерированный столбец может ссылаться
+-- только на свою таблицу, поэтому вектор есть в каждой таблице, где ищем.
+-- Словарь simple: названия товаров и бренды на разных языках, без стемминга
+
+-- Трек-номер заказа
+ALTER TABLE orders ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector('simple', coalesce(track_number, '')), 'A')) STORED;
+CREATE INDEX idx_orders_search ON orders USING GIN (search_vector);
+
+-- Название товара и бренд
+ALTER TABLE items ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(name, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(brand, '')), 'B')
+    ) STORED;
+CREATE INDEX idx_items_search ON items USING GIN (search_vector);
+
+-- Имя получателя. У зашифрованных имен (key_id не пуст) в name шифртекст,
+-- вектор для них не строится
+ALTER TABLE delivery ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        CASE WHEN key_id IS NULL THEN setweight(to_tsvector('simple', coalesce(name, '')), 'C') END
+    ) STORED;
+CREATE INDEX idx_delivery_search ON delivery USING GIN (search_vector);
@@ -0,0 +1,4 @@
+-- Поиск заказа по трек-номеру и по транзакции оплаты.
+-- Ключи не уникальны в данных, поэтому индексы обычные
+CREATE INDEX idx_orders_track_number ON orders (track_number, date_created DESC);
+CREATE INDEX idx_payment_transaction ON payment (transaction);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_orders_customer_id_date;
+CREATE INDEX idx_orders_customer_id ON orders (customer_id);
@@ -0,0 +1,4 @@
+-- История заказов клиента: выборка по customer_id от новых к старым.
+-- Заменяет одноколоночный индекс idx_orders_customer_id из 3_privacy_audit
+DROP INDEX idx_orders_customer_id;
+CREATE INDEX idx_orders_customer_id_date ON orders (customer_id, date_created DESC);
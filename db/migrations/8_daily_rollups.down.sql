@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_date_created;
+DROP TABLE IF EXISTS daily_provider_sales;
+DROP TABLE IF EXISTS daily_delivery_sales;
+DROP TABLE IF EXISTS daily_brand_sales;
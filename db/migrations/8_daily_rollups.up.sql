@@ -0,0 +1,34 @@
+-- Сводные таблицы продаж по дням для аналитики. Обновляются при вставке заказа,
+-- существующие данные заполняются командой demoservctl rollup-backfill
+CREATE TABLE daily_brand_sales (
+    day DATE NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    PRIMARY KEY (day, brand, currency)
+);
+
+CREATE TABLE daily_delivery_sales (
+    day DATE NOT NULL,
+    delivery_service VARCHAR(255) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    PRIMARY KEY (day, delivery_service, currency)
+);
+
+CREATE TABLE daily_provider_sales (
+    day DATE NOT NULL,
+    provider VARCHAR(255) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    PRIMARY KEY (day, provider, currency)
+);
+
+-- Выборка за период строится по дате
+CREATE INDEX idx_orders_date_created ON orders (date_created);
@@ -0,0 +1,3 @@
+ALTER TABLE items DROP COLUMN date_created;
+ALTER TABLE payment DROP COLUMN date_created;
+ALTER TABLE delivery DROP COLUMN date_created;
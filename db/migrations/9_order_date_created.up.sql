@@ -0,0 +1,6 @@
+-- Дочерние таблицы получают date_created заказа: без него нельзя секционировать
+-- и ссылаться на секционированный orders. Само секционирование включается
+-- отдельно командой demoservctl partition-prepare
+ALTER TABLE delivery ADD COLUMN date_created TIMESTAMP;
+ALTER TABLE payment ADD COLUMN date_created TIMESTAMP;
+ALTER TABLE items ADD COLUMN date_created TIMESTAMP;
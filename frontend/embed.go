@@ -0,0 +1,8 @@
+package frontend
+
+import "embed"
+
+// FS статика фронтенда, встроенная в бинарник
+//
+//go:embed index.html styles
+var FS embed.FS
@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+)
+
+const day = 24 * time.Hour
+
+// UploadFunc записывает пачку заказов в хранилище и проверяет файл
+type UploadFunc func(ctx context.Context, orders []models.ArchivedOrder) (models.ArchiveObject, error)
+
+// BatchFunc архивирует до limit заказов старше cutoff: передает их в upload и после
+// успешной загрузки удаляет из базы. Возвращает число заказов, например postgress.ArchiveOrders
+type BatchFunc func(ctx context.Context, cutoff time.Time, limit int, upload UploadFunc) (int, error)
+
+// Archiver по расписанию выгружает старые заказы в архив и удаляет их из базы
+type Archiver struct {
+	batch         BatchFunc
+	store         Store
+	interval      time.Duration
+	retentionDays int
+	batchSize     int
+	log           *slog.Logger
+}
+
+// New создает задачу архивации. Архивация начинается после запуска Run
+func New(cfg models.ArchiveConfig, batch BatchFunc, store Store, log *slog.Logger) *Archiver {
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = 365
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = day
+	}
+	return &Archiver{
+		batch:         batch,
+		store:         store,
+		interval:      cfg.Interval,
+		retentionDays: cfg.RetentionDays,
+		batchSize:     cfg.BatchSize,
+		log:           log,
+	}
+}
+
+// Cutoff граница хранения: начало дня по UTC, отстоящего от now на days дней
+func Cutoff(now time.Time, days int) time.Time {
+	return now.UTC().Truncate(day).Add(-time.Duration(days) * day)
+}
+
+// Archive выгружает все заказы старше срока хранения пачками. Возвращает число заказов
+func (a *Archiver) Archive(ctx context.Context) (int, error) {
+	cutoff := Cutoff(time.Now(), a.retentionDays)
+	start := time.Now()
+	total := 0
+	for {
+		n, err := a.batch(ctx, cutoff, a.batchSize, a.Upload)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < a.batchSize {
+			break
+		}
+	}
+	a.log.Info("orders archived",
+		slog.Time("cutoff", cutoff), slog.Int("orders", total), slog.Duration("took", time.Since(start)))
+	return total, nil
+}
+
+// Upload записывает пачку в хранилище, читает файл обратно и сверяет контрольную сумму
+// и состав заказов. Заказы можно удалять из базы только после успешной проверки
+func (a *Archiver) Upload(ctx context.Context, orders []models.ArchivedOrder) (models.ArchiveObject, error) {
+	if len(orders) == 0 {
+		return models.ArchiveObject{}, fmt.Errorf("empty archive batch")
+	}
+	data, err := Encode(orders)
+	if err != nil {
+		return models.ArchiveObject{}, err
+	}
+	sum := sha256.Sum256(data)
+	storage, location := a.store.Name()
+	obj := models.ArchiveObject{
+		Key:      Key(orders[0].DateCreated, time.Now()),
+		Storage:  storage,
+		Location: location,
+		Format:   FormatNDJSON,
+		Size:     int64(len(data)),
+		SHA256:   hex.EncodeToString(sum[:]),
+	}
+	if err := a.store.Put(ctx, obj.Key, data); err != nil {
+		return obj, fmt.Errorf("put %s: %w", obj.Key, err)
+	}
+	if err := Verify(ctx, a.store, obj, orders); err != nil {
+		return obj, err
+	}
+	return obj, nil
+}
+
+// Key имя файла: год и месяц первого заказа пачки, время выгрузки
+func Key(firstOrder, now time.Time) string {
+	return fmt.Sprintf("%s/orders-%s.%s",
+		firstOrder.UTC().Format("2006/01"), now.UTC().Format("20060102T150405.000000000Z"), FormatNDJSON)
+}
+
+// Verify читает файл из хранилища и проверяет размер, sha256 и то, что в нем ровно заказы orders
+func Verify(ctx context.Context, store Store, obj models.ArchiveObject, orders []models.ArchivedOrder) error {
+	data, err := Fetch(ctx, store, obj)
+	if err != nil {
+		return err
+	}
+	stored, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", obj.Key, err)
+	}
+	want := make(map[string]bool, len(orders))
+	for _, o := range orders {
+		want[o.OrderUID] = true
+	}
+	if len(stored) != len(orders) {
+		return fmt.Errorf("verify %s: %d orders in archive, want %d", obj.Key, len(stored), len(orders))
+	}
+	for _, o := range stored {
+		if !want[o.OrderUID] {
+			return fmt.Errorf("verify %s: unexpected order %s", obj.Key, o.OrderUID)
+		}
+		delete(want, o.OrderUID)
+	}
+	if len(want) != 0 {
+		return fmt.Errorf("verify %s: %d orders are missing", obj.Key, len(want))
+	}
+	return nil
+}
+
+// Fetch читает файл архива и сверяет размер и sha256 с манифестом
+func Fetch(ctx context.Context, store Store, obj models.ArchiveObject) ([]byte, error) {
+	r, err := store.Get(ctx, obj.Key)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", obj.Key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", obj.Key, err)
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != obj.Size || hex.EncodeToString(sum[:]) != obj.SHA256 {
+		return nil, fmt.Errorf("archive %s is corrupted: size %d, sha256 %x", obj.Key, len(data), sum)
+	}
+	return data, nil
+}
+
+// Run архивирует раз в interval до отмены ctx
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := a.Archive(ctx); err != nil && ctx.Err() == nil {
+			a.log.Warn("unable to archive orders", sl.Err(err))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"demoserv/internal/models"
+)
+
+// FormatNDJSON заказы по одному JSON в строке, файл сжат gzip
+const FormatNDJSON = "ndjson.gz"
+
+// maxLineSize максимальная длина строки NDJSON при чтении
+const maxLineSize = 16 << 20
+
+// Encode пишет заказы в формате FormatNDJSON
+func Encode(orders []models.ArchivedOrder) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for i := range orders {
+		if err := enc.Encode(&orders[i]); err != nil {
+			return nil, fmt.Errorf("encode order %s: %w", orders[i].OrderUID, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode читает заказы в формате FormatNDJSON
+func Decode(r io.Reader) ([]models.ArchivedOrder, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip: %w", err)
+	}
+	defer zr.Close()
+
+	var orders []models.ArchivedOrder
+	sc := bufio.NewScanner(zr)
+	sc.Buffer(make([]byte, 64<<10), maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var o models.ArchivedOrder
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		orders = append(orders, o)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"demoserv/internal/models"
+)
+
+// RestoreFunc возвращает заказ из файла manifestID в базу, например postgress.RestoreOrder.
+// restored=false, если заказ уже есть в базе
+type RestoreFunc func(ctx context.Context, order models.ArchivedOrder, manifestID int64) (restored, anonymized bool, err error)
+
+// Load читает заказы файла архива. Файл должен лежать в настроенном хранилище
+func Load(ctx context.Context, store Store, m models.ArchiveManifest) ([]models.ArchivedOrder, error) {
+	storage, location := store.Name()
+	if m.Storage != storage || m.Location != location {
+		return nil, fmt.Errorf("archive %d is stored in %s %s, configured storage is %s %s",
+			m.ID, m.Storage, m.Location, storage, location)
+	}
+	data, err := Fetch(ctx, store, models.ArchiveObject{Key: m.Key, Size: m.Size, SHA256: m.SHA256})
+	if err != nil {
+		return nil, err
+	}
+	orders, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", m.Key, err)
+	}
+	return orders, nil
+}
+
+// Restore возвращает в базу заказы uids из файлов manifests, а если uids пуст — все заказы
+// файлов. Заказ, который архивировался несколько раз, берется из первого файла, поэтому
+// manifests передаются от новых к старым. При ошибке возвращает отчет о том, что уже восстановлено
+func Restore(ctx context.Context, store Store, manifests []models.ArchiveManifest, uids []string, restore RestoreFunc) (models.RestoreReport, error) {
+	report := models.RestoreReport{
+		Manifests:  []int64{},
+		Restored:   []string{},
+		Skipped:    []string{},
+		NotFound:   []string{},
+		Anonymized: []string{},
+	}
+	wanted := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		wanted[uid] = true
+	}
+	done := map[string]bool{}
+
+	for _, m := range manifests {
+		orders, err := Load(ctx, store, m)
+		if err != nil {
+			return report, err
+		}
+		report.Manifests = append(report.Manifests, m.ID)
+		for _, o := range orders {
+			if done[o.OrderUID] || (len(uids) > 0 && !wanted[o.OrderUID]) {
+				continue
+			}
+			done[o.OrderUID] = true
+
+			restored, anonymized, err := restore(ctx, o, m.ID)
+			if err != nil {
+				return report, fmt.Errorf("restore %s: %w", o.OrderUID, err)
+			}
+			if !restored {
+				report.Skipped = append(report.Skipped, o.OrderUID)
+				continue
+			}
+			report.Restored = append(report.Restored, o.OrderUID)
+			if anonymized {
+				report.Anonymized = append(report.Anonymized, o.OrderUID)
+			}
+		}
+	}
+	for _, uid := range uids {
+		if !done[uid] {
+			report.NotFound = append(report.NotFound, uid)
+			done[uid] = true
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"demoserv/internal/models"
+)
+
+// Хранилища архива
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// ErrObjectNotFound файла архива нет в хранилище
+var ErrObjectNotFound = errors.New("archive object not found")
+
+// Store хранилище файлов архива
+type Store interface {
+	// Put записывает файл целиком, при ошибке файл не должен остаться частично записанным
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Name тип хранилища и его расположение (каталог или бакет) для манифеста
+	Name() (storage, location string)
+}
+
+// NewStore хранилище из конфига
+func NewStore(cfg models.ArchiveConfig) (Store, error) {
+	switch cfg.Storage {
+	case StorageLocal, "":
+		return NewLocalStore(cfg.Dir)
+	case StorageS3:
+		return NewS3Store(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown archive storage %q", cfg.Storage)
+	}
+}
+
+// LocalStore файлы архива в локальном каталоге
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore хранилище в каталоге dir, каталог создается при первой записи
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("archive dir is not configured")
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) Name() (string, string) {
+	return StorageLocal, s.dir
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid archive key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put пишет во временный файл и переименовывает его, чтобы файл появился только целиком
+func (s *LocalStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create archive dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("create archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write archive file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync archive file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close archive file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename archive file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open archive file: %w", err)
+	}
+	return f, nil
+}
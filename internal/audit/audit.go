@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+)
+
+// Действия в журнале доступа
+const (
+	ActionRead      = "read"
+	ActionIngest    = "ingest"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionExport    = "export"
+	ActionAnonymize = "anonymize"
+	ActionConsumer  = "consumer"
+	ActionSearch    = "search"
+	ActionRestore   = "restore"
+	ActionEvict     = "evict"
+)
+
+// WriteFunc записывает пачку записей, например postgress.InsertAuditEntries
+type WriteFunc func(ctx context.Context, entries []models.AuditEntry) error
+
+// Logger копит записи журнала в буфере и пишет их пачками в фоне,
+// чтобы запись журнала не замедляла запросы.
+// nil *Logger ничего не пишет — так журнал выключается
+type Logger struct {
+	entries       chan models.AuditEntry
+	write         WriteFunc
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Int64
+	log           *slog.Logger
+}
+
+// New создает журнал. Запись начинается после запуска Run
+func New(cfg models.AuditConfig, write WriteFunc, log *slog.Logger) *Logger {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	return &Logger{
+		entries:       make(chan models.AuditEntry, cfg.BufferSize),
+		write:         write,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		log:           log,
+	}
+}
+
+// Log ставит запись в очередь без ожидания. Если буфер полон, запись теряется
+func (l *Logger) Log(e models.AuditEntry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	select {
+	case l.entries <- e:
+	default:
+		if n := l.dropped.Add(1); n == 1 || n%1000 == 0 {
+			l.log.Warn("audit buffer is full, entry dropped", slog.Int64("dropped", n))
+		}
+	}
+}
+
+// Dropped сколько записей потеряно из-за переполнения буфера
+func (l *Logger) Dropped() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.dropped.Load()
+}
+
+// Run пишет записи пачками до отмены ctx, затем сбрасывает то, что осталось в буфере
+func (l *Logger) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditEntry, 0, l.batchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.write(ctx, batch); err != nil {
+			l.log.Error("unable to write audit entries", slog.Int("entries", len(batch)), sl.Err(err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-l.entries:
+			batch = append(batch, e)
+			if len(batch) >= l.batchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// контекст уже отменен, дописываем с отдельным таймаутом
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case e := <-l.entries:
+					batch = append(batch, e)
+					if len(batch) >= l.batchSize {
+						flush(flushCtx)
+					}
+				default:
+					flush(flushCtx)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package audit_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"demoserv/internal/audit"
+	"demoserv/internal/models"
+)
+
+// recorder запоминает пачки, которые записал Logger
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]models.AuditEntry
+}
+
+func (r *recorder) write(_ context.Context, entries []models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]models.AuditEntry(nil), entries...))
+	return nil
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func run(t *testing.T, l *audit.Logger) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestLogger_BatchesBySize(t *testing.T) {
+	rec := &recorder{}
+	l := audit.New(models.AuditConfig{BatchSize: 2, FlushInterval: time.Hour}, rec.write, slog.New(slog.DiscardHandler))
+	for i := 0; i < 5; i++ {
+		l.Log(models.AuditEntry{Action: audit.ActionRead})
+	}
+	stop := run(t, l)
+	stop()
+
+	sizes := rec.sizes()
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("expected batches [2 2 1], got %v", sizes)
+	}
+}
+
+func TestLogger_FlushesByInterval(t *testing.T) {
+	rec := &recorder{}
+	l := audit.New(models.AuditConfig{BatchSize: 100, FlushInterval: 10 * time.Millisecond}, rec.write, slog.New(slog.DiscardHandler))
+	stop := run(t, l)
+	defer stop()
+
+	l.Log(models.AuditEntry{Action: audit.ActionRead, OrderUID: "o1"})
+
+	deadline := time.Now().Add(time.Second)
+	for len(rec.sizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("entry was not flushed by interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if got := rec.batches[0][0]; got.OrderUID != "o1" || got.Time.IsZero() {
+		t.Fatalf("unexpected entry %+v", got)
+	}
+}
+
+func TestLogger_DropsWhenBufferFull(t *testing.T) {
+	rec := &recorder{}
+	l := audit.New(models.AuditConfig{BufferSize: 2}, rec.write, slog.New(slog.DiscardHandler))
+	for i := 0; i < 5; i++ {
+		l.Log(models.AuditEntry{})
+	}
+	if l.Dropped() != 3 {
+		t.Fatalf("expected 3 dropped entries, got %d", l.Dropped())
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var l *audit.Logger
+	l.Log(models.AuditEntry{})
+	if l.Dropped() != 0 {
+		t.Fatalf("nil logger must not drop entries")
+	}
+}
@@ -3,83 +3,16 @@ package cache_test
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"testing"
 	"time"
 
 	"demoserv/internal/cache"
 	"demoserv/internal/models"
-	"demoserv/internal/postgress"
-	"demoserv/internal/testutils"
-
-	"github.com/jackc/pgx/v5/pgxpool"
+	"demoserv/internal/storage/memory"
 )
 
-
-func createTables(t *testing.T, pool *pgxpool.Pool) {
-	t.Helper()
-	ctx := context.Background()
-	sql := `
-CREATE TABLE IF NOT EXISTS orders (
-    order_uid VARCHAR(255) PRIMARY KEY,
-    track_number VARCHAR(255) NOT NULL,
-    entry VARCHAR(50),
-    locale VARCHAR(10),
-    internal_signature VARCHAR(255),
-    customer_id VARCHAR(255),
-    delivery_service VARCHAR(255),
-    shardkey VARCHAR(10),
-    sm_id INT,
-    date_created TIMESTAMP,
-    oof_shard VARCHAR(10)
-);
-CREATE TABLE IF NOT EXISTS delivery (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    name VARCHAR(255),
-    phone VARCHAR(50),
-    zip VARCHAR(20),
-    city VARCHAR(255),
-    address VARCHAR(255),
-    region VARCHAR(255),
-    email VARCHAR(255)
-);
-CREATE TABLE IF NOT EXISTS payment (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    transaction VARCHAR(255),
-    request_id VARCHAR(255),
-    currency VARCHAR(10),
-    provider VARCHAR(50),
-    amount INT,
-    payment_dt BIGINT,
-    bank VARCHAR(50),
-    delivery_cost INT,
-    goods_total INT,
-    custom_fee INT
-);
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) REFERENCES orders(order_uid) ON DELETE CASCADE,
-    chrt_id BIGINT,
-    track_number VARCHAR(255),
-    price INT,
-    rid VARCHAR(255),
-    name VARCHAR(255),
-    sale INT,
-    size VARCHAR(50),
-    total_price INT,
-    nm_id BIGINT,
-    brand VARCHAR(255),
-    status INT,
-    CONSTRAINT unique_order_item UNIQUE (order_uid, chrt_id)
-);
-`
-	if _, err := pool.Exec(ctx, sql); err != nil {
-		t.Fatalf("createTables exec: %v", err)
-	}
-}
-
 func sampleOrder(uid string) *models.Order {
 	return &models.Order{
 		OrderUID:    uid,
@@ -130,6 +63,67 @@ func TestCache_AddGet_Eviction(t *testing.T) {
 	}
 }
 
+func TestCache_Remove(t *testing.T) {
+	c := cache.NewCache(2)
+	c.Add(models.Order{OrderUID: "o1"})
+	c.Add(models.Order{OrderUID: "o2"})
+
+	c.Remove("o1", "missing")
+	if _, ok := c.Get("o1"); ok {
+		t.Fatalf("expected o1 removed")
+	}
+
+	// освободившееся место не должно вытеснять o2
+	c.Add(models.Order{OrderUID: "o3"})
+	if _, ok := c.Get("o2"); !ok {
+		t.Fatalf("expected o2 to remain")
+	}
+	if _, ok := c.Get("o3"); !ok {
+		t.Fatalf("expected o3 present")
+	}
+}
+
+func TestCache_AddIfUnchanged(t *testing.T) {
+	c := cache.NewCache(10)
+
+	version := c.Version()
+	if !c.AddIfUnchanged(*sampleOrder("a"), version) {
+		t.Fatal("order must be added when nothing was removed")
+	}
+	// заказ прочитан до Remove, например до обезличивания, и не должен вернуться в кэш
+	version = c.Version()
+	c.Remove("b")
+	if c.AddIfUnchanged(*sampleOrder("b"), version) {
+		t.Fatal("order read before Remove must not be added")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("stale order is in cache")
+	}
+}
+
+func TestCache_AddReplaces(t *testing.T) {
+	c := cache.NewCache(2)
+	o1 := sampleOrder("o1")
+	c.Add(*o1)
+
+	// замена заказа не занимает второе место в очереди
+	o1.TrackNumber = "T-new"
+	c.Add(*o1)
+	c.Add(*sampleOrder("o2"))
+	got, ok := c.Get("o1")
+	if !ok {
+		t.Fatalf("expected o1 to remain after replace")
+	}
+	if got.TrackNumber != "T-new" {
+		t.Fatalf("expected replaced order, got track number %q", got.TrackNumber)
+	}
+
+	c.Add(*sampleOrder("o3")) // evict o1
+	if _, ok := c.Get("o1"); ok {
+		t.Fatalf("expected o1 to be evicted")
+	}
+}
+
 func TestCache_ConcurrentAccess(t *testing.T) {
 	c := cache.NewCache(1000)
 	var wg sync.WaitGroup
@@ -161,11 +155,8 @@ func TestCache_ConcurrentAccess(t *testing.T) {
 	}
 }
 
-func TestInitCacheFromDB_Embedded(t *testing.T) {
-	_, pool := testutils.StartEmbeddedPG(t)
-
-	createTables(t, pool)
-
+func TestInitCacheFromDB(t *testing.T) {
+	repo := memory.New()
 	ctx := context.Background()
 
 	// insert 2 orders
@@ -173,15 +164,15 @@ func TestInitCacheFromDB_Embedded(t *testing.T) {
 	o2 := sampleOrder("init-2")
 	o2.DateCreated = time.Now() // o2 is newer
 
-	if err := postgress.InsertOrder(ctx, pool, o1); err != nil {
+	if err := repo.InsertOrder(ctx, o1); err != nil {
 		t.Fatalf("InsertOrder o1: %v", err)
 	}
-	if err := postgress.InsertOrder(ctx, pool, o2); err != nil {
+	if err := repo.InsertOrder(ctx, o2); err != nil {
 		t.Fatalf("InsertOrder o2: %v", err)
 	}
 
 	c := cache.NewCache(10)
-	if err := cache.InitCacheFromDB(ctx, pool, c); err != nil {
+	if err := cache.InitCacheFromDB(ctx, repo, c, slog.New(slog.DiscardHandler)); err != nil {
 		t.Fatalf("InitCacheFromDB failed: %v", err)
 	}
 
@@ -4,11 +4,10 @@ import (
 	// "container/list"
 	"context"
 	"demoserv/internal/models"
-	"demoserv/internal/postgress"
+	"demoserv/internal/storage"
 	"fmt"
+	"log/slog"
 	"sync"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Cache struct {
@@ -16,6 +15,9 @@ type Cache struct {
 	data  map[string]models.Order
 	queue []string
 	limit int
+
+	// version меняется при каждом Remove, см. AddIfUnchanged
+	version uint64
 }
 
 // NewCache создает новый кэш
@@ -27,21 +29,48 @@ func NewCache(limit int) *Cache {
 	}
 }
 
-// Add добавляет заказ в кэш
+// Add добавляет заказ в кэш. Заказ, который уже есть в кэше, заменяется
 func (c *Cache) Add(order models.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.add(order)
+}
 
-	if len(c.queue) >= c.limit {
-		oldest := c.queue[0]
-		c.queue = c.queue[1:]
-		delete(c.data, oldest)
+// add добавляет заказ. Вызывается под mu
+func (c *Cache) add(order models.Order) {
+	if _, ok := c.data[order.OrderUID]; !ok {
+		if len(c.queue) >= c.limit {
+			oldest := c.queue[0]
+			c.queue = c.queue[1:]
+			delete(c.data, oldest)
+		}
+		c.queue = append(c.queue, order.OrderUID)
 	}
 
-	c.queue = append(c.queue, order.OrderUID)
 	c.data[order.OrderUID] = order
 }
 
+// Version текущая версия кэша. Читатель, который на промахе берет заказ из хранилища,
+// запоминает ее до запроса и кладет заказ через AddIfUnchanged
+func (c *Cache) Version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// AddIfUnchanged добавляет заказ, только если после version из кэша ничего не удаляли.
+// Иначе заказ мог быть прочитан до изменения, из-за которого его убрали из кэша,
+// например до обезличивания, и вернул бы в кэш старые данные
+func (c *Cache) AddIfUnchanged(order models.Order, version uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return false
+	}
+	c.add(order)
+	return true
+}
+
 // Get получает заказ из кэша
 func (c *Cache) Get(orderUID string) (models.Order, bool) {
 	c.mu.Lock()
@@ -51,9 +80,31 @@ func (c *Cache) Get(orderUID string) (models.Order, bool) {
 	return order, ok
 }
 
-// InitCacheFromDB инициализирует кэш из базы
-func InitCacheFromDB(ctx context.Context, pool *pgxpool.Pool, cache *Cache) error {
-	orders, err := postgress.GetLastOrders(ctx, pool, cache.limit)
+// Remove удаляет заказы из кэша. Заказы, прочитанные из хранилища до вызова,
+// после него через AddIfUnchanged в кэш не попадут
+func (c *Cache) Remove(orderUIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+
+	for _, uid := range orderUIDs {
+		if _, ok := c.data[uid]; !ok {
+			continue
+		}
+		delete(c.data, uid)
+		for i, queued := range c.queue {
+			if queued == uid {
+				c.queue = append(c.queue[:i], c.queue[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// InitCacheFromDB инициализирует кэш из хранилища
+func InitCacheFromDB(ctx context.Context, repo storage.OrderRepository, cache *Cache, log *slog.Logger) error {
+	orders, err := repo.GetLastOrders(ctx, cache.limit)
 	if err != nil {
 		return fmt.Errorf("unable to get last orders: %v", err)
 	}
@@ -61,5 +112,6 @@ func InitCacheFromDB(ctx context.Context, pool *pgxpool.Pool, cache *Cache) erro
 	for i := len(orders) - 1; i >= 0; i-- {
 		cache.Add(orders[i])
 	}
+	log.Info("cache initialized", slog.Int("orders", len(orders)), slog.Int("limit", cache.limit))
 	return nil
 }
@@ -8,9 +8,18 @@ import (
 )
 
 type Config struct {
+	Log        models.LogConfig        `yaml:"LOG"`
 	Postgres   models.PostgresConfig   `yaml:"POSTGRES"`
 	Kafka      models.KafkaConfig      `yaml:"KAFKA"`
 	HttpServer models.HttpServerConfig `yaml:"HTTP_SERVER"`
+	Auth       models.AuthConfig       `yaml:"AUTH"`
+	Masking    models.MaskingConfig    `yaml:"MASKING"`
+	Encryption models.EncryptionConfig `yaml:"ENCRYPTION"`
+	Audit      models.AuditConfig      `yaml:"AUDIT"`
+	Tracing    models.TracingConfig    `yaml:"TRACING"`
+	Rollups    models.RollupsConfig    `yaml:"ROLLUPS"`
+	Partitions models.PartitionsConfig `yaml:"PARTITIONS"`
+	Archive    models.ArchiveConfig    `yaml:"ARCHIVE"`
 }
 
 // New конфиг
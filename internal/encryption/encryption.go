@@ -0,0 +1,235 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"demoserv/internal/models"
+)
+
+// KeySize длина мастер-ключа и ключей данных (AES-256)
+const KeySize = 32
+
+// ErrUnknownKey ключ данных с таким id не загружен
+var ErrUnknownKey = errors.New("unknown data key")
+
+// Cipher конвертное шифрование: поля шифруются ключами данных (DEK),
+// а сами ключи данных хранятся в базе зашифрованными мастер-ключом
+type Cipher struct {
+	masterKeyID string
+	master      cipher.AEAD
+	// indexKey ключ HMAC для слепого индекса, выводится из мастер-ключа
+	indexKey []byte
+
+	mu     sync.RWMutex
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// New читает мастер-ключ из файла или переменной окружения
+func New(cfg models.EncryptionConfig) (*Cipher, error) {
+	if cfg.MasterKeyID == "" {
+		return nil, fmt.Errorf("master key id is empty")
+	}
+	key, err := loadMasterKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(cfg.MasterKeyID, key)
+}
+
+// NewCipher создает Cipher с уже прочитанным мастер-ключом
+func NewCipher(masterKeyID string, masterKey []byte) (*Cipher, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("master key %q: %w", masterKeyID, err)
+	}
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte("demoserv/blind-index"))
+	return &Cipher{
+		masterKeyID: masterKeyID,
+		master:      master,
+		indexKey:    mac.Sum(nil),
+		keys:        make(map[string]cipher.AEAD),
+	}, nil
+}
+
+func loadMasterKey(cfg models.EncryptionConfig) ([]byte, error) {
+	var raw string
+	switch {
+	case cfg.MasterKeyFile != "":
+		data, err := os.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read master key: %w", err)
+		}
+		raw = string(data)
+	case cfg.MasterKeyEnv != "":
+		raw = os.Getenv(cfg.MasterKeyEnv)
+		if raw == "" {
+			return nil, fmt.Errorf("master key env %s is empty", cfg.MasterKeyEnv)
+		}
+	default:
+		return nil, fmt.Errorf("master key source is not configured")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("master key is not base64: %w", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MasterKeyID id мастер-ключа, которым зашифрованы ключи данных
+func (c *Cipher) MasterKeyID() string {
+	return c.masterKeyID
+}
+
+// ActiveKeyID ключ данных, которым шифруются новые записи
+func (c *Cipher) ActiveKeyID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// HasKey загружен ли ключ данных
+func (c *Cipher) HasKey(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.keys[id]
+	return ok
+}
+
+// GenerateKey создает новый ключ данных и делает его активным.
+// Возвращает id и ключ, зашифрованный мастер-ключом, для сохранения в базе
+func (c *Cipher) GenerateKey() (string, []byte, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+	id := "dek-" + hex.EncodeToString(idBytes)
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	wrapped, err := seal(c.master, key, []byte(id))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := c.AddKey(id, wrapped, true); err != nil {
+		return "", nil, err
+	}
+	return id, wrapped, nil
+}
+
+// AddKey расшифровывает ключ данных мастер-ключом и загружает его
+func (c *Cipher) AddKey(id string, wrapped []byte, active bool) error {
+	key, err := open(c.master, wrapped, []byte(id))
+	if err != nil {
+		return fmt.Errorf("unwrap data key %s: %w", id, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("data key %s: %w", id, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys[id] = aead
+	if active {
+		c.active = id
+	}
+	return nil
+}
+
+// BlindIndex детерминированный токен значения для поиска по зашифрованному полю:
+// равные значения дают равные токены, само значение из токена не восстановить.
+// Токен зависит от мастер-ключа, при смене мастер-ключа индекс строится заново
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Encrypt шифрует значение ключом keyID. aad привязывает шифртекст к записи и полю,
+// чтобы его нельзя было переставить в другую строку
+func (c *Cipher) Encrypt(keyID, plaintext, aad string) (string, error) {
+	aead, err := c.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение, зашифрованное Encrypt
+func (c *Cipher) Decrypt(keyID, ciphertext, aad string) (string, error) {
+	aead, err := c.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("ciphertext is not base64: %w", err)
+	}
+	plain, err := open(aead, sealed, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (c *Cipher) key(id string) (cipher.AEAD, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	aead, ok := c.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return aead, nil
+}
+
+// seal шифрует данные, nonce записывается перед шифртекстом
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plain, nil
+}
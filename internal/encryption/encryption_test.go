@@ -0,0 +1,124 @@
+package encryption_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+)
+
+func newCipher(t *testing.T) *encryption.Cipher {
+	t.Helper()
+	c, err := encryption.NewCipher("master-1", bytes.Repeat([]byte{7}, encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	return c
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := newCipher(t)
+	id, _, err := c.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if c.ActiveKeyID() != id {
+		t.Fatalf("generated key must become active")
+	}
+
+	ct, err := c.Encrypt(id, "+9720000000", "order-1/phone")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if ct == "+9720000000" {
+		t.Fatalf("ciphertext equals plaintext")
+	}
+
+	got, err := c.Decrypt(id, ct, "order-1/phone")
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if got != "+9720000000" {
+		t.Fatalf("expected plaintext back, got %q", got)
+	}
+
+	if _, err := c.Decrypt(id, ct, "order-2/phone"); err == nil {
+		t.Fatalf("expected error for ciphertext moved to another row")
+	}
+}
+
+func TestAddKey_Rotation(t *testing.T) {
+	c := newCipher(t)
+	oldID, oldWrapped, _ := c.GenerateKey()
+	ct, _ := c.Encrypt(oldID, "Test Testov", "o/name")
+	newID, _, _ := c.GenerateKey()
+	if c.ActiveKeyID() != newID {
+		t.Fatalf("expected %s active, got %s", newID, c.ActiveKeyID())
+	}
+
+	// после перезапуска старый ключ подгружается из базы
+	restarted := newCipher(t)
+	if err := restarted.AddKey(oldID, oldWrapped, false); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	got, err := restarted.Decrypt(oldID, ct, "o/name")
+	if err != nil || got != "Test Testov" {
+		t.Fatalf("decrypt with reloaded key: %q, %v", got, err)
+	}
+
+	if _, err := restarted.Encrypt(newID, "x", "o/name"); !errors.Is(err, encryption.ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestAddKey_WrongMaster(t *testing.T) {
+	id, wrapped, _ := newCipher(t).GenerateKey()
+
+	other, _ := encryption.NewCipher("master-2", bytes.Repeat([]byte{8}, encryption.KeySize))
+	if err := other.AddKey(id, wrapped, true); err == nil {
+		t.Fatalf("expected unwrap error with another master key")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := newCipher(t)
+	token := c.BlindIndex("testov")
+	if token != newCipher(t).BlindIndex("testov") {
+		t.Fatal("blind index must not depend on the cipher instance")
+	}
+	if token == c.BlindIndex("test") || strings.Contains(token, "testov") {
+		t.Fatalf("unexpected token %q", token)
+	}
+	other, _ := encryption.NewCipher("master-2", bytes.Repeat([]byte{8}, encryption.KeySize))
+	if token == other.BlindIndex("testov") {
+		t.Fatal("blind index must depend on the master key")
+	}
+}
+
+func TestNew_MasterKeySources(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, encryption.KeySize))
+
+	path := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if _, err := encryption.New(models.EncryptionConfig{MasterKeyID: "m", MasterKeyFile: path}); err != nil {
+		t.Fatalf("key from file: %v", err)
+	}
+
+	t.Setenv("TEST_MASTER_KEY", key)
+	if _, err := encryption.New(models.EncryptionConfig{MasterKeyID: "m", MasterKeyEnv: "TEST_MASTER_KEY"}); err != nil {
+		t.Fatalf("key from env: %v", err)
+	}
+
+	t.Setenv("TEST_MASTER_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+	if _, err := encryption.New(models.EncryptionConfig{MasterKeyID: "m", MasterKeyEnv: "TEST_MASTER_KEY"}); err == nil {
+		t.Fatalf("expected error for short key")
+	}
+}
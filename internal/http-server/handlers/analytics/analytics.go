@@ -0,0 +1,208 @@
+package analytics
+
+import (
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultPeriod = 30 * 24 * time.Hour
+	maxBuckets    = 2000
+	maxTop        = 100
+	defaultTop    = 10 // для отчета по брендам
+	dateLayout    = "2006-01-02"
+)
+
+// dimensions название разреза отчета, оно же колонка в CSV
+var dimensions = map[string]string{
+	models.ReportSales:            "",
+	models.ReportBrands:           "brand",
+	models.ReportDeliveryServices: "delivery_service",
+	models.ReportBanks:            "bank",
+	models.ReportProviders:        "provider",
+}
+
+var bucketSizes = map[string]time.Duration{
+	models.BucketHour: time.Hour,
+	models.BucketDay:  24 * time.Hour,
+	models.BucketWeek: 7 * 24 * time.Hour,
+}
+
+// Response отчет в JSON
+type Response struct {
+	Report    string                `json:"report"`
+	Dimension string                `json:"dimension,omitempty"`
+	Bucket    string                `json:"bucket"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Currency  string                `json:"currency,omitempty"`
+	Rows      []models.AnalyticsRow `json:"rows"`
+}
+
+// New отдает отчет report по интервалам bucket (hour, day, week) за период from..to
+// с фильтром по валюте. format=csv или Accept: text/csv — ответ в CSV.
+// rollups — отчеты по дням и неделям читать из сводных таблиц
+func New(log *slog.Logger, repo storage.OrderRepository, report string, rollups bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.analytics.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("report", report),
+		)
+		query, err := parseQuery(r.URL.Query(), report, time.Now().UTC())
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		query.Rollups = rollups
+		asCSV, err := wantCSV(r)
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rows, err := repo.Analytics(r.Context(), query)
+		if err != nil {
+			log.Error("unable to build report", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to build report")
+			return
+		}
+
+		if asCSV {
+			if err := writeCSV(w, query, rows); err != nil {
+				log.Error("unable to write csv", sl.Err(err))
+			}
+			return
+		}
+		render.JSON(w, r, Response{
+			Report:    report,
+			Dimension: dimensions[report],
+			Bucket:    query.Bucket,
+			From:      query.From,
+			To:        query.To,
+			Currency:  query.Currency,
+			Rows:      rows,
+		})
+	}
+}
+
+func parseQuery(q url.Values, report string, now time.Time) (models.AnalyticsQuery, error) {
+	aq := models.AnalyticsQuery{
+		Report:   report,
+		Bucket:   models.BucketDay,
+		Currency: strings.ToUpper(strings.TrimSpace(q.Get("currency"))),
+	}
+	if report == models.ReportBrands {
+		aq.Top = defaultTop
+	}
+
+	if v := q.Get("bucket"); v != "" {
+		if _, ok := bucketSizes[v]; !ok {
+			return aq, fmt.Errorf("bucket must be hour, day or week")
+		}
+		aq.Bucket = v
+	}
+
+	var err error
+	aq.To = now
+	if v := q.Get("to"); v != "" {
+		if aq.To, err = parseTime(v); err != nil {
+			return aq, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	aq.From = aq.To.Add(-defaultPeriod)
+	if v := q.Get("from"); v != "" {
+		if aq.From, err = parseTime(v); err != nil {
+			return aq, fmt.Errorf("invalid from: %v", err)
+		}
+	}
+	if !aq.From.Before(aq.To) {
+		return aq, fmt.Errorf("from must be before to")
+	}
+	if aq.To.Sub(aq.From)/bucketSizes[aq.Bucket] > maxBuckets {
+		return aq, fmt.Errorf("period is too long for bucket %s: at most %d buckets", aq.Bucket, maxBuckets)
+	}
+
+	if v := q.Get("top"); v != "" {
+		if aq.Top, err = strconv.Atoi(v); err != nil || aq.Top < 0 || aq.Top > maxTop {
+			return aq, fmt.Errorf("top must be between 0 and %d", maxTop)
+		}
+	}
+	return aq, nil
+}
+
+// parseTime принимает RFC 3339 или дату YYYY-MM-DD (начало дня UTC)
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("expected RFC 3339 or %s", dateLayout)
+	}
+	return t.UTC(), nil
+}
+
+// wantCSV формат ответа: параметр format важнее заголовка Accept
+func wantCSV(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), "text/csv"), nil
+	default:
+		return false, fmt.Errorf("format must be json or csv")
+	}
+}
+
+func writeCSV(w http.ResponseWriter, q models.AnalyticsQuery, rows []models.AnalyticsRow) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("%s-%s-%s.csv", q.Report, q.From.Format(dateLayout), q.To.Format(dateLayout))))
+
+	dimension := dimensions[q.Report]
+	header := []string{"bucket"}
+	if dimension != "" {
+		header = append(header, dimension)
+	}
+	header = append(header, "currency", "orders", "items", "revenue", "avg_basket")
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		record := []string{r.Bucket.UTC().Format(time.RFC3339)}
+		if dimension != "" {
+			record = append(record, r.Key)
+		}
+		record = append(record, r.Currency,
+			strconv.FormatInt(r.Orders, 10),
+			strconv.FormatInt(r.Items, 10),
+			strconv.FormatInt(r.Revenue, 10),
+			strconv.FormatFloat(r.AvgBasket, 'f', 2, 64),
+		)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
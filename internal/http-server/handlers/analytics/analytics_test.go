@@ -0,0 +1,75 @@
+package analytics_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"demoserv/internal/http-server/handlers/analytics"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+)
+
+func TestHandler_InvalidQuery(t *testing.T) {
+	h := analytics.New(slog.New(slog.DiscardHandler), memory.New(), models.ReportBrands, false)
+	for _, query := range []string{
+		"bucket=month",
+		"from=yesterday",
+		"to=2026-13-01",
+		"from=2026-02-01&to=2026-01-01",
+		"bucket=hour&from=2025-01-01&to=2026-01-01",
+		"top=-1",
+		"top=1000",
+		"format=xml",
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/analytics/brands?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_Report(t *testing.T) {
+	repo := memory.New()
+	day := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	for i, uid := range []string{"r-1", "r-2"} {
+		o := storagetest.Order(uid, day.Add(time.Duration(i+1)*time.Hour))
+		if i == 1 {
+			o.DeliveryService = "dhl"
+		}
+		if err := repo.InsertOrder(context.Background(), o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	h := analytics.New(slog.New(slog.DiscardHandler), repo, models.ReportDeliveryServices, false)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/analytics/delivery-services?from=2026-04-01&to=2026-04-02", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp analytics.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Dimension != "delivery_service" || len(resp.Rows) != 2 || resp.Rows[0].Key != "dhl" || resp.Rows[1].Key != "meest" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/analytics/delivery-services?from=2026-04-01&to=2026-04-02&format=csv", nil))
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(records) != 3 || records[0][1] != "delivery_service" || records[1][1] != "dhl" {
+		t.Fatalf("unexpected csv %v", records)
+	}
+}
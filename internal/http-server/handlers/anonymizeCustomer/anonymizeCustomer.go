@@ -0,0 +1,54 @@
+package anonymizecustomer
+
+import (
+	"demoserv/internal/cache"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/storage"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Response список обезличенных заказов
+type Response struct {
+	CustomerID string   `json:"customer_id"`
+	OrderUIDs  []string `json:"order_uids"`
+}
+
+// New обезличивает данные доставки во всех заказах клиента и сразу после фиксации
+// убирает эти заказы из кэша, до ответа клиенту
+func New(log *slog.Logger, cache *cache.Cache, repo storage.AdminRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.anonymizeCustomer.New"
+		customerID := chi.URLParam(r, "customer_id")
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("customer_id", customerID),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		uids, err := repo.AnonymizeCustomer(r.Context(), customerID, principal.String())
+		if err != nil {
+			log.Error("unable to anonymize customer", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to anonymize customer data")
+			return
+		}
+		if len(uids) == 0 {
+			response.Error(w, r, http.StatusNotFound, fmt.Sprintf("customer %s not found", customerID))
+			return
+		}
+
+		cache.Remove(uids...)
+		log.Info("customer anonymized", slog.String("principal", principal.String()), slog.Int("orders", len(uids)))
+
+		render.JSON(w, r, Response{CustomerID: customerID, OrderUIDs: uids})
+	}
+}
@@ -0,0 +1,61 @@
+package anonymizecustomer_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"demoserv/internal/cache"
+	anonymizecustomer "demoserv/internal/http-server/handlers/anonymizeCustomer"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandler_EvictsAnonymizedOrders(t *testing.T) {
+	repo := memory.New()
+	ordersCache := cache.NewCache(10)
+	for _, uid := range []string{"an-1", "an-2"} {
+		order := storagetest.Order(uid, time.Now())
+		if err := repo.InsertOrder(context.Background(), order); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		ordersCache.Add(*order)
+	}
+	r := chi.NewRouter()
+	r.Post("/admin/customers/{customer_id}/anonymize", anonymizecustomer.New(slog.New(slog.DiscardHandler), ordersCache, repo))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/customers/cust/anonymize", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp anonymizecustomer.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.OrderUIDs) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	// кэш не должен отдавать данные доставки после ответа
+	for _, uid := range resp.OrderUIDs {
+		if _, ok := ordersCache.Get(uid); ok {
+			t.Fatalf("order %s is still cached", uid)
+		}
+	}
+	if order, err := repo.GetOrder(context.Background(), "an-1"); err != nil || order.Delivery != (models.Delivery{}) {
+		t.Fatalf("delivery is not anonymized: %+v, %v", order.Delivery, err)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/customers/nobody/anonymize", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown customer, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package consumercontrol
+
+import (
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/kafka"
+	"demoserv/internal/lib/logger/sl"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// pauseTimeout сколько ждать, пока консьюмер дообработает текущее сообщение
+const pauseTimeout = 30 * time.Second
+
+// SeekRequest новое смещение группы в партиции
+type SeekRequest struct {
+	Partition *int   `json:"partition"`
+	Offset    *int64 `json:"offset"`
+}
+
+// NewPause ставит консьюмер на паузу и отвечает, когда текущее сообщение обработано.
+// Если обработка не закончилась за pauseTimeout, отвечает 202 с состоянием pausing
+func NewPause(log *slog.Logger, control *kafka.Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumerControl.NewPause"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), pauseTimeout)
+		defer cancel()
+		err := control.Pause(ctx, principal.String())
+		switch {
+		case errors.Is(err, kafka.ErrNoConsumer):
+			response.Error(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		case err != nil:
+			log.Warn("consumer is still processing a message", sl.Err(err))
+			render.Status(r, http.StatusAccepted)
+		default:
+			log.Info("consumer paused", slog.String("principal", principal.String()))
+		}
+		render.JSON(w, r, control.Snapshot())
+	}
+}
+
+// NewResume продолжает чтение после паузы
+func NewResume(log *slog.Logger, control *kafka.Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumerControl.NewResume"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		if err := control.Resume(principal.String()); err != nil {
+			response.Error(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		log.Info("consumer resumed", slog.String("principal", principal.String()))
+		render.JSON(w, r, control.Snapshot())
+	}
+}
+
+// NewSeek сдвигает смещение группы в партиции. Только на паузе
+func NewSeek(log *slog.Logger, control *kafka.Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumerControl.NewSeek"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		var req SeekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Partition == nil || *req.Partition < 0 || req.Offset == nil || *req.Offset < 0 {
+			response.Error(w, r, http.StatusBadRequest, "partition and offset must be non-negative integers")
+			return
+		}
+
+		err := control.Seek(r.Context(), *req.Partition, *req.Offset)
+		switch {
+		case errors.Is(err, kafka.ErrNotPaused):
+			response.Error(w, r, http.StatusConflict, "pause the consumer before seeking")
+			return
+		case errors.Is(err, kafka.ErrNoConsumer):
+			response.Error(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		case err != nil:
+			log.Error("unable to seek", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to seek partition")
+			return
+		}
+		log.Info("partition offset moved",
+			slog.String("principal", principal.String()),
+			slog.Int("partition", *req.Partition),
+			slog.Int64("offset", *req.Offset))
+		render.JSON(w, r, control.Snapshot())
+	}
+}
@@ -0,0 +1,28 @@
+package consumerstatus
+
+import (
+	"demoserv/internal/kafka"
+	"demoserv/internal/models"
+
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// Response состояние консьюмера и его цикла
+type Response struct {
+	models.ConsumerStatus
+	State models.ConsumerState `json:"state"`
+}
+
+// New отдает состояние консьюмера: отставание по партициям, последние
+// закоммиченные смещения, время последнего сообщения, ошибки и ребалансы,
+// паузу и партиции этого экземпляра
+func New(monitor *kafka.Monitor, control *kafka.Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{
+			ConsumerStatus: monitor.Status(),
+			State:          control.State(r.Context()),
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package customerorders
+
+import (
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Pagination параметры страницы
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+// Response страница истории заказов клиента со сводкой по всем заказам
+type Response struct {
+	CustomerID string                 `json:"customer_id"`
+	Stats      models.CustomerStats   `json:"stats"`
+	Orders     []models.CustomerOrder `json:"orders"`
+	Pagination Pagination             `json:"pagination"`
+}
+
+// New отдает историю заказов клиента от новых к старым с постраничным выводом limit/offset
+// и сводку: число заказов, траты по валютам, даты первого и последнего заказа
+// и самую частую службу доставки
+func New(log *slog.Logger, repo storage.OrderRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.customerOrders.New"
+		customerID := chi.URLParam(r, "customer_id")
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("customer_id", customerID),
+		)
+		limit, offset, err := parsePage(r.URL.Query())
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stats, err := repo.CustomerStats(r.Context(), customerID)
+		if err != nil {
+			log.Error("unable to get customer stats", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to get customer orders")
+			return
+		}
+		if stats.TotalOrders == 0 {
+			response.Error(w, r, http.StatusNotFound, fmt.Sprintf("customer %s not found", customerID))
+			return
+		}
+
+		orders, err := repo.CustomerOrders(r.Context(), customerID, limit, offset)
+		if err != nil {
+			log.Error("unable to get customer orders", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to get customer orders")
+			return
+		}
+
+		render.JSON(w, r, Response{
+			CustomerID: customerID,
+			Stats:      stats,
+			Orders:     orders,
+			Pagination: Pagination{Limit: limit, Offset: offset, Total: stats.TotalOrders},
+		})
+	}
+}
+
+func parsePage(q url.Values) (limit, offset int, err error) {
+	limit = defaultLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be non-negative")
+		}
+	}
+	return limit, offset, nil
+}
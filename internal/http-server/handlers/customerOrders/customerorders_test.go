@@ -0,0 +1,66 @@
+package customerorders_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	customerorders "demoserv/internal/http-server/handlers/customerOrders"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandler_InvalidPage(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/customers/{customer_id}/orders", customerorders.New(slog.New(slog.DiscardHandler), memory.New()))
+	for _, query := range []string{
+		"limit=0",
+		"limit=1000",
+		"limit=ten",
+		"offset=-1",
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/customers/test/orders?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_History(t *testing.T) {
+	repo := memory.New()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, uid := range []string{"h-1", "h-2", "h-3"} {
+		if err := repo.InsertOrder(context.Background(), storagetest.Order(uid, base.Add(time.Duration(i)*time.Hour))); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	r := chi.NewRouter()
+	r.Get("/customers/{customer_id}/orders", customerorders.New(slog.New(slog.DiscardHandler), repo))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/customers/cust/orders?limit=2&offset=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp customerorders.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Stats.TotalOrders != 3 || resp.Pagination.Total != 3 || len(resp.Orders) != 2 ||
+		resp.Orders[0].OrderUID != "h-2" || resp.Orders[1].OrderUID != "h-1" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/customers/nobody/orders", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown customer, got %d", rr.Code)
+	}
+}
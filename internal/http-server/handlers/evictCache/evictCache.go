@@ -0,0 +1,64 @@
+package evictcache
+
+import (
+	"demoserv/internal/cache"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxOrderUIDs сколько заказов можно убрать из кэша одним запросом
+const maxOrderUIDs = 1000
+
+// Request заказы, которые нужно убрать из кэша
+type Request struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+// Response заказы, убранные из кэша
+type Response struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+// New убирает заказы из кэша сервиса. Нужен командам demoservctl, которые меняют
+// или удаляют заказы в базе в обход сервиса: обезличивание, архивация
+func New(log *slog.Logger, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.evictCache.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if len(req.OrderUIDs) == 0 {
+			response.Error(w, r, http.StatusBadRequest, "order_uids is required")
+			return
+		}
+		if len(req.OrderUIDs) > maxOrderUIDs {
+			response.Error(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d order_uids per request", maxOrderUIDs))
+			return
+		}
+		if len(req.OrderUIDs) == 1 {
+			auditlog.SetOrderUID(r.Context(), req.OrderUIDs[0])
+		}
+
+		cache.Remove(req.OrderUIDs...)
+		log.Info("orders evicted from cache", slog.String("principal", principal.String()), slog.Int("orders", len(req.OrderUIDs)))
+
+		render.JSON(w, r, Response{OrderUIDs: req.OrderUIDs})
+	}
+}
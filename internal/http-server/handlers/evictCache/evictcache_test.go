@@ -0,0 +1,47 @@
+package evictcache_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demoserv/internal/cache"
+	evictcache "demoserv/internal/http-server/handlers/evictCache"
+	"demoserv/internal/models"
+)
+
+func TestHandler_RemovesOrders(t *testing.T) {
+	c := cache.NewCache(10)
+	c.Add(models.Order{OrderUID: "a", TrackNumber: "TA"})
+	c.Add(models.Order{OrderUID: "b", TrackNumber: "TB"})
+	h := evictcache.New(slog.New(slog.DiscardHandler), c)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/cache/evict", strings.NewReader(`{"order_uids": ["a", "missing"]}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("order a is still cached")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("order b must stay in cache")
+	}
+}
+
+func TestHandler_InvalidRequest(t *testing.T) {
+	h := evictcache.New(slog.New(slog.DiscardHandler), cache.NewCache(10))
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"order_uids": [` + strings.Repeat(`"a",`, 1000) + `"a"]}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/cache/evict", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%.40s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package exportcustomer
+
+import (
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// New выгружает все заказы клиента без маскирования персональных данных.
+// Каждая выгрузка записывается в журнал обработки персональных данных
+func New(log *slog.Logger, repo storage.AdminRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.exportCustomer.New"
+		customerID := chi.URLParam(r, "customer_id")
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("customer_id", customerID),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		orders, err := repo.ExportCustomer(r.Context(), customerID, principal.String())
+		if err != nil {
+			log.Error("unable to export customer", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to export customer data")
+			return
+		}
+		if len(orders) == 0 {
+			response.Error(w, r, http.StatusNotFound, fmt.Sprintf("customer %s not found", customerID))
+			return
+		}
+
+		log.Info("customer exported", slog.String("principal", principal.String()), slog.Int("orders", len(orders)))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "customer-"+customerID+".json"))
+		render.JSON(w, r, models.CustomerExport{
+			CustomerID: customerID,
+			ExportedAt: time.Now().UTC(),
+			Orders:     orders,
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package exportcustomer_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	exportcustomer "demoserv/internal/http-server/handlers/exportCustomer"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandler_Export(t *testing.T) {
+	repo := memory.New()
+	order := storagetest.Order("ex-1", time.Now())
+	if err := repo.InsertOrder(context.Background(), order); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Get("/admin/customers/{customer_id}/export", exportcustomer.New(slog.New(slog.DiscardHandler), repo))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/customers/cust/export", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var export models.CustomerExport
+	if err := json.NewDecoder(rr.Body).Decode(&export); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// выгрузка без маскирования
+	if export.CustomerID != "cust" || len(export.Orders) != 1 || export.Orders[0].Delivery.Phone != order.Delivery.Phone {
+		t.Fatalf("unexpected export %+v", export)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/customers/nobody/export", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown customer, got %d", rr.Code)
+	}
+}
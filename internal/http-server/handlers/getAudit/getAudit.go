@@ -0,0 +1,98 @@
+package getaudit
+
+import (
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Pagination параметры страницы
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+// Response страница журнала доступа
+type Response struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	Pagination Pagination          `json:"pagination"`
+}
+
+// New отдает журнал доступа с фильтрами principal, order_uid, from, to (RFC 3339)
+// и постраничным выводом limit/offset
+func New(log *slog.Logger, repo storage.AdminRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.getAudit.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		entries, total, err := repo.QueryAudit(r.Context(), filter)
+		if err != nil {
+			log.Error("unable to query audit log", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to query audit log")
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Entries:    entries,
+			Pagination: Pagination{Limit: filter.Limit, Offset: filter.Offset, Total: total},
+		})
+	}
+}
+
+func parseFilter(q url.Values) (models.AuditFilter, error) {
+	f := models.AuditFilter{
+		Principal: q.Get("principal"),
+		OrderUID:  q.Get("order_uid"),
+		Limit:     defaultLimit,
+	}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid from: %v", err)
+		}
+		f.From = f.From.UTC()
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid to: %v", err)
+		}
+		f.To = f.To.UTC()
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return f, fmt.Errorf("offset must be non-negative")
+		}
+	}
+	return f, nil
+}
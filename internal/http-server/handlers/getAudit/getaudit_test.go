@@ -0,0 +1,58 @@
+package getaudit_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	getaudit "demoserv/internal/http-server/handlers/getAudit"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+)
+
+func TestHandler_InvalidFilter(t *testing.T) {
+	h := getaudit.New(slog.New(slog.DiscardHandler), memory.New())
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2026-13-01T00:00:00Z",
+		"limit=0",
+		"limit=1000",
+		"offset=-1",
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/audit?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_Entries(t *testing.T) {
+	repo := memory.New()
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var entries []models.AuditEntry
+	for i, principal := range []string{"apikey:alice", "jwt:bob", "apikey:alice"} {
+		entries = append(entries, models.AuditEntry{Time: start.Add(time.Duration(i) * time.Minute), Principal: principal, Action: "read", Status: 200})
+	}
+	if err := repo.InsertAuditEntries(context.Background(), entries); err != nil {
+		t.Fatalf("InsertAuditEntries: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	getaudit.New(slog.New(slog.DiscardHandler), repo).ServeHTTP(rr,
+		httptest.NewRequest("GET", "/admin/audit?principal=apikey:alice&limit=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp getaudit.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Pagination.Total != 2 || len(resp.Entries) != 1 || !resp.Entries[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
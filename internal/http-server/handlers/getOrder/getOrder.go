@@ -2,45 +2,130 @@ package getorder
 
 import (
 	"demoserv/internal/cache"
-	"demoserv/internal/postgress"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/mask"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+	"demoserv/internal/tracing"
 	
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func New(ctx context.Context, cache *cache.Cache, pool *pgxpool.Pool) http.HandlerFunc {
+var tracer = tracing.Tracer("demoserv/internal/http-server/handlers/getOrder")
+
+// lookup способ найти заказ по ключу из пути: сначала в кэше, затем в хранилище
+type lookup struct {
+	op    string
+	param string
+	name  string // имя ключа в ответе 404
+	// cached nil — заказ всегда берется из хранилища. Трек-номер и транзакция не уникальны,
+	// база отдает самый новый заказ с ключом, а в кэше его может не быть среди заказов с тем же ключом
+	cached func(c *cache.Cache, key string) (models.Order, bool)
+	load   func(repo storage.OrderRepository, ctx context.Context, key string) (models.Order, error)
+	span   string
+}
+
+var (
+	byUID = lookup{
+		op:     "handlers.getOrder.New",
+		param:  "order_uid",
+		name:   "order",
+		cached: (*cache.Cache).Get,
+		load:   storage.OrderRepository.GetOrder,
+		span:   "storage.GetOrder",
+	}
+	byTrackNumber = lookup{
+		op:    "handlers.getOrder.NewByTrackNumber",
+		param: "track_number",
+		name:  "order with track number",
+		load:  storage.OrderRepository.GetOrderByTrackNumber,
+		span:  "storage.GetOrderByTrackNumber",
+	}
+	byTransaction = lookup{
+		op:    "handlers.getOrder.NewByTransaction",
+		param: "transaction",
+		name:  "order with transaction",
+		load:  storage.OrderRepository.GetOrderByTransaction,
+		span:  "storage.GetOrderByTransaction",
+	}
+)
+
+// New отдает заказ по order_uid
+func New(ctx context.Context, log *slog.Logger, cache *cache.Cache, repo storage.OrderRepository, policy mask.Policy) http.HandlerFunc {
+	return newHandler(ctx, log, cache, repo, policy, byUID)
+}
+
+// NewByTrackNumber отдает самый новый заказ с трек-номером
+func NewByTrackNumber(ctx context.Context, log *slog.Logger, cache *cache.Cache, repo storage.OrderRepository, policy mask.Policy) http.HandlerFunc {
+	return newHandler(ctx, log, cache, repo, policy, byTrackNumber)
+}
+
+// NewByTransaction отдает самый новый заказ с транзакцией оплаты
+func NewByTransaction(ctx context.Context, log *slog.Logger, cache *cache.Cache, repo storage.OrderRepository, policy mask.Policy) http.HandlerFunc {
+	return newHandler(ctx, log, cache, repo, policy, byTransaction)
+}
+
+func newHandler(ctx context.Context, log *slog.Logger, cache *cache.Cache, repo storage.OrderRepository, policy mask.Policy, l lookup) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("op", l.op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
 
-		order_uid := chi.URLParam(r, "order_uid")
+		key := chi.URLParam(r, l.param)
+		log = log.With(slog.String(l.param, key))
+		// Персональные данные скрываются по ролям клиента
+		principal, _ := auth.FromContext(r.Context())
 
 		// Получаем из кэша
-		if order, ok := cache.Get(order_uid); ok {
-			render.JSON(w, r, order)
-			return
+		if l.cached != nil {
+			_, span := tracer.Start(r.Context(), "cache.Get")
+			order, ok := l.cached(cache, key)
+			span.SetAttributes(attribute.Bool("cache.hit", ok))
+			span.End()
+			if ok {
+				auditlog.SetOrderUID(r.Context(), order.OrderUID)
+				render.JSON(w, r, policy.Apply(order, principal.Roles))
+				return
+			}
 		}
 
-		// Получаем из бд если нет в кэше
-		order, err := postgress.GetOrder(ctx, order_uid, pool)
+		// Получаем из бд если нет в кэше.
+		// Запрос не отменяется вместе с запросом клиента, но остается в его трассе
+		version := cache.Version()
+		dbCtx, span := tracer.Start(trace.ContextWithSpan(ctx, trace.SpanFromContext(r.Context())), l.span)
+		order, err := l.load(repo, dbCtx, key)
+		if errors.Is(err, storage.ErrOrderNotFound) {
+			span.End()
+			log.Info("order not found in db")
+			response.Error(w, r, http.StatusNotFound, fmt.Sprintf("%s %s not found", l.name, key))
+			return
+		}
+		tracing.End(span, err)
 		if err != nil {
-			log.Printf("order %s not found in db", order_uid)
-			http.Error(w, fmt.Sprintf(`{"error":"order %s not found"}`, order_uid), http.StatusNotFound)
+			log.Error("unable to get order", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to get order")
 			return
 		}
 
-		// Добавляем в кэш после получения
-		cache.Add(order)
+		// Добавляем в кэш после получения, если заказ не убрали из кэша, пока он читался.
+		// Заказ, найденный по трек-номеру или транзакции, потом находится в кэше по order_uid
+		cache.AddIfUnchanged(order, version)
+		auditlog.SetOrderUID(r.Context(), order.OrderUID)
 		// Отправляем ответ
-		render.JSON(w, r, order)
-
+		render.JSON(w, r, policy.Apply(order, principal.Roles))
 	}
-
 }
-
-
-
@@ -3,6 +3,7 @@ package getorder_test
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,78 +11,15 @@ import (
 
 	"demoserv/internal/cache"
 	getorder "demoserv/internal/http-server/handlers/getOrder"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/mask"
 	"demoserv/internal/models"
-	"demoserv/internal/postgress"
-	"demoserv/internal/testutils"
+	"demoserv/internal/storage"
+	"demoserv/internal/storage/memory"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func createTables(t *testing.T, pool *pgxpool.Pool) {
-	t.Helper()
-	ctx := context.Background()
-	sql := `
-CREATE TABLE IF NOT EXISTS orders (
-    order_uid VARCHAR(255) PRIMARY KEY,
-    track_number VARCHAR(255) NOT NULL,
-    entry VARCHAR(50),
-    locale VARCHAR(10),
-    internal_signature VARCHAR(255),
-    customer_id VARCHAR(255),
-    delivery_service VARCHAR(255),
-    shardkey VARCHAR(10),
-    sm_id INT,
-    date_created TIMESTAMP,
-    oof_shard VARCHAR(10)
-);
-CREATE TABLE IF NOT EXISTS delivery (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    name VARCHAR(255),
-    phone VARCHAR(50),
-    zip VARCHAR(20),
-    city VARCHAR(255),
-    address VARCHAR(255),
-    region VARCHAR(255),
-    email VARCHAR(255)
-);
-CREATE TABLE IF NOT EXISTS payment (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    transaction VARCHAR(255),
-    request_id VARCHAR(255),
-    currency VARCHAR(10),
-    provider VARCHAR(50),
-    amount INT,
-    payment_dt BIGINT,
-    bank VARCHAR(50),
-    delivery_cost INT,
-    goods_total INT,
-    custom_fee INT
-);
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) REFERENCES orders(order_uid) ON DELETE CASCADE,
-    chrt_id BIGINT,
-    track_number VARCHAR(255),
-    price INT,
-    rid VARCHAR(255),
-    name VARCHAR(255),
-    sale INT,
-    size VARCHAR(50),
-    total_price INT,
-    nm_id BIGINT,
-    brand VARCHAR(255),
-    status INT,
-    CONSTRAINT unique_order_item UNIQUE (order_uid, chrt_id)
-);
-`
-	if _, err := pool.Exec(ctx, sql); err != nil {
-		t.Fatalf("createTables exec: %v", err)
-	}
-}
-
 func sampleOrder(uid string) *models.Order {
 	return &models.Order{
 		OrderUID:    uid,
@@ -113,12 +51,20 @@ func newChiWithHandler(h http.HandlerFunc) http.Handler {
 	return r
 }
 
+func newLookupRouter(c *cache.Cache, repo storage.OrderRepository) http.Handler {
+	log := slog.New(slog.DiscardHandler)
+	r := chi.NewRouter()
+	r.Get("/orders/by-track/{track_number}", getorder.NewByTrackNumber(context.Background(), log, c, repo, mask.DefaultPolicy()))
+	r.Get("/orders/by-transaction/{transaction}", getorder.NewByTransaction(context.Background(), log, c, repo, mask.DefaultPolicy()))
+	return r
+}
+
 func TestHandler_CacheHit(t *testing.T) {
 	c := cache.NewCache(10)
 	o := models.Order{OrderUID: "hit-1", TrackNumber: "T", DateCreated: time.Now()}
 	c.Add(o)
 
-	h := getorder.New(context.Background(), c, nil)
+	h := getorder.New(context.Background(), slog.New(slog.DiscardHandler), c, nil, mask.DefaultPolicy())
 
 	req := httptest.NewRequest("GET", "/order/hit-1", nil)
 	rr := httptest.NewRecorder()
@@ -140,18 +86,15 @@ func TestHandler_CacheHit(t *testing.T) {
 }
 
 func TestHandler_CacheMiss_DBFetch(t *testing.T) {
-	_, pool := testutils.StartEmbeddedPG(t)
-
-	createTables(t, pool)
-
+	repo := memory.New()
 	ctx := context.Background()
 	order := sampleOrder("db-1")
-	if err := postgress.InsertOrder(ctx, pool, order); err != nil {
+	if err := repo.InsertOrder(ctx, order); err != nil {
 		t.Fatalf("InsertOrder failed: %v", err)
 	}
 
 	c := cache.NewCache(10)
-	h := getorder.New(context.Background(), c, pool)
+	h := getorder.New(context.Background(), slog.New(slog.DiscardHandler), c, repo, mask.DefaultPolicy())
 
 	req := httptest.NewRequest("GET", "/order/db-1", nil)
 	rr := httptest.NewRecorder()
@@ -170,4 +113,113 @@ func TestHandler_CacheMiss_DBFetch(t *testing.T) {
 	if got.OrderUID != "db-1" {
 		t.Fatalf("unexpected uid: %s", got.OrderUID)
 	}
+	if _, ok := c.Get("db-1"); !ok {
+		t.Fatalf("expected order in cache after db fetch")
+	}
+}
+
+func TestHandler_NotFound(t *testing.T) {
+	h := getorder.New(context.Background(), slog.New(slog.DiscardHandler), cache.NewCache(10), memory.New(), mask.DefaultPolicy())
+
+	rr := httptest.NewRecorder()
+	newChiWithHandler(h).ServeHTTP(rr, httptest.NewRequest("GET", "/order/missing", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_MasksPIIByRole(t *testing.T) {
+	c := cache.NewCache(10)
+	o := models.Order{
+		OrderUID: "pii-1",
+		Delivery: models.Delivery{Phone: "+9721111111", Email: "alex@gmail.com"},
+	}
+	c.Add(o)
+
+	h := getorder.New(context.Background(), slog.New(slog.DiscardHandler), c, nil, mask.DefaultPolicy())
+	r := newChiWithHandler(h)
+
+	req := httptest.NewRequest("GET", "/order/pii-1", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{ID: "bot", Roles: []string{mask.RoleSupport}}))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var got models.Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got.Delivery.Phone != "+972****1111" || got.Delivery.Email != "a****@gmail.com" {
+		t.Fatalf("expected masked delivery, got %+v", got.Delivery)
+	}
+
+	// в кэше данные остаются полными
+	if cached, _ := c.Get("pii-1"); cached.Delivery.Phone != "+9721111111" {
+		t.Fatalf("cache must keep original data, got %q", cached.Delivery.Phone)
+	}
+}
+
+func TestHandler_ByTrackNumber(t *testing.T) {
+	repo := memory.New()
+	if err := repo.InsertOrder(context.Background(), sampleOrder("track-1")); err != nil {
+		t.Fatalf("InsertOrder failed: %v", err)
+	}
+	c := cache.NewCache(10)
+	r := newLookupRouter(c, repo)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/by-track/T-track-1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var got models.Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got.OrderUID != "track-1" {
+		t.Fatalf("unexpected uid: %s", got.OrderUID)
+	}
+	if _, ok := c.Get("track-1"); !ok {
+		t.Fatalf("expected order in cache by order_uid after db fetch")
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/by-track/T-missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ByTransaction_SkipsCache(t *testing.T) {
+	repo := memory.New()
+	c := cache.NewCache(10)
+	older := sampleOrder("tx-older")
+	older.Payment.Transaction = "tx-123"
+	older.DateCreated = time.Now().Add(-2 * time.Hour)
+	c.Add(*older)
+	o := sampleOrder("tx-order")
+	o.Payment.Transaction = "tx-123"
+	for _, order := range []*models.Order{older, o} {
+		if err := repo.InsertOrder(context.Background(), order); err != nil {
+			t.Fatalf("InsertOrder failed: %v", err)
+		}
+	}
+
+	// в кэше только старый заказ с той же транзакцией, а ответ должен быть как у базы — самый новый
+	rr := httptest.NewRecorder()
+	newLookupRouter(c, repo).ServeHTTP(rr, httptest.NewRequest("GET", "/orders/by-transaction/tx-123", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var got models.Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got.OrderUID != "tx-order" {
+		t.Fatalf("unexpected uid: %s", got.OrderUID)
+	}
+	// транзакция скрывается по ролям так же, как в GET /order
+	if got.Payment.Transaction != "" {
+		t.Fatalf("expected transaction hidden for anonymous client, got %q", got.Payment.Transaction)
+	}
 }
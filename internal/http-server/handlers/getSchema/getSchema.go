@@ -0,0 +1,16 @@
+package getschema
+
+import (
+	"net/http"
+
+	"demoserv/internal/schema"
+)
+
+// New отдает JSON Schema заказа, чтобы продюсеры могли проверять сообщения до отправки
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(schema.Order())
+	}
+}
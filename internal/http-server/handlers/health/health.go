@@ -0,0 +1,33 @@
+package health
+
+import (
+	"net/http"
+
+	"demoserv/internal/kafka"
+
+	"github.com/go-chi/render"
+)
+
+// Response ответ проверки живости
+type Response struct {
+	Status string `json:"status"`
+}
+
+// New отвечает, что процесс жив. Аутентификация не требуется
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{Status: "ok"})
+	}
+}
+
+// NewReady отвечает 503, пока консьюмер завис: отстает и не продвигается
+func NewReady(monitor *kafka.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !monitor.Ready() {
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, Response{Status: "consumer stalled"})
+			return
+		}
+		render.JSON(w, r, Response{Status: "ok"})
+	}
+}
@@ -0,0 +1,111 @@
+package restorearchive
+
+import (
+	"demoserv/internal/archive"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxOrderUIDs сколько заказов можно восстановить одним запросом по order_uid
+const maxOrderUIDs = 1000
+
+// Request файл архива или список заказов, указывается что-то одно
+type Request struct {
+	ManifestID int64    `json:"manifest_id"`
+	OrderUIDs  []string `json:"order_uids"`
+}
+
+// New возвращает в базу заказы из архива: все заказы файла manifest_id или заказы order_uids
+// из любых файлов. Заказы, которые уже есть в базе, пропускаются. Файлы читаются из хранилища ARCHIVE
+func New(log *slog.Logger, repo storage.AdminRepository, cfg models.ArchiveConfig) http.HandlerFunc {
+	store, storeErr := archive.NewStore(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.restoreArchive.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		principal, _ := auth.FromContext(r.Context())
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if (req.ManifestID > 0) == (len(req.OrderUIDs) > 0) {
+			response.Error(w, r, http.StatusBadRequest, "either manifest_id or order_uids is required")
+			return
+		}
+		if len(req.OrderUIDs) > maxOrderUIDs {
+			response.Error(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d order_uids per request", maxOrderUIDs))
+			return
+		}
+		if len(req.OrderUIDs) == 1 {
+			auditlog.SetOrderUID(r.Context(), req.OrderUIDs[0])
+		}
+		if storeErr != nil {
+			log.Error("archive storage is not configured", sl.Err(storeErr))
+			response.Error(w, r, http.StatusServiceUnavailable, "archive storage is not configured")
+			return
+		}
+
+		var manifests []models.ArchiveManifest
+		if req.ManifestID > 0 {
+			m, err := repo.ArchiveManifest(r.Context(), req.ManifestID)
+			if errors.Is(err, storage.ErrManifestNotFound) {
+				response.Error(w, r, http.StatusNotFound, fmt.Sprintf("archive manifest %d not found", req.ManifestID))
+				return
+			}
+			if err != nil {
+				log.Error("unable to get archive manifest", sl.Err(err))
+				response.Error(w, r, http.StatusInternalServerError, "unable to restore orders")
+				return
+			}
+			manifests = append(manifests, m)
+		} else {
+			var err error
+			manifests, err = repo.FindArchiveManifests(r.Context(), req.OrderUIDs)
+			if err != nil {
+				log.Error("unable to find archive manifests", sl.Err(err))
+				response.Error(w, r, http.StatusInternalServerError, "unable to restore orders")
+				return
+			}
+		}
+
+		report, err := archive.Restore(r.Context(), store, manifests, req.OrderUIDs,
+			func(ctx context.Context, order models.ArchivedOrder, manifestID int64) (bool, bool, error) {
+				return repo.RestoreOrder(ctx, order, manifestID, principal.String())
+			})
+		if err == nil {
+			err = repo.UpdateArchiveStatus(r.Context(), report.Manifests)
+		}
+		if err != nil {
+			log.Error("unable to restore orders", slog.Int("restored", len(report.Restored)), sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError,
+				fmt.Sprintf("unable to restore orders, %d restored before the error", len(report.Restored)))
+			return
+		}
+
+		log.Info("orders restored from archive",
+			slog.String("principal", principal.String()),
+			slog.Any("manifests", report.Manifests),
+			slog.Int("restored", len(report.Restored)),
+			slog.Int("skipped", len(report.Skipped)),
+			slog.Int("not_found", len(report.NotFound)))
+		render.JSON(w, r, report)
+	}
+}
@@ -0,0 +1,86 @@
+package restorearchive_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"demoserv/internal/archive"
+	restorearchive "demoserv/internal/http-server/handlers/restoreArchive"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+)
+
+func TestHandler_InvalidRequest(t *testing.T) {
+	h := restorearchive.New(slog.New(slog.DiscardHandler), memory.New(), models.ArchiveConfig{Dir: t.TempDir()})
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"manifest_id": 1, "order_uids": ["a"]}`,
+		`{"order_uids": []}`,
+		`{"order_uids": [` + strings.Repeat(`"a",`, 1000) + `"a"]}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/archive/restore", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%.40s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandler_StorageNotConfigured(t *testing.T) {
+	h := restorearchive.New(slog.New(slog.DiscardHandler), memory.New(), models.ArchiveConfig{Storage: "ftp"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/archive/restore", strings.NewReader(`{"manifest_id": 1}`)))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Restore(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.DiscardHandler)
+	cfg := models.ArchiveConfig{Dir: t.TempDir()}
+	store, err := archive.NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	repo := memory.New()
+	for _, uid := range []string{"ra-1", "ra-2"} {
+		if err := repo.InsertOrder(ctx, storagetest.Order(uid, time.Now().Add(-48*time.Hour))); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	if _, err := repo.ArchiveOrders(ctx, time.Now(), 10, archive.New(cfg, nil, store, log).Upload); err != nil {
+		t.Fatalf("ArchiveOrders: %v", err)
+	}
+
+	h := restorearchive.New(log, repo, cfg)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/archive/restore", strings.NewReader(`{"order_uids": ["ra-2", "ra-missing"]}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var report models.RestoreReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(report.Restored) != 1 || report.Restored[0] != "ra-2" || len(report.NotFound) != 1 || report.NotFound[0] != "ra-missing" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, err := repo.GetOrder(ctx, "ra-2"); err != nil {
+		t.Fatalf("restored order: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/archive/restore", strings.NewReader(`{"manifest_id": 42}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown manifest, got %d", rr.Code)
+	}
+}
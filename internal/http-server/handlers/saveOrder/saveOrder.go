@@ -0,0 +1,87 @@
+package saveorder
+
+import (
+	"demoserv/internal/cache"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/message"
+	"demoserv/internal/models"
+	"demoserv/internal/schema"
+	"demoserv/internal/storage"
+	"demoserv/internal/validate"
+
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxBodySize ограничение на размер тела запроса
+const maxBodySize = 1 << 20
+
+// Response ответ на сохранение заказа
+type Response struct {
+	OrderUID string `json:"order_uid"`
+}
+
+// New принимает заказ в JSON, проверяет его и сохраняет в хранилище и кэш.
+// При schemaValidation тело дополнительно проверяется по JSON Schema
+func New(log *slog.Logger, cache *cache.Cache, repo storage.OrderRepository, schemaValidation bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.saveOrder.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.Error(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			response.Error(w, r, http.StatusBadRequest, "unable to read request body")
+			return
+		}
+
+		// Проверка по JSON Schema
+		if schemaValidation {
+			if err := schema.Validate(body); err != nil {
+				response.Error(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		var order models.Order
+		if err := message.Unmarshal(body, &order, false); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+			return
+		}
+
+		auditlog.SetOrderUID(r.Context(), order.OrderUID)
+		log = log.With(slog.String("order_uid", order.OrderUID))
+
+		// Проверяем валидность каждого поля заказа
+		if err := validate.ValidateOrder(order); err != nil {
+			response.Error(w, r, http.StatusBadRequest, "invalid order data: "+err.Error())
+			return
+		}
+
+		// Вставка в базу
+		if err := repo.InsertOrder(r.Context(), &order); err != nil {
+			log.Error("unable to insert order", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to save order")
+			return
+		}
+
+		cache.Add(order) // добавляем в кэш
+		log.Info("order saved")
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{OrderUID: order.OrderUID})
+	}
+}
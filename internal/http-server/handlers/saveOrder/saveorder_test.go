@@ -0,0 +1,77 @@
+package saveorder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"demoserv/internal/cache"
+	saveorder "demoserv/internal/http-server/handlers/saveOrder"
+	"demoserv/internal/storage/memory"
+)
+
+func readTestOrder(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../../../test.json")
+	if err != nil {
+		t.Fatalf("read test.json: %v", err)
+	}
+	return data
+}
+
+func post(h http.HandlerFunc, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/order", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandler_SchemaValidationRejects(t *testing.T) {
+	var o map[string]any
+	if err := json.Unmarshal(readTestOrder(t), &o); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	o["sm_id"] = "not a number"
+	body, _ := json.Marshal(o)
+
+	rr := post(saveorder.New(slog.New(slog.DiscardHandler), cache.NewCache(10), nil, true), body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_InvalidOrderRejects(t *testing.T) {
+	rr := post(saveorder.New(slog.New(slog.DiscardHandler), cache.NewCache(10), nil, false), []byte(`{"order_uid":"x"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("error body is not json: %v", err)
+	}
+	if got["error"] == "" {
+		t.Fatalf("expected error message, got %v", got)
+	}
+}
+
+func TestHandler_SaveOrder(t *testing.T) {
+	repo := memory.New()
+	c := cache.NewCache(10)
+	rr := post(saveorder.New(slog.New(slog.DiscardHandler), c, repo, true), readTestOrder(t))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body: %s", rr.Code, rr.Body.String())
+	}
+
+	if _, ok := c.Get("c789def8c3c95a7test"); !ok {
+		t.Fatalf("expected order in cache")
+	}
+	if _, err := repo.GetOrder(context.Background(), "c789def8c3c95a7test"); err != nil {
+		t.Fatalf("expected order in repository: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package searchorders
+
+import (
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/mask"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	maxQueryLen  = 200
+)
+
+// Pagination параметры страницы
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+// Response страница найденных заказов
+type Response struct {
+	Results    []models.SearchResult `json:"results"`
+	Pagination Pagination            `json:"pagination"`
+}
+
+// New ищет заказы по словам из q в названиях и брендах товаров, имени получателя
+// и трек-номере. prefix=true ищет последнее слово как префикс — для подсказок при вводе.
+// Фрагменты с персональными данными скрываются по ролям клиента
+func New(log *slog.Logger, repo storage.OrderRepository, policy mask.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.searchOrders.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		query, err := parseQuery(r.URL.Query())
+		if err != nil {
+			response.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		results, total, err := repo.SearchOrders(r.Context(), query)
+		if errors.Is(err, storage.ErrEmptySearch) {
+			response.Error(w, r, http.StatusBadRequest, "q must contain at least one word")
+			return
+		}
+		if err != nil {
+			log.Error("unable to search orders", sl.Err(err))
+			response.Error(w, r, http.StatusInternalServerError, "unable to search orders")
+			return
+		}
+
+		principal, _ := auth.FromContext(r.Context())
+		for i := range results {
+			results[i] = policy.ApplySearch(results[i], principal.Roles)
+		}
+
+		render.JSON(w, r, Response{
+			Results:    results,
+			Pagination: Pagination{Limit: query.Limit, Offset: query.Offset, Total: total},
+		})
+	}
+}
+
+func parseQuery(q url.Values) (models.SearchQuery, error) {
+	sq := models.SearchQuery{
+		Text:  strings.TrimSpace(q.Get("q")),
+		Limit: defaultLimit,
+	}
+	if sq.Text == "" {
+		return sq, fmt.Errorf("q is required")
+	}
+	if len(sq.Text) > maxQueryLen {
+		return sq, fmt.Errorf("q must be at most %d bytes", maxQueryLen)
+	}
+
+	var err error
+	if v := q.Get("prefix"); v != "" {
+		if sq.Prefix, err = strconv.ParseBool(v); err != nil {
+			return sq, fmt.Errorf("prefix must be true or false")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if sq.Limit, err = strconv.Atoi(v); err != nil || sq.Limit < 1 || sq.Limit > maxLimit {
+			return sq, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if sq.Offset, err = strconv.Atoi(v); err != nil || sq.Offset < 0 {
+			return sq, fmt.Errorf("offset must be non-negative")
+		}
+	}
+	return sq, nil
+}
@@ -0,0 +1,90 @@
+package searchorders_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	searchorders "demoserv/internal/http-server/handlers/searchOrders"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/mask"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+)
+
+func TestHandler_InvalidQuery(t *testing.T) {
+	h := searchorders.New(slog.New(slog.DiscardHandler), memory.New(), mask.DefaultPolicy())
+	for _, query := range []string{
+		"",
+		"q=",
+		"q=" + url.QueryEscape("!!! ---"),
+		"q=" + strings.Repeat("a", 201),
+		"q=nike&prefix=maybe",
+		"q=nike&limit=0",
+		"q=nike&limit=1000",
+		"q=nike&offset=-1",
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/search?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_Search(t *testing.T) {
+	repo := memory.New()
+	shoes := storagetest.Order("shoes", time.Now().Add(-time.Hour))
+	shoes.Items[0].Name, shoes.Items[0].Brand = "Air Max", "Nike"
+	shoes.Delivery.Name = "Nike Petrov"
+	bag := storagetest.Order("bag", time.Now())
+	bag.Items[0].Name, bag.Items[0].Brand = "Sport bag", "Nike"
+	for _, o := range []*models.Order{shoes, bag, storagetest.Order("other", time.Now())} {
+		if err := repo.InsertOrder(context.Background(), o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	policy, err := mask.NewPolicy(map[string]map[string]string{mask.RoleAnalyst: {mask.FieldName: "hidden"}})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	h := searchorders.New(slog.New(slog.DiscardHandler), repo, policy)
+
+	search := func(roles ...string) searchorders.Response {
+		req := httptest.NewRequest("GET", "/orders/search?q=nike&limit=1", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Roles: roles}))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+		}
+		var resp searchorders.Response
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	// у shoes совпали товар и имя, поэтому он выше
+	resp := search(mask.RoleAdmin)
+	if resp.Pagination.Total != 2 || len(resp.Results) != 1 || resp.Results[0].OrderUID != "shoes" {
+		t.Fatalf("unexpected page %+v", resp)
+	}
+	if len(resp.Results[0].Snippets) != 2 || resp.Results[0].Snippets[1].Text != "<mark>Nike</mark> Petrov" {
+		t.Fatalf("unexpected snippets %+v", resp.Results[0].Snippets)
+	}
+
+	resp = search(mask.RoleAnalyst)
+	for _, s := range resp.Results[0].Snippets {
+		if s.Field == mask.FieldName {
+			t.Fatalf("name snippet must be hidden for analyst: %+v", resp.Results[0].Snippets)
+		}
+	}
+}
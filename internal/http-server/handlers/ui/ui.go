@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Prefix путь, под которым отдается фронтенд
+const Prefix = "/ui/"
+
+// assetCacheControl сколько браузер может не перепроверять стили
+const assetCacheControl = "public, max-age=3600"
+
+// New отдает статику из fsys под Prefix. ETag считается по содержимому,
+// чтобы браузер мог перепроверять файлы через If-None-Match.
+// index.html перепроверяется всегда, остальное кэшируется на час
+func New(fsys fs.FS) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, Prefix) {
+			http.Redirect(w, r, Prefix, http.StatusMovedPermanently)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/ui")
+		name = strings.TrimPrefix(name, "/")
+		if name == "" {
+			name = "index.html"
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if name == "index.html" {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", assetCacheControl)
+		}
+		sum := sha256.Sum256(content)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+	}
+}
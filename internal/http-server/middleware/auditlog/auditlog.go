@@ -0,0 +1,66 @@
+package auditlog
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"demoserv/internal/audit"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type entryKey struct{}
+
+// New записывает в журнал каждый запрос к маршруту: клиента, маршрут,
+// order_uid, код ответа и IP. Ставится после auth.Middleware
+func New(logger *audit.Logger, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if logger == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &models.AuditEntry{
+				Action:   action,
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				SourceIP: sourceIP(r),
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), entryKey{}, entry)))
+
+			if p, ok := auth.FromContext(r.Context()); ok {
+				entry.Principal = p.String()
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Route = rctx.RoutePattern()
+				if entry.OrderUID == "" {
+					entry.OrderUID = rctx.URLParam("order_uid")
+				}
+			}
+			entry.Status = ww.Status()
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			logger.Log(*entry)
+		})
+	}
+}
+
+// SetOrderUID указывает заказ, если его нет в пути запроса (например, POST /order)
+func SetOrderUID(ctx context.Context, orderUID string) {
+	if entry, ok := ctx.Value(entryKey{}).(*models.AuditEntry); ok {
+		entry.OrderUID = orderUID
+	}
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
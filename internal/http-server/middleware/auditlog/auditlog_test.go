@@ -0,0 +1,71 @@
+package auditlog_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"demoserv/internal/audit"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMiddleware_RecordsRequest(t *testing.T) {
+	var mu sync.Mutex
+	var got []models.AuditEntry
+	logger := audit.New(models.AuditConfig{FlushInterval: time.Hour}, func(_ context.Context, entries []models.AuditEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, entries...)
+		return nil
+	}, slog.New(slog.DiscardHandler))
+
+	withPrincipal := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := auth.Principal{ID: "alice", Method: auth.MethodJWT}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+
+	r := chi.NewRouter()
+	r.Use(withPrincipal)
+	r.With(auditlog.New(logger, audit.ActionRead)).Get("/order/{order_uid}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.With(auditlog.New(logger, audit.ActionIngest)).Post("/order", func(w http.ResponseWriter, r *http.Request) {
+		auditlog.SetOrderUID(r.Context(), "from-body")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/order/o-1", nil),
+		httptest.NewRequest("POST", "/order", nil),
+	} {
+		req.RemoteAddr = "10.0.0.7:51000"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.Run(ctx)
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(got))
+	}
+	read := got[0]
+	if read.Principal != "jwt:alice" || read.Action != audit.ActionRead || read.Route != "/order/{order_uid}" ||
+		read.OrderUID != "o-1" || read.Status != http.StatusNotFound || read.SourceIP != "10.0.0.7" {
+		t.Fatalf("unexpected read entry %+v", read)
+	}
+	ingest := got[1]
+	if ingest.Action != audit.ActionIngest || ingest.OrderUID != "from-body" || ingest.Status != http.StatusCreated {
+		t.Fatalf("unexpected ingest entry %+v", ingest)
+	}
+}
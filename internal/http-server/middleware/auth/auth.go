@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// APIKeyHeader заголовок со статическим ключом
+const APIKeyHeader = "X-API-Key"
+
+var errUnauthorized = errors.New("unauthorized")
+
+type apiKey struct {
+	name  string
+	hash  []byte
+	roles []string
+}
+
+type jwtKey struct {
+	kid string
+	alg string
+	key any
+}
+
+// Claims поля JWT, которые понимает сервис
+type Claims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator проверяет API ключи и JWT
+type Authenticator struct {
+	enabled        bool
+	anonymousRoles []string
+	apiKeys []apiKey
+	jwtKeys []jwtKey
+	parser  *jwt.Parser
+}
+
+// New создает аутентификатор по конфигу. Секреты и публичные ключи читаются сразу,
+// чтобы ошибка в конфиге была видна при старте, а не на первом запросе
+func New(cfg models.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{enabled: cfg.Enabled, anonymousRoles: cfg.AnonymousRoles}
+
+	for _, k := range cfg.APIKeys {
+		hash, err := hex.DecodeString(strings.TrimSpace(k.Hash))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: hash must be hex sha256", k.Name)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: k.Name, hash: hash, roles: k.Roles})
+	}
+
+	for _, k := range cfg.JWT.Keys {
+		key, err := loadJWTKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %v", k.KID, err)
+		}
+		a.jwtKeys = append(a.jwtKeys, key)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.JWT.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.JWT.Issuer))
+	}
+	if cfg.JWT.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.JWT.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+func loadJWTKey(k models.JWTKeyConfig) (jwtKey, error) {
+	switch k.Alg {
+	case jwt.SigningMethodHS256.Alg():
+		if len(k.Secret) < 32 {
+			return jwtKey{}, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		return jwtKey{kid: k.KID, alg: k.Alg, key: []byte(k.Secret)}, nil
+	case jwt.SigningMethodRS256.Alg():
+		pem, err := os.ReadFile(k.PublicKeyFile)
+		if err != nil {
+			return jwtKey{}, fmt.Errorf("read public key: %v", err)
+		}
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return jwtKey{}, fmt.Errorf("parse public key: %v", err)
+		}
+		return jwtKey{kid: k.KID, alg: k.Alg, key: pub}, nil
+	default:
+		return jwtKey{}, fmt.Errorf("unsupported alg %q (supported: HS256, RS256)", k.Alg)
+	}
+}
+
+// Middleware пускает дальше только аутентифицированных клиентов
+// и кладет Principal в контекст запроса
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled {
+			p := Principal{ID: MethodAnonymous, Method: MethodAnonymous, Roles: a.anonymousRoles}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+			return
+		}
+
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="demoserv"`)
+			response.Error(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// RequireRole пускает дальше только клиентов с одной из ролей.
+// Ставится после Middleware
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok || !p.HasRole(roles...) {
+				response.Error(w, r, http.StatusForbidden, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authenticate определяет клиента по заголовкам запроса
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.checkAPIKey(key)
+	}
+
+	scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: missing credentials", errUnauthorized)
+	}
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return a.checkJWT(strings.TrimSpace(credentials))
+	case "apikey":
+		return a.checkAPIKey(strings.TrimSpace(credentials))
+	default:
+		return Principal{}, fmt.Errorf("%w: unsupported authorization scheme", errUnauthorized)
+	}
+}
+
+func (a *Authenticator) checkAPIKey(key string) (Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			return Principal{ID: k.name, Method: MethodAPIKey, Roles: k.roles}, nil
+		}
+	}
+	return Principal{}, fmt.Errorf("%w: invalid api key", errUnauthorized)
+}
+
+func (a *Authenticator) checkJWT(raw string) (Principal, error) {
+	var claims Claims
+	_, err := a.parser.ParseWithClaims(raw, &claims, a.keyFunc)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid token", errUnauthorized)
+	}
+	if claims.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", errUnauthorized)
+	}
+	return Principal{ID: claims.Subject, Method: MethodJWT, Roles: claims.Roles}, nil
+}
+
+// keyFunc выбирает ключ по kid и алгоритму токена. Алгоритм ключа обязан
+// совпадать с алгоритмом токена, иначе RS256 ключ можно выдать за HS256 секрет
+func (a *Authenticator) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	alg := token.Method.Alg()
+
+	var found []jwtKey
+	for _, k := range a.jwtKeys {
+		if k.alg != alg {
+			continue
+		}
+		if kid == "" || k.kid == kid {
+			found = append(found, k)
+		}
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("no unique %s key for kid %q", alg, kid)
+	}
+	return found[0].key, nil
+}
@@ -0,0 +1,221 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const hsSecret = "0123456789abcdef0123456789abcdef"
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pub")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	return path
+}
+
+func newAuthenticator(t *testing.T, rsaKey *rsa.PrivateKey) *auth.Authenticator {
+	t.Helper()
+	a, err := auth.New(models.AuthConfig{
+		Enabled: true,
+		APIKeys: []models.APIKeyConfig{
+			{Name: "support-bot", Hash: hashKey("s3cret"), Roles: []string{"support"}},
+		},
+		JWT: models.JWTConfig{
+			Issuer: "demoserv-test",
+			Keys: []models.JWTKeyConfig{
+				{KID: "hs", Alg: "HS256", Secret: hsSecret},
+				{KID: "rs", Alg: "RS256", PublicKeyFile: writePublicKey(t, rsaKey)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("auth.New: %v", err)
+	}
+	return a
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims auth.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return raw
+}
+
+func validClaims() auth.Claims {
+	return auth.Claims{
+		Roles: []string{"admin"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "demoserv-test",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// serve прогоняет запрос через middleware и возвращает код ответа и клиента
+func serve(a *auth.Authenticator, setup func(r *http.Request)) (int, auth.Principal) {
+	var got auth.Principal
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = auth.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/order/x", nil)
+	setup(req)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr.Code, got
+}
+
+func TestMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	a := newAuthenticator(t, rsaKey)
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+
+	cases := []struct {
+		name     string
+		setup    func(r *http.Request)
+		wantCode int
+		wantID   string
+	}{
+		{"no credentials", func(r *http.Request) {}, http.StatusUnauthorized, ""},
+		{"api key header", func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, "s3cret") }, http.StatusOK, "support-bot"},
+		{"api key scheme", func(r *http.Request) { r.Header.Set("Authorization", "ApiKey s3cret") }, http.StatusOK, "support-bot"},
+		{"wrong api key", func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, "guess") }, http.StatusUnauthorized, ""},
+		{"hs256", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte(hsSecret), validClaims()))
+		}, http.StatusOK, "alice"},
+		{"rs256", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodRS256, "rs", rsaKey, validClaims()))
+		}, http.StatusOK, "alice"},
+		{"expired", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte(hsSecret), expired))
+		}, http.StatusUnauthorized, ""},
+		{"wrong issuer", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte(hsSecret), wrongIssuer))
+		}, http.StatusUnauthorized, ""},
+		{"wrong secret", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte("another-secret-another-secret-xx"), validClaims()))
+		}, http.StatusUnauthorized, ""},
+		{"hs256 token with rs256 kid", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "rs", []byte(hsSecret), validClaims()))
+		}, http.StatusUnauthorized, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, p := serve(a, tc.setup)
+			if code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, code)
+			}
+			if p.ID != tc.wantID {
+				t.Fatalf("expected principal %q, got %q", tc.wantID, p.ID)
+			}
+		})
+	}
+}
+
+func TestMiddleware_RolesInContext(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	a := newAuthenticator(t, rsaKey)
+
+	_, p := serve(a, func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, "s3cret") })
+	if p.Method != auth.MethodAPIKey || !p.HasRole("support") || p.HasRole("admin") {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	a, err := auth.New(models.AuthConfig{})
+	if err != nil {
+		t.Fatalf("auth.New: %v", err)
+	}
+
+	code, p := serve(a, func(r *http.Request) {})
+	if code != http.StatusOK || p.Method != auth.MethodAnonymous {
+		t.Fatalf("expected anonymous access, got %d %+v", code, p)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	a := newAuthenticator(t, rsaKey)
+	h := a.Middleware(auth.RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	cases := map[string]struct {
+		setup    func(r *http.Request)
+		wantCode int
+	}{
+		"support api key": {func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, "s3cret") }, http.StatusForbidden},
+		"admin jwt": {func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte(hsSecret), validClaims()))
+		}, http.StatusOK},
+	}
+	for name, tc := range cases {
+		req := httptest.NewRequest("GET", "/admin/customers/x/export", nil)
+		tc.setup(req)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Fatalf("%s: expected %d, got %d", name, tc.wantCode, rr.Code)
+		}
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cases := map[string]models.AuthConfig{
+		"bad hash":    {APIKeys: []models.APIKeyConfig{{Name: "k", Hash: "plain-text-key"}}},
+		"short hs256": {JWT: models.JWTConfig{Keys: []models.JWTKeyConfig{{KID: "k", Alg: "HS256", Secret: "short"}}}},
+		"missing pem": {JWT: models.JWTConfig{Keys: []models.JWTKeyConfig{{KID: "k", Alg: "RS256", PublicKeyFile: "/nonexistent.pem"}}}},
+		"bad alg":     {JWT: models.JWTConfig{Keys: []models.JWTKeyConfig{{KID: "k", Alg: "none"}}}},
+	}
+	for name, cfg := range cases {
+		if _, err := auth.New(cfg); err == nil {
+			t.Fatalf("%s: expected error, got nil", name)
+		}
+	}
+}
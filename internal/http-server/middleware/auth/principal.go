@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Способы аутентификации
+const (
+	MethodAPIKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+// Principal аутентифицированный клиент
+type Principal struct {
+	ID     string
+	Method string
+	Roles  []string
+}
+
+// HasRole проверяет, есть ли у клиента хотя бы одна из ролей
+func (p Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// String идентификатор клиента для журналов: api_key:support-bot, jwt:alice
+func (p Principal) String() string {
+	return p.Method + ":" + p.ID
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладет клиента в контекст запроса
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext достает клиента из контекста запроса
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
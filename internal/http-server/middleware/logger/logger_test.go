@@ -0,0 +1,34 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mwLogger "demoserv/internal/http-server/middleware/logger"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestMiddleware_LogsRequestWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := middleware.RequestID(mwLogger.New(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	req := httptest.NewRequest("GET", "/order/o-1", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("log line is not json: %v; %s", err, buf.String())
+	}
+	if rec["request_id"] != "req-42" || rec["path"] != "/order/o-1" || rec["status"] != float64(http.StatusTeapot) {
+		t.Fatalf("unexpected record %v", rec)
+	}
+}
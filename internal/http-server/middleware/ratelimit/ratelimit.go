@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/response"
+	"demoserv/internal/models"
+)
+
+// idleTTL через сколько без запросов корзина клиента удаляется
+const idleTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter token bucket на каждого клиента в пределах одной группы маршрутов
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New создает лимитер группы маршрутов. При RPS <= 0 ограничение выключено
+func New(cfg models.RateLimitConfig) *Limiter {
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(cfg.RPS))
+	}
+	return &Limiter{
+		rate:      cfg.RPS,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Middleware отвечает 429 с Retry-After, когда клиент исчерпал лимит.
+// В каждом ответе есть RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset.
+// Должен стоять после аутентификации: клиент определяется по ключу или JWT,
+// а без них — по IP
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return l.middleware(next, clientKey)
+}
+
+// IPMiddleware как Middleware, но клиент всегда определяется по IP.
+// Ставится перед аутентификацией, чтобы перебор ключей и токенов тоже расходовал лимит
+func (l *Limiter) IPMiddleware(next http.Handler) http.Handler {
+	return l.middleware(next, ipKey)
+}
+
+func (l *Limiter) middleware(next http.Handler, key func(*http.Request) string) http.Handler {
+	if l.rate <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, remaining, retryAfter, reset := l.take(key(r), time.Now())
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(int(l.burst)))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+		if !allowed {
+			h.Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			response.Error(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take забирает токен из корзины клиента. Возвращает, разрешен ли запрос,
+// сколько целых токенов осталось, через сколько появится следующий токен
+// и через сколько корзина наполнится полностью
+func (l *Limiter) take(key string, now time.Time) (bool, int, time.Duration, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// пополняем корзину за прошедшее время
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	var retryAfter time.Duration
+	if b.tokens < 1 {
+		retryAfter = l.duration(1 - b.tokens)
+	}
+	return allowed, int(b.tokens), retryAfter, l.duration(l.burst - b.tokens)
+}
+
+// sweep раз в idleTTL удаляет корзины клиентов, которые давно не приходили
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientKey ключ клиента: аутентифицированный клиент или IP
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Method != auth.MethodAnonymous {
+		return p.Method + ":" + p.ID
+	}
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,98 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/middleware/ratelimit"
+	"demoserv/internal/models"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func request(h http.Handler, remoteAddr string, p *auth.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/order/x", nil)
+	req.RemoteAddr = remoteAddr
+	if p != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLimiter_BurstThen429(t *testing.T) {
+	h := ratelimit.New(models.RateLimitConfig{RPS: 0.5, Burst: 2}).Middleware(ok)
+
+	for i := 0; i < 2; i++ {
+		rr := request(h, "10.0.0.1:1000", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rr.Code)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("unexpected RateLimit-Limit %q", rr.Header().Get("RateLimit-Limit"))
+		}
+		if want := strconv.Itoa(1 - i); rr.Header().Get("RateLimit-Remaining") != want {
+			t.Fatalf("request %d: RateLimit-Remaining %q, want %s", i, rr.Header().Get("RateLimit-Remaining"), want)
+		}
+	}
+
+	rr := request(h, "10.0.0.1:1001", nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected Retry-After 2 for 0.5 rps, got %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Header().Get("RateLimit-Reset") != "4" {
+		t.Fatalf("expected RateLimit-Reset 4, got %q", rr.Header().Get("RateLimit-Reset"))
+	}
+
+	// другой IP не затронут
+	if rr := request(h, "10.0.0.2:1000", nil); rr.Code != http.StatusOK {
+		t.Fatalf("other client: expected 200, got %d", rr.Code)
+	}
+}
+
+func TestLimiter_KeyedByPrincipal(t *testing.T) {
+	h := ratelimit.New(models.RateLimitConfig{RPS: 0.1, Burst: 1}).Middleware(ok)
+	bot := &auth.Principal{ID: "bot", Method: auth.MethodAPIKey}
+
+	if rr := request(h, "10.0.0.1:1000", bot); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	// тот же ключ с другого IP делит лимит
+	if rr := request(h, "10.0.0.2:1000", bot); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for same principal, got %d", rr.Code)
+	}
+	// а IP без ключа — нет
+	if rr := request(h, "10.0.0.2:1000", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for anonymous ip, got %d", rr.Code)
+	}
+}
+
+func TestLimiter_IPMiddlewareIgnoresPrincipal(t *testing.T) {
+	h := ratelimit.New(models.RateLimitConfig{RPS: 0.1, Burst: 1}).IPMiddleware(ok)
+
+	if rr := request(h, "10.0.0.1:1000", &auth.Principal{ID: "a", Method: auth.MethodAPIKey}); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	// другой ключ с того же IP делит лимит
+	if rr := request(h, "10.0.0.1:1001", &auth.Principal{ID: "b", Method: auth.MethodAPIKey}); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for same ip, got %d", rr.Code)
+	}
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	h := ratelimit.New(models.RateLimitConfig{}).Middleware(ok)
+
+	for i := 0; i < 100; i++ {
+		rr := request(h, "10.0.0.1:1000", nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("limiter must be disabled, got %d %v", rr.Code, rr.Header())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mwTracing "demoserv/internal/http-server/middleware/tracing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+func TestMiddleware_SpanPerRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := chi.NewRouter()
+	r.Use(mwTracing.New())
+	r.Get("/order/{order_uid}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/order/o-1", nil)
+	req.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /order/{order_uid}" {
+		t.Fatalf("unexpected span name %q", span.Name())
+	}
+	if span.Parent().TraceID().String() != "0102030405060708090a0b0c0d0e0f10" {
+		t.Fatalf("trace context is not taken from traceparent: %v", span.Parent())
+	}
+	if span.Status().Code != codes.Error {
+		t.Fatalf("expected error status for 500, got %v", span.Status())
+	}
+
+	found := false
+	for _, a := range span.Attributes() {
+		if a == semconv.HTTPResponseStatusCode(http.StatusInternalServerError) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("status code attribute is missing: %v", span.Attributes())
+	}
+}
@@ -0,0 +1,52 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// swagger-initializer.js из поставки Swagger UI смотрит на petstore,
+// поэтому подменяем его своим
+//
+//go:embed swagger-initializer.js
+var swaggerInitializer []byte
+
+// DocsPrefix путь, под которым отдается Swagger UI
+const DocsPrefix = "/docs/"
+
+// Spec возвращает OpenAPI документ в исходном виде
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler отдает OpenAPI документ
+func SpecHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// DocsHandler отдает встроенный Swagger UI под DocsPrefix
+func DocsHandler() http.Handler {
+	files := http.StripPrefix(DocsPrefix, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, DocsPrefix) {
+			http.Redirect(w, r, DocsPrefix, http.StatusMovedPermanently)
+			return
+		}
+		if strings.TrimPrefix(r.URL.Path, DocsPrefix) == "swagger-initializer.js" {
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Write(swaggerInitializer)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,18 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// ErrorResponse тело ответа с ошибкой
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Error отправляет ошибку в JSON с нужным статусом
+func Error(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	render.Status(r, status)
+	render.JSON(w, r, ErrorResponse{Error: msg})
+}
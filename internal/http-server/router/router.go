@@ -0,0 +1,174 @@
+package router
+
+import (
+	"demoserv/frontend"
+	"demoserv/internal/audit"
+	"demoserv/internal/cache"
+	"demoserv/internal/config"
+	"demoserv/internal/http-server/handlers/analytics"
+	"demoserv/internal/http-server/handlers/anonymizeCustomer"
+	"demoserv/internal/http-server/handlers/consumerControl"
+	"demoserv/internal/http-server/handlers/consumerStatus"
+	"demoserv/internal/http-server/handlers/customerOrders"
+	"demoserv/internal/http-server/handlers/evictCache"
+	"demoserv/internal/http-server/handlers/exportCustomer"
+	"demoserv/internal/http-server/handlers/getAudit"
+	"demoserv/internal/http-server/handlers/getOrder"
+	"demoserv/internal/http-server/handlers/getSchema"
+	"demoserv/internal/http-server/handlers/health"
+	"demoserv/internal/http-server/handlers/restoreArchive"
+	"demoserv/internal/http-server/handlers/saveOrder"
+	"demoserv/internal/http-server/handlers/searchOrders"
+	"demoserv/internal/http-server/handlers/ui"
+	"demoserv/internal/http-server/middleware/auditlog"
+	"demoserv/internal/http-server/middleware/auth"
+	mwLogger "demoserv/internal/http-server/middleware/logger"
+	"demoserv/internal/http-server/middleware/ratelimit"
+	mwTracing "demoserv/internal/http-server/middleware/tracing"
+	"demoserv/internal/http-server/openapi"
+	"demoserv/internal/kafka"
+	"demoserv/internal/mask"
+	"demoserv/internal/metrics"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"context"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// Группы маршрутов с отдельными лимитами в HTTP_SERVER.RATE_LIMITS.
+// GroupAuth — лимит по IP перед аутентификацией на всех маршрутах, где она нужна
+const (
+	GroupOrders = "orders"
+	GroupPublic = "public"
+	GroupAuth   = "auth"
+)
+
+// Deps зависимости обработчиков HTTP API
+type Deps struct {
+	Cache *cache.Cache
+	// Repo хранилище заказов и данных админских маршрутов
+	Repo storage.Repository
+
+	Authenticator *auth.Authenticator
+	Policy        mask.Policy
+	// AuditLog журнал доступа к заказам и их изменений, nil - журнал выключен
+	AuditLog *audit.Logger
+	// Monitor состояние консьюмера для /readyz, /metrics и /admin/consumer
+	Monitor *kafka.Monitor
+	// Control пауза и сдвиг смещений консьюмера
+	Control *kafka.Control
+}
+
+// New собирает роутер со всеми маршрутами HTTP API.
+// Каждый маршрут должен быть описан в openapi.json
+func New(ctx context.Context, log *slog.Logger, cfg *config.Config, deps Deps) *chi.Mux {
+	router := chi.NewRouter()
+	// Фронтенд отдается с того же адреса, поэтому CORS нужен только
+	// для сторонних источников из конфига. Пустой список — CORS выключен
+	if len(cfg.HttpServer.CorsAllowedOrigins) > 0 {
+		router.Use(cors.Handler(cors.Options{
+			AllowedOrigins:   cfg.HttpServer.CorsAllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", auth.APIKeyHeader, "Content-Type", "X-CSRF-Token"},
+			ExposedHeaders:   []string{"Link"},
+			AllowCredentials: false,
+			MaxAge:           300,
+		}))
+	}
+	router.Use(middleware.RequestID)
+	router.Use(mwTracing.New())
+	router.Use(mwLogger.New(log))
+	router.Use(middleware.Recoverer)
+
+	// Одна корзина клиента на все группы с лимитом orders: заказы, аналитика и админка.
+	// Перед аутентификацией запросы ограничиваются по IP, иначе перебор ключей
+	// и токенов получает 401 раньше, чем тратит лимит
+	ordersLimiter := ratelimit.New(cfg.HttpServer.RateLimits[GroupOrders])
+	authLimiter := ratelimit.New(cfg.HttpServer.RateLimits[GroupAuth])
+
+	// Данные заказов только для аутентифицированных клиентов.
+	// URLFormat отрезает расширение из пути, поэтому только для заказов,
+	// иначе не откроются /schema/order.json и /openapi.json
+	router.Group(func(r chi.Router) {
+		r.Use(authLimiter.IPMiddleware)
+		r.Use(deps.Authenticator.Middleware)
+		r.Use(ordersLimiter.Middleware)
+		r.Use(middleware.URLFormat)
+		r.With(auditlog.New(deps.AuditLog, audit.ActionRead)).
+			Get("/order/{order_uid}", getorder.New(ctx, log, deps.Cache, deps.Repo, deps.Policy))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionRead)).
+			Get("/orders/by-track/{track_number}", getorder.NewByTrackNumber(ctx, log, deps.Cache, deps.Repo, deps.Policy))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionRead)).
+			Get("/orders/by-transaction/{transaction}", getorder.NewByTransaction(ctx, log, deps.Cache, deps.Repo, deps.Policy))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionIngest)).
+			Post("/order", saveorder.New(log, deps.Cache, deps.Repo, cfg.HttpServer.SchemaValidation))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionRead)).
+			Get("/customers/{customer_id}/orders", customerorders.New(log, deps.Repo))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionSearch)).
+			Get("/orders/search", searchorders.New(log, deps.Repo, deps.Policy))
+	})
+
+	// Аналитические отчеты для ролей analyst и admin
+	router.Group(func(r chi.Router) {
+		r.Use(authLimiter.IPMiddleware)
+		r.Use(deps.Authenticator.Middleware)
+		r.Use(auth.RequireRole(mask.RoleAnalyst, mask.RoleAdmin))
+		r.Use(ordersLimiter.Middleware)
+		r.Get("/analytics/sales", analytics.New(log, deps.Repo, models.ReportSales, cfg.Rollups.Analytics))
+		r.Get("/analytics/brands", analytics.New(log, deps.Repo, models.ReportBrands, cfg.Rollups.Analytics))
+		r.Get("/analytics/delivery-services", analytics.New(log, deps.Repo, models.ReportDeliveryServices, cfg.Rollups.Analytics))
+		r.Get("/analytics/banks", analytics.New(log, deps.Repo, models.ReportBanks, cfg.Rollups.Analytics))
+		r.Get("/analytics/providers", analytics.New(log, deps.Repo, models.ReportProviders, cfg.Rollups.Analytics))
+	})
+
+	// Административные операции только для роли admin
+	router.Group(func(r chi.Router) {
+		r.Use(authLimiter.IPMiddleware)
+		r.Use(deps.Authenticator.Middleware)
+		r.Use(auth.RequireRole(mask.RoleAdmin))
+		r.Use(ordersLimiter.Middleware)
+		r.With(auditlog.New(deps.AuditLog, audit.ActionExport)).
+			Get("/admin/customers/{customer_id}/export", exportcustomer.New(log, deps.Repo))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionAnonymize)).
+			Post("/admin/customers/{customer_id}/anonymize", anonymizecustomer.New(log, deps.Cache, deps.Repo))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionRestore)).
+			Post("/admin/archive/restore", restorearchive.New(log, deps.Repo, cfg.Archive))
+		r.With(auditlog.New(deps.AuditLog, audit.ActionEvict)).
+			Post("/admin/cache/evict", evictcache.New(log, deps.Cache))
+		r.Get("/admin/audit", getaudit.New(log, deps.Repo))
+		r.Get("/admin/consumer", consumerstatus.New(deps.Monitor, deps.Control))
+		r.Group(func(r chi.Router) {
+			r.Use(auditlog.New(deps.AuditLog, audit.ActionConsumer))
+			r.Post("/admin/consumer/pause", consumercontrol.NewPause(log, deps.Control))
+			r.Post("/admin/consumer/resume", consumercontrol.NewResume(log, deps.Control))
+			r.Post("/admin/consumer/seek", consumercontrol.NewSeek(log, deps.Control))
+		})
+	})
+
+	// Проверки живости и готовности и метрики без аутентификации и лимитов
+	router.Get("/healthz", health.New())
+	router.Get("/readyz", health.NewReady(deps.Monitor))
+	router.Method("GET", "/metrics", metrics.Handler(metrics.New(deps.Monitor)))
+
+	// Публичные маршруты: схемы, документация, фронтенд
+	router.Group(func(r chi.Router) {
+		r.Use(ratelimit.New(cfg.HttpServer.RateLimits[GroupPublic]).Middleware)
+		r.Get("/schema/order.json", getschema.New())
+
+		// Документация API
+		r.Get("/openapi.json", openapi.SpecHandler())
+		r.Handle("/docs", openapi.DocsHandler())
+		r.Handle(openapi.DocsPrefix+"*", openapi.DocsHandler())
+
+		// Фронтенд
+		r.Get("/ui", ui.New(frontend.FS))
+		r.Get(ui.Prefix+"*", ui.New(frontend.FS))
+	})
+
+	return router
+}
@@ -0,0 +1,308 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demoserv/internal/cache"
+	"demoserv/internal/config"
+	"demoserv/internal/http-server/middleware/auth"
+	"demoserv/internal/http-server/openapi"
+	"demoserv/internal/http-server/router"
+	"demoserv/internal/mask"
+	"demoserv/internal/models"
+	"demoserv/internal/storage/memory"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Статика Swagger UI и фронтенда не является частью API
+var undocumented = map[string]bool{
+	"/docs":   true,
+	"/docs/*": true,
+	"/ui":     true,
+	"/ui/*":   true,
+}
+
+type spec struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var s spec
+	if err := json.Unmarshal(openapi.Spec(), &s); err != nil {
+		t.Fatalf("openapi.json is not valid json: %v", err)
+	}
+	return s
+}
+
+func newRouter() *chi.Mux {
+	return newRouterWithConfig(&config.Config{})
+}
+
+func newRouterWithConfig(cfg *config.Config) *chi.Mux {
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
+	return router.New(context.Background(), slog.New(slog.DiscardHandler), cfg, router.Deps{
+		Cache:         cache.NewCache(10),
+		Repo:          memory.New(),
+		Authenticator: authenticator,
+		Policy:        mask.DefaultPolicy(),
+	})
+}
+
+func TestRoutes_DescribedInOpenAPI(t *testing.T) {
+	s := loadSpec(t)
+
+	err := chi.Walk(newRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if undocumented[route] {
+			return nil
+		}
+		ops, ok := s.Paths[route]
+		if !ok {
+			t.Errorf("route %s %s is not described in openapi.json", method, route)
+			return nil
+		}
+		if _, ok := ops[strings.ToLower(method)]; !ok {
+			t.Errorf("method %s of %s is not described in openapi.json", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+func TestOpenAPI_OperationsAreRouted(t *testing.T) {
+	s := loadSpec(t)
+	r := newRouter()
+
+	for path, ops := range s.Paths {
+		for method := range ops {
+			rctx := chi.NewRouteContext()
+			if !r.Match(rctx, strings.ToUpper(method), path) {
+				t.Errorf("openapi.json describes %s %s, but it is not routed", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPI_RefsResolve(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	components := doc["components"].(map[string]any)
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				group, _ := components[parts[0]].(map[string]any)
+				if len(parts) != 2 || group[parts[1]] == nil {
+					t.Errorf("unresolved $ref %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestDocs_Served(t *testing.T) {
+	r := newRouter()
+
+	for path, want := range map[string]string{
+		"/openapi.json":                `"openapi"`,
+		"/docs/":                       "swagger-ui",
+		"/docs/swagger-initializer.js": "/openapi.json",
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("%s: body does not contain %q", path, want)
+		}
+	}
+}
+
+func TestUI_Served(t *testing.T) {
+	r := newRouter()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/ui/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("index.html must be revalidated, got Cache-Control %q", rr.Header().Get("Cache-Control"))
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/ui/styles/styles.css", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for styles, got %d", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("unexpected content type %q", rr.Header().Get("Content-Type"))
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || !strings.Contains(rr.Header().Get("Cache-Control"), "max-age") {
+		t.Fatalf("expected ETag and max-age, got %v", rr.Header())
+	}
+
+	req := httptest.NewRequest("GET", "/ui/styles/styles.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rr.Code)
+	}
+}
+
+func TestCORS_ConfiguredOrigins(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.HttpServer.CorsAllowedOrigins = []string{"https://allowed.example"}
+	r := newRouterWithConfig(cfg)
+
+	for origin, want := range map[string]string{
+		"https://allowed.example": "https://allowed.example",
+		"null":                    "",
+		"https://evil.example":    "",
+	} {
+		req := httptest.NewRequest("GET", "/openapi.json", nil)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Fatalf("origin %s: expected %q, got %q", origin, want, got)
+		}
+	}
+
+	// фронтенд передает API ключ в заголовке X-API-Key
+	req := httptest.NewRequest("OPTIONS", "/order/x", nil)
+	req.Header.Set("Origin", "https://allowed.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "X-API-Key")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if !strings.Contains(strings.ToLower(rr.Header().Get("Access-Control-Allow-Headers")), "x-api-key") {
+		t.Fatalf("preflight must allow X-API-Key, got %v", rr.Header())
+	}
+}
+
+func TestAuth_ProtectsOrdersOnly(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.Enabled = true
+	r := newRouterWithConfig(cfg)
+
+	for path, want := range map[string]int{
+		"/order/x":                  http.StatusUnauthorized,
+		"/orders/search?q=nike":     http.StatusUnauthorized,
+		"/orders/by-track/x":        http.StatusUnauthorized,
+		"/orders/by-transaction/x":  http.StatusUnauthorized,
+		"/customers/x/orders":       http.StatusUnauthorized,
+		"/analytics/sales":          http.StatusUnauthorized,
+		"/analytics/brands":         http.StatusUnauthorized,
+		"/admin/customers/x/export": http.StatusUnauthorized,
+		"/admin/consumer":           http.StatusUnauthorized,
+		"/healthz":                  http.StatusOK,
+		"/readyz":                   http.StatusOK,
+		"/metrics":                  http.StatusOK,
+		"/openapi.json":             http.StatusOK,
+		"/schema/order.json":        http.StatusOK,
+		"/ui/":                      http.StatusOK,
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+// Группы с лимитом orders расходуют одну корзину клиента
+func TestRateLimit_SharedAcrossOrderGroups(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AnonymousRoles = []string{"admin"}
+	cfg.HttpServer.RateLimits = map[string]models.RateLimitConfig{router.GroupOrders: {RPS: 0.001, Burst: 1}}
+	r := newRouterWithConfig(cfg)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/order/x", nil))
+	if rr.Code == http.StatusTooManyRequests {
+		t.Fatal("first request must not be limited")
+	}
+	for _, path := range []string{"/order/x", "/admin/audit", "/analytics/sales"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected 429, got %d", path, rr.Code)
+		}
+	}
+}
+
+// Неудачные попытки аутентификации тоже расходуют лимит по IP
+func TestRateLimit_BeforeAuth(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.Enabled = true
+	cfg.HttpServer.RateLimits = map[string]models.RateLimitConfig{router.GroupAuth: {RPS: 0.001, Burst: 2}}
+	r := newRouterWithConfig(cfg)
+
+	for i, path := range []string{"/order/x", "/admin/audit", "/analytics/sales"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", "guess")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		want := http.StatusUnauthorized
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func TestAdmin_RequiresAdminRole(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AnonymousRoles = []string{"support"}
+	r := newRouterWithConfig(cfg)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/admin/customers/x/export", nil),
+		httptest.NewRequest("POST", "/admin/customers/x/anonymize", nil),
+		httptest.NewRequest("GET", "/admin/consumer", nil),
+		httptest.NewRequest("POST", "/admin/consumer/pause", nil),
+		httptest.NewRequest("POST", "/admin/consumer/seek", nil),
+		httptest.NewRequest("POST", "/admin/archive/restore", nil),
+		httptest.NewRequest("POST", "/admin/cache/evict", nil),
+		httptest.NewRequest("GET", "/analytics/sales", nil),
+		httptest.NewRequest("GET", "/analytics/delivery-services", nil),
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: expected 403, got %d", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+}
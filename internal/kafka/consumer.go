@@ -1,68 +1,298 @@
 package kafka
 
 import (
+	"demoserv/internal/audit"
 	"demoserv/internal/cache"
 	"demoserv/internal/config"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/message"
 	"demoserv/internal/models"
-	"demoserv/internal/postgress"
+	"demoserv/internal/schema"
+	"demoserv/internal/storage"
+	"demoserv/internal/tracing"
 	"demoserv/internal/validate"
 	
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewConsumer(сtx context.Context, cfg *config.Config, pool *pgxpool.Pool, cache *cache.Cache) {
-	// Подключаемся к брокеру
-	reader := kafka.NewReader(kafka.ReaderConfig{
+var tracer = tracing.Tracer("demoserv/internal/kafka")
+
+// Пауза перед повторным чтением сообщения, которое не удалось записать в базу
+const (
+	minRetryBackoff = time.Second
+	maxRetryBackoff = 30 * time.Second
+)
+
+func NewConsumer(сtx context.Context, cfg *config.Config, repo storage.OrderRepository, cache *cache.Cache, auditLog *audit.Logger, monitor *Monitor, control *Control, log *slog.Logger) {
+	// Подключаемся к брокеру. Уникальный client id нужен,
+	// чтобы найти партиции этого экземпляра среди участников группы
+	newReader := func() *kafka.Reader {
+		return kafka.NewReader(kafka.ReaderConfig{
+			Brokers: []string{cfg.Kafka.KAFKA_BROKER},
+			Topic:   cfg.Kafka.KAFKA_TOPIC,
+			GroupID: cfg.Kafka.KAFKA_GROUP,
+			Dialer: &kafka.Dialer{
+				ClientID:  control.ClientID(),
+				Timeout:   10 * time.Second,
+				DualStack: true,
+			},
+		})
+	}
+	reader := newReader()
+
+	defer func() { reader.Close() }()
+	monitor.attach(reader)
+
+	// reconnect пересоздает читателя: после ребаланса он читает с закоммиченного смещения группы
+	reconnect := func() {
+		if err := reader.Close(); err != nil {
+			log.Warn("unable to close reader", sl.Err(err))
+		}
+		reader = newReader()
+		monitor.attach(reader)
+	}
+
+	// seek выполняется циклом на паузе: смещение коммитится от имени группы,
+	// затем читатель переподключается и после ребаланса читает с нового смещения
+	seek := func(partition int, offset int64) error {
+		err := reader.CommitMessages(сtx, kafka.Message{
+			Topic:     cfg.Kafka.KAFKA_TOPIC,
+			Partition: partition,
+			Offset:    offset - 1,
+		})
+		if err != nil {
+			return fmt.Errorf("commit offset %d of partition %d: %w", offset, partition, err)
+		}
+		reconnect()
+		log.Info("partition offset moved", slog.Int("partition", partition), slog.Int64("offset", offset))
+		return nil
+	}
+
+	// Заказы, которые база не принимает, уходят в топик недоставленных, чтобы не блокировать партицию
+	deadLetters := kafka.NewWriter(kafka.WriterConfig{
 		Brokers: []string{cfg.Kafka.KAFKA_BROKER},
-		Topic:   cfg.Kafka.KAFKA_TOPIC,
-		GroupID: cfg.Kafka.KAFKA_GROUP,
+		Topic:   DeadLetterTopic(cfg.Kafka),
 	})
+	defer deadLetters.Close()
 
-	defer reader.Close()
+	codecs := message.NewCodecs(cfg.Kafka.KAFKA_STRICT_DECODING)
 
+	// Каждое сообщение записывается в журнал доступа как прием заказа
+	logIngest := func(msg kafka.Message, orderUID string, status int) {
+		auditLog.Log(models.AuditEntry{
+			Principal: "kafka:" + cfg.Kafka.KAFKA_GROUP,
+			Action:    audit.ActionIngest,
+			Method:    "CONSUME",
+			Route:     cfg.Kafka.KAFKA_TOPIC,
+			Path:      fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset),
+			OrderUID:  orderUID,
+			Status:    status,
+			SourceIP:  cfg.Kafka.KAFKA_BROKER,
+		})
+	}
 
-	log.Println("listening topic...")
+	log.Info("listening topic", slog.String("topic", cfg.Kafka.KAFKA_TOPIC), slog.String("group", cfg.Kafka.KAFKA_GROUP))
 
-	// Читаем очередь
-	for {
-		msg, err := reader.ReadMessage(сtx)
+	// process обрабатывает одно сообщение: decode → validate → insert → cache.
+	// Каждый шаг — отдельный спан внутри спана сообщения. Возвращает false, если
+	// заказ не записан ни в базу, ни в топик недоставленных: такое сообщение не коммитится и читается снова
+	process := func(ctx context.Context, span trace.Span, msg kafka.Message, msgLog *slog.Logger) bool {
+		// Проверка по JSON Schema
+		checkSchema := func(orderUID string, validate func() error) bool {
+			_, step := tracer.Start(ctx, "order.validate_schema")
+			err := validate()
+			tracing.End(step, err)
+			if err != nil {
+				msgLog.Warn("message rejected by schema", sl.Err(err))
+				monitor.Failed(StageValidate)
+				tracing.SetError(span, err)
+				logIngest(msg, orderUID, http.StatusBadRequest)
+				return false
+			}
+			return true
+		}
+
+		// Формат сообщения по content-type
+		format, err := codecs.Format(headerValue(msg.Headers, message.ContentTypeHeader))
 		if err != nil {
-			log.Printf("unable to read message: %v", err)
-			continue
+			msgLog.Warn("message rejected", sl.Err(err))
+			monitor.Failed(StageDecode)
+			tracing.SetError(span, err)
+			logIngest(msg, "", http.StatusBadRequest)
+			return true
+		}
+		// JSON проверяется по схеме как есть, до декодирования в структуру
+		isJSON := format.ContentType() == message.ContentTypeJSON
+		if cfg.Kafka.KAFKA_SCHEMA_VALIDATION && isJSON {
+			if !checkSchema("", func() error { return schema.Validate(message.Payload(msg.Value)) }) {
+				return true
+			}
 		}
-		fmt.Println("message received")
 
-		// Анмаршалим сообщение
-		var order models.Order
-		if err := json.Unmarshal(msg.Value, &order); err != nil {
-			log.Printf("unable to unmarshal message: %v", err)
-			continue
+		// Декодируем сообщение по версии схемы
+		_, step := tracer.Start(ctx, "message.decode")
+		order, err := format.Unmarshal(headerValue(msg.Headers, message.VersionHeader), msg.Value)
+		tracing.End(step, err)
+		if err != nil {
+			msgLog.Warn("message rejected", sl.Err(err))
+			monitor.Failed(StageDecode)
+			tracing.SetError(span, err)
+			logIngest(msg, "", http.StatusBadRequest)
+			return true
+		}
+
+		msgLog = msgLog.With(slog.String("order_uid", order.OrderUID))
+		span.SetAttributes(attribute.String("order_uid", order.OrderUID))
+
+		// Protobuf и Avro проверяются по схеме после декодирования
+		if cfg.Kafka.KAFKA_SCHEMA_VALIDATION && !isJSON {
+			if !checkSchema(order.OrderUID, func() error { return schema.ValidateOrder(order) }) {
+				return true
+			}
 		}
 
 		// Проверяем валидность каждого поля заказа
-		if err := validate.ValidateOrder(order); err != nil {
-			log.Printf("invalid order data: %v (order_uid: %s)", err, order.OrderUID)
-			continue
+		_, step = tracer.Start(ctx, "order.validate")
+		err = validate.ValidateOrder(order)
+		tracing.End(step, err)
+		if err != nil {
+			msgLog.Warn("invalid order data", sl.Err(err))
+			monitor.Failed(StageValidate)
+			tracing.SetError(span, err)
+			logIngest(msg, order.OrderUID, http.StatusBadRequest)
+			return true
 		}
 
 		// Вставка в базу
-		if err := postgress.InsertOrder(сtx, pool, &order); err != nil {
-			log.Printf("unable to insert order: %v", err)
-			continue
+		insertCtx, step := tracer.Start(ctx, "storage.InsertOrder")
+		err = repo.InsertOrder(insertCtx, &order)
+		tracing.End(step, err)
+		// база не принимает заказ: повтор не поможет, сообщение уходит в топик недоставленных
+		if errors.Is(err, storage.ErrRejected) {
+			msgLog.Error("order rejected by storage", sl.Err(err))
+			monitor.Failed(StageInsert)
+			tracing.SetError(span, err)
+			logIngest(msg, order.OrderUID, http.StatusUnprocessableEntity)
+
+			dlCtx, step := tracer.Start(ctx, "kafka.dead_letter")
+			err = deadLetters.WriteMessages(dlCtx, DeadLetter(msg, err))
+			tracing.End(step, err)
+			if err != nil {
+				msgLog.Error("unable to write dead letter", sl.Err(err))
+				monitor.Failed(StageDeadLetter)
+				return false
+			}
+			msgLog.Warn("message moved to dead letter topic", slog.String("dead_letter_topic", deadLetters.Topic))
+			return true
+		}
+		// остальные ошибки временные (соединение, таймаут): сообщение читается снова
+		if err != nil {
+			msgLog.Error("unable to insert order", sl.Err(err))
+			monitor.Failed(StageInsert)
+			tracing.SetError(span, err)
+			logIngest(msg, order.OrderUID, http.StatusInternalServerError)
+			return false
 		}
 
+		_, step = tracer.Start(ctx, "cache.Add")
 		cache.Add(order) // добавляем в кэш
+		step.End()
+		logIngest(msg, order.OrderUID, http.StatusCreated)
+
+		msgLog.Info("order processed")
+		return true
+	}
 
+	// Читаем очередь
+	backoff := minRetryBackoff
+	for {
+		// на паузе цикл стоит здесь, читатель остается в группе
+		if control.Paused() {
+			monitor.SetPaused(true)
+			log.Info("consumer paused")
+			if !control.Wait(сtx, seek) {
+				return
+			}
+			monitor.SetPaused(false)
+			log.Info("consumer resumed")
+		}
+
+		// FetchMessage не коммитит сам, поэтому пауза не может
+		// прервать чтение между получением сообщения и коммитом
+		readCtx, cancel := control.ReadContext(сtx)
+		msg, err := reader.FetchMessage(readCtx)
+		cancel()
+		if err != nil {
+			if сtx.Err() != nil {
+				return
+			}
+			// чтение прервано паузой
+			if control.Paused() {
+				continue
+			}
+			log.Error("unable to read message", sl.Err(err))
+			monitor.Failed(StageRead)
+			continue
+		}
+		monitor.Received()
+		// у всех строк лога сообщения есть партиция и смещение, после декодирования — order_uid
+		msgLog := log.With(slog.Int("partition", msg.Partition), slog.Int64("offset", msg.Offset))
+		msgLog.Debug("message received")
+
+		// спан сообщения продолжает трассу продюсера из заголовков
+		ctx, span := tracer.Start(tracing.ExtractKafka(сtx, msg), "kafka.consume "+msg.Topic,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKafka,
+				semconv.MessagingOperationTypeReceive,
+				semconv.MessagingDestinationName(msg.Topic),
+				semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+				semconv.MessagingKafkaOffset(int(msg.Offset)),
+			),
+		)
+		if !process(ctx, span, msg, msgLog) {
+			span.End()
+			// база или топик недоставленных недоступны: смещение не коммитится, читатель возвращается
+			// к закоммиченному смещению и после паузы читает сообщение снова
+			msgLog.Warn("message will be read again", slog.Duration("backoff", backoff))
+			select {
+			case <-сtx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxRetryBackoff)
+			reconnect()
+			continue
+		}
+		backoff = minRetryBackoff
+
+		// коммитим обработанные, отклоненные и недоставленные сообщения: при повторе они отклонились бы снова
 		if err := reader.CommitMessages(сtx, msg); err != nil {
-			log.Printf("unable to commit message: %v", err)
+			msgLog.Error("unable to commit message", sl.Err(err))
+			tracing.SetError(span, err)
+			monitor.Failed(StageCommit)
 		}
+		span.End()
+	}
+}
 
-		fmt.Printf("Processed order: %s\n", order.OrderUID)
+// headerValue возвращает значение заголовка сообщения или пустую строку
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
 	}
+	return ""
 }
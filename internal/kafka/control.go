@@ -0,0 +1,309 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"demoserv/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Состояния цикла консьюмера
+const (
+	StateRunning = "running"
+	StatePausing = "pausing"
+	StatePaused  = "paused"
+)
+
+var (
+	// ErrNotPaused смещение можно сдвинуть только на паузе
+	ErrNotPaused = errors.New("consumer is not paused")
+	// ErrNoConsumer консьюмер не запущен
+	ErrNoConsumer = errors.New("consumer is not running")
+)
+
+// MembersFunc возвращает участников группы консьюмеров и их партиции
+type MembersFunc func(ctx context.Context) ([]models.GroupMember, error)
+
+// Control управляет циклом консьюмера из других горутин.
+// Цикл сам выполняет все операции с читателем между сообщениями,
+// поэтому пауза и сдвиг смещения не пересекаются с обработкой.
+// На паузе читатель не закрывается и продолжает отправлять heartbeat,
+// консьюмер остается в группе
+type Control struct {
+	clientID string
+	members  MembersFunc
+
+	mu         sync.Mutex
+	paused     bool
+	parked     bool // цикл стоит на паузе, сообщений в обработке нет
+	since      time.Time
+	by         string
+	changed    chan struct{} // закрывается при каждой смене состояния
+	cancelRead context.CancelFunc
+	seeks      chan seekRequest
+}
+
+type seekRequest struct {
+	partition int
+	offset    int64
+	done      chan error
+}
+
+// SeekFunc сдвигает смещение группы в партиции. Вызывается циклом консьюмера
+type SeekFunc func(partition int, offset int64) error
+
+// NewControl создает управление консьюмером. clientID читателя
+// уникален для процесса, по нему экземпляр находит свои партиции в группе
+func NewControl(members MembersFunc) *Control {
+	host, _ := os.Hostname()
+	return &Control{
+		clientID: fmt.Sprintf("demoserv-%s-%d", host, os.Getpid()),
+		members:  members,
+		changed:  make(chan struct{}),
+		seeks:    make(chan seekRequest),
+	}
+}
+
+// GroupMembers читает участников группы у брокера
+func GroupMembers(cfg models.KafkaConfig) MembersFunc {
+	client := &kafka.Client{Addr: kafka.TCP(cfg.KAFKA_BROKER), Timeout: 10 * time.Second}
+	topic, group := cfg.KAFKA_TOPIC, cfg.KAFKA_GROUP
+
+	return func(ctx context.Context) ([]models.GroupMember, error) {
+		resp, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{group}})
+		if err != nil {
+			return nil, fmt.Errorf("describe group: %w", err)
+		}
+		var members []models.GroupMember
+		for _, g := range resp.Groups {
+			if g.Error != nil {
+				return nil, fmt.Errorf("describe group %s: %w", g.GroupID, g.Error)
+			}
+			for _, m := range g.Members {
+				member := models.GroupMember{
+					MemberID:   m.MemberID,
+					ClientID:   m.ClientID,
+					Host:       m.ClientHost,
+					Partitions: []int{},
+				}
+				for _, t := range m.MemberAssignments.Topics {
+					if t.Topic == topic {
+						member.Partitions = append(member.Partitions, t.Partitions...)
+					}
+				}
+				sort.Ints(member.Partitions)
+				members = append(members, member)
+			}
+		}
+		return members, nil
+	}
+}
+
+// ClientID id клиента читателя
+func (c *Control) ClientID() string {
+	if c == nil {
+		return ""
+	}
+	return c.clientID
+}
+
+// Pause останавливает чтение и ждет, пока цикл дообработает текущее сообщение.
+// Если ctx отменен раньше, пауза остается в силе и наступит после этого сообщения
+func (c *Control) Pause(ctx context.Context, by string) error {
+	if c == nil {
+		return ErrNoConsumer
+	}
+	c.mu.Lock()
+	if !c.paused {
+		c.paused = true
+		c.since = time.Now()
+		c.by = by
+		// прерываем ожидание следующего сообщения
+		if c.cancelRead != nil {
+			c.cancelRead()
+		}
+		c.notify()
+	}
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		parked, changed := c.parked, c.changed
+		c.mu.Unlock()
+		if parked {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Resume продолжает чтение
+func (c *Control) Resume(by string) error {
+	if c == nil {
+		return ErrNoConsumer
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.paused = false
+		c.since = time.Now()
+		c.by = by
+		c.notify()
+	}
+	return nil
+}
+
+// Seek сдвигает смещение группы в партиции. Консьюмер должен стоять на паузе,
+// после продолжения чтение начнется с offset
+func (c *Control) Seek(ctx context.Context, partition int, offset int64) error {
+	if c == nil {
+		return ErrNoConsumer
+	}
+	c.mu.Lock()
+	parked := c.parked
+	c.mu.Unlock()
+	if !parked {
+		return ErrNotPaused
+	}
+
+	req := seekRequest{partition: partition, offset: offset, done: make(chan error, 1)}
+	select {
+	case c.seeks <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Snapshot состояние цикла без запроса к брокеру
+func (c *Control) Snapshot() models.ConsumerState {
+	if c == nil {
+		return models.ConsumerState{Status: StateRunning, Assignments: []int{}, Members: []models.GroupMember{}}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := models.ConsumerState{
+		Status:      StateRunning,
+		By:          c.by,
+		ClientID:    c.clientID,
+		Assignments: []int{},
+		Members:     []models.GroupMember{},
+	}
+	switch {
+	case c.parked:
+		state.Status = StatePaused
+	case c.paused:
+		state.Status = StatePausing
+	}
+	if !c.since.IsZero() {
+		t := c.since.UTC()
+		state.Since = &t
+	}
+	return state
+}
+
+// State состояние цикла и партиции, назначенные этому экземпляру
+func (c *Control) State(ctx context.Context) models.ConsumerState {
+	state := c.Snapshot()
+	if c == nil || c.members == nil {
+		return state
+	}
+	members, err := c.members(ctx)
+	if err != nil {
+		state.MembersError = err.Error()
+		return state
+	}
+	for _, m := range members {
+		if m.ClientID == c.clientID {
+			m.Self = true
+			state.Assignments = append(state.Assignments, m.Partitions...)
+		}
+		state.Members = append(state.Members, m)
+	}
+	return state
+}
+
+// Paused запрошена ли пауза
+func (c *Control) Paused() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// ReadContext контекст ожидания следующего сообщения для цикла консьюмера, Pause его отменяет
+func (c *Control) ReadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c == nil {
+		return ctx, func() {}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	readCtx, cancel := context.WithCancel(ctx)
+	c.cancelRead = cancel
+	if c.paused {
+		cancel()
+	}
+	return readCtx, cancel
+}
+
+// Wait держит цикл консьюмера, пока он на паузе, и выполняет сдвиги смещений.
+// Возвращает false, если ctx отменен
+func (c *Control) Wait(ctx context.Context, seek SeekFunc) bool {
+	if c == nil {
+		return ctx.Err() == nil
+	}
+	defer func() {
+		c.mu.Lock()
+		if c.parked {
+			c.parked = false
+			c.notify()
+		}
+		c.mu.Unlock()
+	}()
+	for {
+		c.mu.Lock()
+		if !c.paused {
+			c.mu.Unlock()
+			return true
+		}
+		if !c.parked {
+			c.parked = true
+			c.notify()
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case req := <-c.seeks:
+			req.done <- seek(req.partition, req.offset)
+		case <-changed:
+		}
+	}
+}
+
+// notify будит всех, кто ждет смены состояния. Вызывается под mu
+func (c *Control) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
@@ -0,0 +1,182 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"demoserv/internal/kafka"
+	"demoserv/internal/models"
+)
+
+type seekCall struct {
+	partition int
+	offset    int64
+}
+
+// startLoop запускает цикл, устроенный как цикл консьюмера:
+// пауза между сообщениями, ожидание сообщения с ReadContext
+func startLoop(t *testing.T, c *kafka.Control, msgs <-chan func()) <-chan seekCall {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	seeks := make(chan seekCall, 1)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	go func() {
+		defer close(done)
+		for {
+			if c.Paused() {
+				if !c.Wait(ctx, func(partition int, offset int64) error {
+					seeks <- seekCall{partition, offset}
+					return nil
+				}) {
+					return
+				}
+			}
+			readCtx, cancelRead := c.ReadContext(ctx)
+			select {
+			case <-readCtx.Done():
+				cancelRead()
+				if ctx.Err() != nil {
+					return
+				}
+			case handle := <-msgs:
+				cancelRead()
+				handle()
+			}
+		}
+	}()
+	return seeks
+}
+
+func waitStatus(t *testing.T, c *kafka.Control, status string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.Snapshot().Status != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s, got %s", status, c.Snapshot().Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestControl_PauseInterruptsIdleRead(t *testing.T) {
+	c := kafka.NewControl(nil)
+	startLoop(t, c, make(chan func()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Pause(ctx, "api_key:ops"); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	s := c.Snapshot()
+	if s.Status != kafka.StatePaused || s.By != "api_key:ops" || s.Since == nil {
+		t.Fatalf("unexpected state %+v", s)
+	}
+
+	c.Resume("api_key:ops")
+	waitStatus(t, c, kafka.StateRunning)
+}
+
+func TestControl_PauseWaitsForMessage(t *testing.T) {
+	c := kafka.NewControl(nil)
+	msgs := make(chan func())
+	startLoop(t, c, msgs)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	msgs <- func() {
+		close(started)
+		<-release
+	}
+	<-started
+
+	paused := make(chan error, 1)
+	go func() { paused <- c.Pause(context.Background(), "jwt:alice") }()
+
+	waitStatus(t, c, kafka.StatePausing)
+	select {
+	case <-paused:
+		t.Fatalf("Pause returned while a message is processed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-paused; err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if c.Snapshot().Status != kafka.StatePaused {
+		t.Fatalf("expected paused")
+	}
+}
+
+func TestControl_PauseTimeout(t *testing.T) {
+	// цикл не запущен — пауза не наступает, но остается запрошенной
+	c := kafka.NewControl(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Pause(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if c.Snapshot().Status != kafka.StatePausing {
+		t.Fatalf("expected pausing")
+	}
+}
+
+func TestControl_SeekOnlyWhenPaused(t *testing.T) {
+	c := kafka.NewControl(nil)
+	seeks := startLoop(t, c, make(chan func()))
+	ctx := context.Background()
+
+	if err := c.Seek(ctx, 0, 10); !errors.Is(err, kafka.ErrNotPaused) {
+		t.Fatalf("expected ErrNotPaused, got %v", err)
+	}
+
+	if err := c.Pause(ctx, "x"); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := c.Seek(ctx, 2, 42); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if got := <-seeks; got != (seekCall{2, 42}) {
+		t.Fatalf("unexpected seek %+v", got)
+	}
+	if c.Snapshot().Status != kafka.StatePaused {
+		t.Fatalf("seek must not resume the consumer")
+	}
+}
+
+func TestControl_StateAssignments(t *testing.T) {
+	var c *kafka.Control
+	c = kafka.NewControl(func(context.Context) ([]models.GroupMember, error) {
+		return []models.GroupMember{
+			{MemberID: "m-1", ClientID: "other", Partitions: []int{0}},
+			{MemberID: "m-2", ClientID: c.ClientID(), Partitions: []int{1, 2}},
+		}, nil
+	})
+
+	s := c.State(context.Background())
+	if len(s.Members) != 2 || !s.Members[1].Self || s.Members[0].Self {
+		t.Fatalf("unexpected members %+v", s.Members)
+	}
+	if len(s.Assignments) != 2 || s.Assignments[0] != 1 || s.Assignments[1] != 2 {
+		t.Fatalf("unexpected assignments %v", s.Assignments)
+	}
+}
+
+func TestControl_Nil(t *testing.T) {
+	var c *kafka.Control
+	if err := c.Pause(context.Background(), "x"); !errors.Is(err, kafka.ErrNoConsumer) {
+		t.Fatalf("expected ErrNoConsumer, got %v", err)
+	}
+	if err := c.Seek(context.Background(), 0, 0); !errors.Is(err, kafka.ErrNoConsumer) {
+		t.Fatalf("expected ErrNoConsumer, got %v", err)
+	}
+	if s := c.State(context.Background()); s.Status != kafka.StateRunning {
+		t.Fatalf("unexpected state %+v", s)
+	}
+}
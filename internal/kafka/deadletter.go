@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"strconv"
+
+	"demoserv/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которые консьюмер добавляет к сообщению в топике недоставленных
+const (
+	DeadLetterErrorHeader     = "dead-letter-error"
+	DeadLetterTopicHeader     = "dead-letter-topic"
+	DeadLetterPartitionHeader = "dead-letter-partition"
+	DeadLetterOffsetHeader    = "dead-letter-offset"
+)
+
+// DeadLetterTopic топик недоставленных сообщений: KAFKA_DEAD_LETTER_TOPIC или <KAFKA_TOPIC>.dlq
+func DeadLetterTopic(cfg models.KafkaConfig) string {
+	if cfg.KAFKA_DEAD_LETTER_TOPIC != "" {
+		return cfg.KAFKA_DEAD_LETTER_TOPIC
+	}
+	return cfg.KAFKA_TOPIC + ".dlq"
+}
+
+// DeadLetter копия сообщения для топика недоставленных: исходные ключ, значение
+// и заголовки, причина отказа и место сообщения в исходном топике
+func DeadLetter(msg kafka.Message, cause error) kafka.Message {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(msg.Topic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	return kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
@@ -0,0 +1,60 @@
+package kafka_test
+
+import (
+	"errors"
+	"testing"
+
+	"demoserv/internal/kafka"
+	"demoserv/internal/models"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterTopic(t *testing.T) {
+	if got := kafka.DeadLetterTopic(models.KafkaConfig{KAFKA_TOPIC: "orders"}); got != "orders.dlq" {
+		t.Fatalf("expected default orders.dlq, got %s", got)
+	}
+	cfg := models.KafkaConfig{KAFKA_TOPIC: "orders", KAFKA_DEAD_LETTER_TOPIC: "rejected"}
+	if got := kafka.DeadLetterTopic(cfg); got != "rejected" {
+		t.Fatalf("expected configured topic, got %s", got)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	msg := kafkago.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("k"),
+		Value:     []byte(`{"order_uid":"x"}`),
+		Headers:   []kafkago.Header{{Key: "content-type", Value: []byte("application/json")}},
+	}
+	dl := kafka.DeadLetter(msg, errors.New("order rejected by storage"))
+
+	if string(dl.Key) != "k" || string(dl.Value) != string(msg.Value) {
+		t.Fatalf("key and value must be kept, got %q %q", dl.Key, dl.Value)
+	}
+	// топик и партицию выбирает писатель топика недоставленных
+	if dl.Topic != "" || dl.Partition != 0 || dl.Offset != 0 {
+		t.Fatalf("expected no position, got %s/%d/%d", dl.Topic, dl.Partition, dl.Offset)
+	}
+	want := map[string]string{
+		"content-type":                  "application/json",
+		kafka.DeadLetterErrorHeader:     "order rejected by storage",
+		kafka.DeadLetterTopicHeader:     "orders",
+		kafka.DeadLetterPartitionHeader: "2",
+		kafka.DeadLetterOffsetHeader:    "42",
+	}
+	if len(dl.Headers) != len(want) {
+		t.Fatalf("expected %d headers, got %+v", len(want), dl.Headers)
+	}
+	for _, h := range dl.Headers {
+		if want[h.Key] != string(h.Value) {
+			t.Fatalf("header %s: got %q want %q", h.Key, h.Value, want[h.Key])
+		}
+	}
+	// заголовки исходного сообщения не меняются
+	if len(msg.Headers) != 1 {
+		t.Fatalf("source headers changed: %+v", msg.Headers)
+	}
+}
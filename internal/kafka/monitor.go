@@ -0,0 +1,321 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Этапы обработки сообщения, на которых считаются ошибки
+const (
+	StageRead       = "read"
+	StageDecode     = "decode"
+	StageValidate   = "validate"
+	StageInsert     = "insert"
+	StageCommit     = "commit"
+	StageDeadLetter = "dead_letter"
+)
+
+// OffsetsFunc возвращает смещения группы по партициям топика:
+// последнее закоммиченное (-1, если коммитов не было) и high water mark
+type OffsetsFunc func(ctx context.Context) ([]models.PartitionStatus, error)
+
+// Monitor следит за консьюмером: отставанием группы, ошибками и прогрессом.
+// Если при ненулевом отставании нет прогресса дольше KAFKA_STALL_TIMEOUT,
+// консьюмер считается зависшим и сервис перестает быть готовым.
+// nil *Monitor ничего не отслеживает и всегда готов
+type Monitor struct {
+	topic         string
+	group         string
+	stallTimeout  time.Duration
+	checkInterval time.Duration
+	offsets       OffsetsFunc
+	log           *slog.Logger
+
+	mu           sync.RWMutex
+	stats        func() kafka.ReaderStats
+	partitions   []models.PartitionStatus
+	lag          int64
+	lastMessage  time.Time
+	lastProgress time.Time
+	lastCheck    time.Time
+	checkErr     error
+	errors       map[string]int64
+	reader       models.ReaderStats
+	stalled      bool
+	paused       bool
+}
+
+// NewMonitor создает монитор. Отставание проверяется после запуска Run
+func NewMonitor(cfg models.KafkaConfig, offsets OffsetsFunc, log *slog.Logger) *Monitor {
+	if cfg.KAFKA_STALL_TIMEOUT <= 0 {
+		cfg.KAFKA_STALL_TIMEOUT = 2 * time.Minute
+	}
+	if cfg.KAFKA_LAG_CHECK_INTERVAL <= 0 {
+		cfg.KAFKA_LAG_CHECK_INTERVAL = 15 * time.Second
+	}
+	return &Monitor{
+		topic:         cfg.KAFKA_TOPIC,
+		group:         cfg.KAFKA_GROUP,
+		stallTimeout:  cfg.KAFKA_STALL_TIMEOUT,
+		checkInterval: cfg.KAFKA_LAG_CHECK_INTERVAL,
+		offsets:       offsets,
+		log:           log,
+		lastProgress:  time.Now(),
+		errors:        make(map[string]int64),
+	}
+}
+
+// GroupOffsets читает смещения группы и high water mark партиций у брокера
+func GroupOffsets(cfg models.KafkaConfig) OffsetsFunc {
+	client := &kafka.Client{Addr: kafka.TCP(cfg.KAFKA_BROKER), Timeout: 10 * time.Second}
+	topic, group := cfg.KAFKA_TOPIC, cfg.KAFKA_GROUP
+
+	return func(ctx context.Context) ([]models.PartitionStatus, error) {
+		meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+		if err != nil {
+			return nil, fmt.Errorf("metadata: %w", err)
+		}
+		if len(meta.Topics) == 0 {
+			return nil, fmt.Errorf("topic %s not found", topic)
+		}
+		if err := meta.Topics[0].Error; err != nil {
+			return nil, fmt.Errorf("metadata of %s: %w", topic, err)
+		}
+		var ids []int
+		var last []kafka.OffsetRequest
+		for _, p := range meta.Topics[0].Partitions {
+			ids = append(ids, p.ID)
+			last = append(last, kafka.LastOffsetOf(p.ID))
+		}
+
+		listed, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+			Topics: map[string][]kafka.OffsetRequest{topic: last},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list offsets: %w", err)
+		}
+		committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+			GroupID: group,
+			Topics:  map[string][]int{topic: ids},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetch group offsets: %w", err)
+		}
+		if committed.Error != nil {
+			return nil, fmt.Errorf("fetch group offsets: %w", committed.Error)
+		}
+
+		byPartition := make(map[int]*models.PartitionStatus, len(ids))
+		result := make([]models.PartitionStatus, len(ids))
+		for i, id := range ids {
+			result[i] = models.PartitionStatus{Partition: id, CommittedOffset: -1}
+			byPartition[id] = &result[i]
+		}
+		for _, p := range listed.Topics[topic] {
+			if p.Error != nil {
+				return nil, fmt.Errorf("offsets of partition %d: %w", p.Partition, p.Error)
+			}
+			if s, ok := byPartition[p.Partition]; ok {
+				s.HighWaterMark = p.LastOffset
+			}
+		}
+		for _, p := range committed.Topics[topic] {
+			if p.Error != nil {
+				return nil, fmt.Errorf("group offset of partition %d: %w", p.Partition, p.Error)
+			}
+			if s, ok := byPartition[p.Partition]; ok {
+				s.CommittedOffset = p.CommittedOffset
+			}
+		}
+		return result, nil
+	}
+}
+
+// attach подключает счетчики читателя
+func (m *Monitor) attach(r *kafka.Reader) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = r.Stats
+}
+
+// Received отмечает, что консьюмер прочитал сообщение
+func (m *Monitor) Received() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastMessage = time.Now()
+	m.lastProgress = m.lastMessage
+}
+
+// SetPaused отмечает паузу консьюмера. На паузе отставание растет,
+// но зависанием это не считается
+func (m *Monitor) SetPaused(paused bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = paused
+	m.lastProgress = time.Now()
+}
+
+// Failed считает ошибку на этапе stage
+func (m *Monitor) Failed(stage string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[stage]++
+}
+
+// Check обновляет отставание и счетчики читателя и проверяет, не завис ли консьюмер.
+// Если смещения получить не удалось, решение принимается по последнему известному отставанию
+func (m *Monitor) Check(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	partitions, err := m.offsets(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.lastCheck = now
+	m.checkErr = err
+
+	if m.stats != nil {
+		// Stats возвращает счетчики с прошлого вызова
+		s := m.stats()
+		m.reader.Messages += s.Messages
+		m.reader.Fetches += s.Fetches
+		m.reader.Dials += s.Dials
+		m.reader.Errors += s.Errors
+		m.reader.Timeouts += s.Timeouts
+		m.reader.Rebalances += s.Rebalances
+	}
+
+	if err == nil {
+		// коммиты группы сдвинулись — прогресс, даже если сообщения читал другой экземпляр
+		if advanced(m.partitions, partitions) {
+			m.lastProgress = now
+		}
+		m.lag = 0
+		for i := range partitions {
+			partitions[i].Lag = partitionLag(partitions[i])
+			m.lag += partitions[i].Lag
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i].Partition < partitions[j].Partition })
+		m.partitions = partitions
+	}
+
+	if m.paused {
+		m.lastProgress = now
+	}
+	stalled := m.lag > 0 && now.Sub(m.lastProgress) > m.stallTimeout
+	if stalled != m.stalled {
+		if stalled {
+			m.log.Warn("consumer stalled",
+				slog.Int64("lag", m.lag),
+				slog.Duration("no_progress_for", now.Sub(m.lastProgress)))
+		} else {
+			m.log.Info("consumer recovered", slog.Int64("lag", m.lag))
+		}
+	}
+	m.stalled = stalled
+	return err
+}
+
+// Run проверяет консьюмер раз в KAFKA_LAG_CHECK_INTERVAL до отмены ctx
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			m.log.Warn("unable to check consumer lag", sl.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready готов ли сервис: консьюмер не завис
+func (m *Monitor) Ready() bool {
+	if m == nil {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !m.stalled
+}
+
+// Status текущее состояние консьюмера
+func (m *Monitor) Status() models.ConsumerStatus {
+	if m == nil {
+		return models.ConsumerStatus{Partitions: []models.PartitionStatus{}, Errors: map[string]int64{}}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := models.ConsumerStatus{
+		Topic:          m.topic,
+		Group:          m.group,
+		Stalled:        m.stalled,
+		Paused:         m.paused,
+		Lag:            m.lag,
+		Partitions:     append([]models.PartitionStatus{}, m.partitions...),
+		LastProgressAt: m.lastProgress.UTC(),
+		Errors:         maps.Clone(m.errors),
+		Reader:         m.reader,
+	}
+	if !m.lastMessage.IsZero() {
+		t := m.lastMessage.UTC()
+		status.LastMessageAt = &t
+	}
+	if !m.lastCheck.IsZero() {
+		t := m.lastCheck.UTC()
+		status.LastCheckAt = &t
+	}
+	if m.checkErr != nil {
+		status.CheckError = m.checkErr.Error()
+	}
+	return status
+}
+
+// partitionLag сколько сообщений группа еще не обработала. Без коммитов
+// группа читает партицию с начала, поэтому отставание считается от нуля
+func partitionLag(p models.PartitionStatus) int64 {
+	committed := max(p.CommittedOffset, 0)
+	return max(p.HighWaterMark-committed, 0)
+}
+
+// advanced сдвинулся ли коммит хотя бы одной партиции
+func advanced(prev, cur []models.PartitionStatus) bool {
+	committed := make(map[int]int64, len(prev))
+	for _, p := range prev {
+		committed[p.Partition] = p.CommittedOffset
+	}
+	for _, p := range cur {
+		if old, ok := committed[p.Partition]; ok && p.CommittedOffset > old {
+			return true
+		}
+	}
+	return false
+}
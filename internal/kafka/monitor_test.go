@@ -0,0 +1,143 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"demoserv/internal/kafka"
+	"demoserv/internal/models"
+)
+
+// fakeOffsets возвращает смещения, которые тест меняет между проверками
+type fakeOffsets struct {
+	partitions []models.PartitionStatus
+	err        error
+}
+
+func (f *fakeOffsets) get(context.Context) ([]models.PartitionStatus, error) {
+	return append([]models.PartitionStatus(nil), f.partitions...), f.err
+}
+
+func newMonitor(offsets *fakeOffsets) *kafka.Monitor {
+	return kafka.NewMonitor(models.KafkaConfig{
+		KAFKA_TOPIC:         "orders",
+		KAFKA_GROUP:         "group",
+		KAFKA_STALL_TIMEOUT: 20 * time.Millisecond,
+	}, offsets.get, slog.New(slog.DiscardHandler))
+}
+
+func TestMonitor_LagPerPartition(t *testing.T) {
+	offsets := &fakeOffsets{partitions: []models.PartitionStatus{
+		{Partition: 1, CommittedOffset: -1, HighWaterMark: 3},
+		{Partition: 0, CommittedOffset: 5, HighWaterMark: 12},
+	}}
+	m := newMonitor(offsets)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	s := m.Status()
+	if s.Lag != 10 || len(s.Partitions) != 2 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if s.Partitions[0].Partition != 0 || s.Partitions[0].Lag != 7 || s.Partitions[1].Lag != 3 {
+		t.Fatalf("unexpected partitions %+v", s.Partitions)
+	}
+}
+
+func TestMonitor_StallDetection(t *testing.T) {
+	offsets := &fakeOffsets{partitions: []models.PartitionStatus{{Partition: 0, CommittedOffset: 5, HighWaterMark: 8}}}
+	m := newMonitor(offsets)
+	ctx := context.Background()
+
+	m.Check(ctx)
+	if !m.Ready() {
+		t.Fatalf("must be ready before stall timeout")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	m.Check(ctx)
+	if m.Ready() || !m.Status().Stalled {
+		t.Fatalf("expected stalled: lag without progress")
+	}
+
+	// коммит группы сдвинулся — прогресс
+	offsets.partitions[0].CommittedOffset = 6
+	m.Check(ctx)
+	if !m.Ready() {
+		t.Fatalf("expected ready after commit advanced")
+	}
+
+	// без отставания простой не считается зависанием
+	time.Sleep(30 * time.Millisecond)
+	offsets.partitions[0].CommittedOffset = 8
+	time.Sleep(30 * time.Millisecond)
+	m.Check(ctx)
+	if !m.Ready() {
+		t.Fatalf("idle consumer without lag must be ready")
+	}
+}
+
+func TestMonitor_ReceivedIsProgress(t *testing.T) {
+	offsets := &fakeOffsets{partitions: []models.PartitionStatus{{Partition: 0, CommittedOffset: 0, HighWaterMark: 4}}}
+	m := newMonitor(offsets)
+	ctx := context.Background()
+
+	time.Sleep(30 * time.Millisecond)
+	m.Check(ctx)
+	if m.Ready() {
+		t.Fatalf("expected stalled")
+	}
+
+	m.Received()
+	m.Check(ctx)
+	if !m.Ready() {
+		t.Fatalf("expected ready after message received")
+	}
+	if m.Status().LastMessageAt == nil {
+		t.Fatalf("last message time is not set")
+	}
+}
+
+func TestMonitor_CheckErrorKeepsLastLag(t *testing.T) {
+	offsets := &fakeOffsets{partitions: []models.PartitionStatus{{Partition: 0, CommittedOffset: 1, HighWaterMark: 2}}}
+	m := newMonitor(offsets)
+	ctx := context.Background()
+	m.Check(ctx)
+
+	offsets.err = errors.New("broker unavailable")
+	m.Failed(kafka.StageRead)
+	time.Sleep(30 * time.Millisecond)
+	if err := m.Check(ctx); err == nil {
+		t.Fatalf("expected check error")
+	}
+
+	s := m.Status()
+	if s.Lag != 1 || s.CheckError == "" || !s.Stalled || s.Errors[kafka.StageRead] != 1 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
+
+func TestMonitor_Nil(t *testing.T) {
+	var m *kafka.Monitor
+	m.Received()
+	m.Failed(kafka.StageInsert)
+	if err := m.Check(context.Background()); err != nil || !m.Ready() {
+		t.Fatalf("nil monitor must be ready")
+	}
+}
+
+func TestMonitor_PausedIsNotStalled(t *testing.T) {
+	offsets := &fakeOffsets{partitions: []models.PartitionStatus{{Partition: 0, CommittedOffset: 0, HighWaterMark: 4}}}
+	m := newMonitor(offsets)
+	m.SetPaused(true)
+
+	time.Sleep(30 * time.Millisecond)
+	m.Check(context.Background())
+	if !m.Ready() || !m.Status().Paused {
+		t.Fatalf("paused consumer must stay ready")
+	}
+}
@@ -3,13 +3,21 @@ package kafka
 import (
 	"context"
 	"demoserv/internal/config"
-	"log"
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/message"
+	"demoserv/internal/models"
+	"demoserv/internal/tracing"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewProducer(cfg *config.Config) {
+func NewProducer(cfg *config.Config, log *slog.Logger) {
 	ctx := context.Background()
 
 	writer := kafka.NewWriter(kafka.WriterConfig{
@@ -21,16 +29,64 @@ func NewProducer(cfg *config.Config) {
 
 	jsonData, err := os.ReadFile("test.json")
 	if err != nil {
-		log.Printf("Ошибка при чтении файла model.json:%v", err)
+		log.Error("unable to read test.json", sl.Err(err))
+	}
+
+	// Кодируем заказ в формат из конфига
+	format, err := producerFormat(cfg.Kafka.KAFKA_PRODUCER_FORMAT)
+	if err != nil {
+		log.Error("unknown producer format", sl.Err(err))
+		return
+	}
+	var order models.Order
+	if err := json.Unmarshal(jsonData, &order); err != nil {
+		log.Error("unable to parse test.json", sl.Err(err))
+		return
+	}
+	value, err := format.Marshal(order)
+	if err != nil {
+		log.Error("unable to encode order", sl.Err(err))
+		return
+	}
+	headers := []kafka.Header{
+		{Key: message.ContentTypeHeader, Value: []byte(format.ContentType())},
+		{Key: message.VersionHeader, Value: []byte(message.CurrentVersion)},
 	}
-	
 
 	for {
-		err = writer.WriteMessages(ctx, kafka.Message{
-			Value: jsonData,
-		})
+		// каждое сообщение начинает трассу, консьюмер продолжает ее по заголовку traceparent
+		msgCtx, span := tracer.Start(ctx, "kafka.produce "+cfg.Kafka.KAFKA_TOPIC,
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKafka,
+				semconv.MessagingOperationTypeSend,
+				semconv.MessagingDestinationName(cfg.Kafka.KAFKA_TOPIC),
+			),
+		)
+		msg := kafka.Message{
+			Headers: append([]kafka.Header(nil), headers...),
+			Value:   value,
+		}
+		tracing.InjectKafka(msgCtx, &msg)
+		err = writer.WriteMessages(msgCtx, msg)
+		tracing.End(span, err)
 		if err != nil {
-			log.Printf("Ошибка при отправке:%v", err)
+			log.Error("unable to send message", sl.Err(err))
 		}
 	}
 }
+
+// producerFormat выбирает кодек по имени формата: json, protobuf или avro
+func producerFormat(name string) (message.Format, error) {
+	codecs := message.NewCodecs(false)
+	switch name {
+	case "", "json":
+		return codecs.Format(message.ContentTypeJSON)
+	case "protobuf":
+		return codecs.Format(message.ContentTypeProtobuf)
+	case "avro":
+		return codecs.Format(message.ContentTypeAvro)
+	default:
+		return nil, fmt.Errorf("unknown producer format %q (supported: json, protobuf, avro)", name)
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"demoserv/internal/models"
+)
+
+// Форматы вывода
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New создает логгер с уровнем и форматом из конфига. По умолчанию info и JSON
+func New(cfg models.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("unknown log level %q (supported: debug, info, warn, error)", cfg.Level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (supported: json, text)", cfg.Format)
+	}
+}
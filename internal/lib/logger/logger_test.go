@@ -0,0 +1,55 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"demoserv/internal/lib/logger"
+	"demoserv/internal/models"
+)
+
+func TestNew_JSONAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(models.LogConfig{Level: "warn", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	log.Info("skipped")
+	log.Warn("kept", "order_uid", "o-1")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only warn line, got %q", buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("log line is not json: %v", err)
+	}
+	if rec["msg"] != "kept" || rec["order_uid"] != "o-1" {
+		t.Fatalf("unexpected record %v", rec)
+	}
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(models.LogConfig{Format: "text"}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	log.Info("hello")
+	if !strings.Contains(buf.String(), "msg=hello") {
+		t.Fatalf("expected text output, got %q", buf.String())
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	if _, err := logger.New(models.LogConfig{Level: "loud"}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+	if _, err := logger.New(models.LogConfig{Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,11 @@
+package sl
+
+import "log/slog"
+
+// Err атрибут с ошибкой
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.String("error", "")
+	}
+	return slog.String("error", err.Error())
+}
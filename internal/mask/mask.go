@@ -0,0 +1,214 @@
+package mask
+
+import (
+	"fmt"
+	"strings"
+
+	"demoserv/internal/models"
+)
+
+// Visibility уровень видимости поля
+type Visibility int
+
+const (
+	// Hidden поле отдается пустым
+	Hidden Visibility = iota
+	// Partial поле частично скрыто: +972****1111
+	Partial
+	// Full поле отдается как есть
+	Full
+)
+
+// Поля заказа с персональными данными
+const (
+	FieldName        = "delivery.name"
+	FieldPhone       = "delivery.phone"
+	FieldEmail       = "delivery.email"
+	FieldAddress     = "delivery.address"
+	FieldTransaction = "payment.transaction"
+)
+
+// Роли клиентов
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleAnalyst = "analyst"
+)
+
+var fields = []string{FieldName, FieldPhone, FieldEmail, FieldAddress, FieldTransaction}
+
+// Policy правила видимости: роль -> поле -> уровень.
+// Поля, которых нет в правилах роли, скрываются полностью
+type Policy map[string]map[string]Visibility
+
+// DefaultPolicy правила по умолчанию
+func DefaultPolicy() Policy {
+	return Policy{
+		RoleAdmin: {
+			FieldName:        Full,
+			FieldPhone:       Full,
+			FieldEmail:       Full,
+			FieldAddress:     Full,
+			FieldTransaction: Full,
+		},
+		RoleSupport: {
+			FieldName:        Full,
+			FieldPhone:       Partial,
+			FieldEmail:       Partial,
+			FieldAddress:     Full,
+			FieldTransaction: Partial,
+		},
+		RoleAnalyst: {
+			FieldName:        Full,
+			FieldPhone:       Hidden,
+			FieldEmail:       Hidden,
+			FieldAddress:     Partial,
+			FieldTransaction: Hidden,
+		},
+	}
+}
+
+// NewPolicy строит правила по умолчанию с переопределениями из конфига
+// вида роль -> поле -> full|partial|hidden
+func NewPolicy(overrides map[string]map[string]string) (Policy, error) {
+	p := DefaultPolicy()
+	for role, rules := range overrides {
+		if p[role] == nil {
+			p[role] = make(map[string]Visibility)
+		}
+		for field, value := range rules {
+			if !isKnownField(field) {
+				return nil, fmt.Errorf("role %s: unknown field %q", role, field)
+			}
+			v, err := parseVisibility(value)
+			if err != nil {
+				return nil, fmt.Errorf("role %s, field %s: %v", role, field, err)
+			}
+			p[role][field] = v
+		}
+	}
+	return p, nil
+}
+
+// Visibility уровень видимости поля для набора ролей: берется самый открытый
+func (p Policy) Visibility(roles []string, field string) Visibility {
+	best := Hidden
+	for _, role := range roles {
+		if v, ok := p[role][field]; ok && v > best {
+			best = v
+		}
+	}
+	return best
+}
+
+// Apply возвращает копию заказа с полями, скрытыми по ролям клиента.
+// Применяется ко всему, что отдается наружу: и из кэша, и из базы,
+// и в списках, иначе кэш отдаст то, что база бы скрыла
+func (p Policy) Apply(order models.Order, roles []string) models.Order {
+	order.Delivery.Name = apply(p.Visibility(roles, FieldName), order.Delivery.Name, name)
+	order.Delivery.Phone = apply(p.Visibility(roles, FieldPhone), order.Delivery.Phone, phone)
+	order.Delivery.Email = apply(p.Visibility(roles, FieldEmail), order.Delivery.Email, email)
+	order.Delivery.Address = apply(p.Visibility(roles, FieldAddress), order.Delivery.Address, address)
+	order.Payment.Transaction = apply(p.Visibility(roles, FieldTransaction), order.Payment.Transaction, tail)
+	return order
+}
+
+// ApplySearch скрывает фрагменты результата поиска по тем же правилам, что и поля заказа.
+// При частичной видимости выделение снимается, скрытые фрагменты убираются
+func (p Policy) ApplySearch(result models.SearchResult, roles []string) models.SearchResult {
+	snippets := make([]models.SearchSnippet, 0, len(result.Snippets))
+	for _, s := range result.Snippets {
+		if s.Field == FieldName {
+			v := p.Visibility(roles, FieldName)
+			if v == Hidden {
+				continue
+			}
+			if v == Partial {
+				s.Text = name(unmark.Replace(s.Text))
+			}
+		}
+		snippets = append(snippets, s)
+	}
+	result.Snippets = snippets
+	return result
+}
+
+// unmark убирает выделение найденных слов из фрагмента поиска
+var unmark = strings.NewReplacer("<mark>", "", "</mark>", "")
+
+func apply(v Visibility, value string, partial func(string) string) string {
+	switch v {
+	case Full:
+		return value
+	case Partial:
+		return partial(value)
+	default:
+		return ""
+	}
+}
+
+const stars = "****"
+
+// keep оставляет head символов в начале и tail в конце. Середина заменяется
+// звездочками фиксированной длины, чтобы не выдавать длину значения
+func keep(s string, head, tail int) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return ""
+	}
+	if len(r) <= head+tail {
+		return stars
+	}
+	return string(r[:head]) + stars + string(r[len(r)-tail:])
+}
+
+// name: Alex Ivanov -> A****
+func name(s string) string {
+	return keep(s, 1, 0)
+}
+
+// phone: +9721111111 -> +972****1111
+func phone(s string) string {
+	return keep(s, 4, 4)
+}
+
+// email: alex.ivanov@gmail.com -> a****@gmail.com
+func email(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok {
+		return keep(s, 1, 0)
+	}
+	return keep(local, 1, 0) + "@" + domain
+}
+
+// address: Herzl Street 10 -> Her****
+func address(s string) string {
+	return keep(s, 3, 0)
+}
+
+// tail: c789def8c3c95a7test -> ****test
+func tail(s string) string {
+	return keep(s, 0, 4)
+}
+
+func isKnownField(field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func parseVisibility(s string) (Visibility, error) {
+	switch strings.ToLower(s) {
+	case "full":
+		return Full, nil
+	case "partial":
+		return Partial, nil
+	case "hidden":
+		return Hidden, nil
+	default:
+		return Hidden, fmt.Errorf("unknown visibility %q (supported: full, partial, hidden)", s)
+	}
+}
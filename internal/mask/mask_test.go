@@ -0,0 +1,139 @@
+package mask_test
+
+import (
+	"slices"
+	"testing"
+
+	"demoserv/internal/mask"
+	"demoserv/internal/models"
+)
+
+func order() models.Order {
+	return models.Order{
+		OrderUID: "o-1",
+		Delivery: models.Delivery{
+			Name:    "Alex Ivanov",
+			Phone:   "+9721111111",
+			Address: "Herzl Street 10",
+			Email:   "alex.ivanov@gmail.com",
+		},
+		Payment: models.Payment{Transaction: "c789def8c3c95a7test"},
+	}
+}
+
+func TestApply_ByRole(t *testing.T) {
+	p := mask.DefaultPolicy()
+
+	cases := []struct {
+		roles []string
+		want  models.Delivery
+		tx    string
+	}{
+		{[]string{mask.RoleAdmin}, order().Delivery, "c789def8c3c95a7test"},
+		{[]string{mask.RoleSupport}, models.Delivery{
+			Name: "Alex Ivanov", Phone: "+972****1111", Address: "Herzl Street 10", Email: "a****@gmail.com",
+		}, "****test"},
+		{[]string{mask.RoleAnalyst}, models.Delivery{
+			Name: "Alex Ivanov", Phone: "", Address: "Her****", Email: "",
+		}, ""},
+		{nil, models.Delivery{}, ""},
+		// при нескольких ролях берется самая открытая видимость
+		{[]string{mask.RoleAnalyst, mask.RoleSupport}, models.Delivery{
+			Name: "Alex Ivanov", Phone: "+972****1111", Address: "Herzl Street 10", Email: "a****@gmail.com",
+		}, "****test"},
+	}
+
+	for _, tc := range cases {
+		got := p.Apply(order(), tc.roles)
+		if got.Delivery != tc.want {
+			t.Fatalf("roles %v: got %+v, want %+v", tc.roles, got.Delivery, tc.want)
+		}
+		if got.Payment.Transaction != tc.tx {
+			t.Fatalf("roles %v: transaction %q, want %q", tc.roles, got.Payment.Transaction, tc.tx)
+		}
+	}
+}
+
+func TestApply_DoesNotMutateSource(t *testing.T) {
+	src := order()
+	mask.DefaultPolicy().Apply(src, nil)
+	if src.Delivery.Phone != "+9721111111" {
+		t.Fatalf("source order was modified: %+v", src.Delivery)
+	}
+}
+
+func TestApply_ShortValues(t *testing.T) {
+	o := order()
+	o.Delivery.Phone = "12345"
+	o.Payment.Transaction = "abc"
+
+	got := mask.DefaultPolicy().Apply(o, []string{mask.RoleSupport})
+	if got.Delivery.Phone != "****" || got.Payment.Transaction != "****" {
+		t.Fatalf("short values must be fully masked, got %q and %q", got.Delivery.Phone, got.Payment.Transaction)
+	}
+}
+
+func TestApplySearch(t *testing.T) {
+	result := models.SearchResult{
+		OrderUID: "o-1",
+		Snippets: []models.SearchSnippet{
+			{Field: "items", Text: "<mark>Mascaras</mark> Vivienne Sabo"},
+			{Field: mask.FieldName, Text: "<mark>Alex</mark> Ivanov"},
+		},
+	}
+	p, err := mask.NewPolicy(map[string]map[string]string{
+		mask.RoleAnalyst: {mask.FieldName: "partial"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	cases := []struct {
+		roles []string
+		want  []models.SearchSnippet
+	}{
+		{[]string{mask.RoleAdmin}, result.Snippets},
+		{[]string{mask.RoleAnalyst}, []models.SearchSnippet{result.Snippets[0], {Field: mask.FieldName, Text: "A****"}}},
+		{nil, result.Snippets[:1]},
+	}
+	for _, tc := range cases {
+		got := p.ApplySearch(result, tc.roles)
+		if !slices.Equal(got.Snippets, tc.want) {
+			t.Fatalf("roles %v: got %+v, want %+v", tc.roles, got.Snippets, tc.want)
+		}
+	}
+	if result.Snippets[1].Text != "<mark>Alex</mark> Ivanov" {
+		t.Fatalf("source result was modified: %+v", result.Snippets)
+	}
+}
+
+func TestNewPolicy_Overrides(t *testing.T) {
+	p, err := mask.NewPolicy(map[string]map[string]string{
+		mask.RoleSupport: {mask.FieldAddress: "partial"},
+		"courier":        {mask.FieldPhone: "full"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	if v := p.Visibility([]string{mask.RoleSupport}, mask.FieldAddress); v != mask.Partial {
+		t.Fatalf("expected override to partial, got %v", v)
+	}
+	if v := p.Visibility([]string{mask.RoleSupport}, mask.FieldPhone); v != mask.Partial {
+		t.Fatalf("expected default to be kept, got %v", v)
+	}
+	if v := p.Visibility([]string{"courier"}, mask.FieldPhone); v != mask.Full {
+		t.Fatalf("expected new role rule, got %v", v)
+	}
+	if v := p.Visibility([]string{"courier"}, mask.FieldEmail); v != mask.Hidden {
+		t.Fatalf("expected unspecified field hidden, got %v", v)
+	}
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	if _, err := mask.NewPolicy(map[string]map[string]string{"support": {"delivery.zip": "full"}}); err == nil {
+		t.Fatalf("expected unknown field error")
+	}
+	if _, err := mask.NewPolicy(map[string]map[string]string{"support": {mask.FieldPhone: "maybe"}}); err == nil {
+		t.Fatalf("expected unknown visibility error")
+	}
+}
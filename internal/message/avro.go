@@ -0,0 +1,211 @@
+package message
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"demoserv/internal/models"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+//go:embed order.avsc
+var orderAvroSchema string
+
+// AvroFormat заказ в Avro Object Container: схема писателя встроена
+// в сообщение, поэтому читатель не зависит от версии продюсера
+type AvroFormat struct{}
+
+func (AvroFormat) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (AvroFormat) Marshal(order models.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc, err := ocf.NewEncoder(orderAvroSchema, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("create avro encoder: %v", err)
+	}
+	if err := enc.Encode(toAvro(order)); err != nil {
+		return nil, fmt.Errorf("encode avro: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("flush avro: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (AvroFormat) Unmarshal(version string, body []byte) (models.Order, error) {
+	if err := checkVersion(version); err != nil {
+		return models.Order{}, err
+	}
+
+	dec, err := ocf.NewDecoder(bytes.NewReader(body))
+	if err != nil {
+		return models.Order{}, fmt.Errorf("read avro header: %v", err)
+	}
+
+	var rec avroOrder
+	if !dec.HasNext() {
+		if err := dec.Error(); err != nil {
+			return models.Order{}, fmt.Errorf("read avro block: %v", err)
+		}
+		return models.Order{}, fmt.Errorf("avro container has no records")
+	}
+	if err := dec.Decode(&rec); err != nil {
+		return models.Order{}, fmt.Errorf("decode avro: %v", err)
+	}
+	if dec.HasNext() {
+		return models.Order{}, fmt.Errorf("avro container must hold exactly one order")
+	}
+	return fromAvro(rec), nil
+}
+
+// avroOrder повторяет order.avsc. Поля, которых нет в схеме писателя,
+// остаются нулевыми
+type avroOrder struct {
+	OrderUID          string       `avro:"order_uid"`
+	TrackNumber       string       `avro:"track_number"`
+	Entry             string       `avro:"entry"`
+	Delivery          avroDelivery `avro:"delivery"`
+	Payment           avroPayment  `avro:"payment"`
+	Items             []avroItem   `avro:"items"`
+	Locale            string       `avro:"locale"`
+	InternalSignature string       `avro:"internal_signature"`
+	CustomerID        string       `avro:"customer_id"`
+	DeliveryService   string       `avro:"delivery_service"`
+	ShardKey          string       `avro:"shardkey"`
+	SmID              int64        `avro:"sm_id"`
+	DateCreated       time.Time    `avro:"date_created"`
+	OofShard          string       `avro:"oof_shard"`
+}
+
+type avroDelivery struct {
+	Name    string `avro:"name"`
+	Phone   string `avro:"phone"`
+	Zip     string `avro:"zip"`
+	City    string `avro:"city"`
+	Address string `avro:"address"`
+	Region  string `avro:"region"`
+	Email   string `avro:"email"`
+}
+
+type avroPayment struct {
+	Transaction  string `avro:"transaction"`
+	RequestID    string `avro:"request_id"`
+	Currency     string `avro:"currency"`
+	Provider     string `avro:"provider"`
+	Amount       int64  `avro:"amount"`
+	PaymentDT    int64  `avro:"payment_dt"`
+	Bank         string `avro:"bank"`
+	DeliveryCost int64  `avro:"delivery_cost"`
+	GoodsTotal   int64  `avro:"goods_total"`
+	CustomFee    int64  `avro:"custom_fee"`
+}
+
+type avroItem struct {
+	ChrtID      int64  `avro:"chrt_id"`
+	TrackNumber string `avro:"track_number"`
+	Price       int64  `avro:"price"`
+	RID         string `avro:"rid"`
+	Name        string `avro:"name"`
+	Sale        int64  `avro:"sale"`
+	Size        string `avro:"size"`
+	TotalPrice  int64  `avro:"total_price"`
+	NmID        int64  `avro:"nm_id"`
+	Brand       string `avro:"brand"`
+	Status      int64  `avro:"status"`
+}
+
+func toAvro(o models.Order) avroOrder {
+	rec := avroOrder{
+		OrderUID:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SmID:              int64(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		Delivery:          avroDelivery(o.Delivery),
+		Payment: avroPayment{
+			Transaction:  o.Payment.Transaction,
+			RequestID:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDT:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items: make([]avroItem, 0, len(o.Items)),
+	}
+	for _, it := range o.Items {
+		rec.Items = append(rec.Items, avroItem{
+			ChrtID:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			RID:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmID:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return rec
+}
+
+func fromAvro(rec avroOrder) models.Order {
+	o := models.Order{
+		OrderUID:          rec.OrderUID,
+		TrackNumber:       rec.TrackNumber,
+		Entry:             rec.Entry,
+		Locale:            rec.Locale,
+		InternalSignature: rec.InternalSignature,
+		CustomerID:        rec.CustomerID,
+		DeliveryService:   rec.DeliveryService,
+		ShardKey:          rec.ShardKey,
+		SmID:              int(rec.SmID),
+		DateCreated:       rec.DateCreated,
+		OofShard:          rec.OofShard,
+		Delivery:          models.Delivery(rec.Delivery),
+		Payment: models.Payment{
+			Transaction:  rec.Payment.Transaction,
+			RequestID:    rec.Payment.RequestID,
+			Currency:     rec.Payment.Currency,
+			Provider:     rec.Payment.Provider,
+			Amount:       int(rec.Payment.Amount),
+			PaymentDT:    rec.Payment.PaymentDT,
+			Bank:         rec.Payment.Bank,
+			DeliveryCost: int(rec.Payment.DeliveryCost),
+			GoodsTotal:   int(rec.Payment.GoodsTotal),
+			CustomFee:    int(rec.Payment.CustomFee),
+		},
+	}
+	for _, it := range rec.Items {
+		o.Items = append(o.Items, models.Item{
+			ChrtID:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       int(it.Price),
+			RID:         it.RID,
+			Name:        it.Name,
+			Sale:        int(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int(it.TotalPrice),
+			NmID:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int(it.Status),
+		})
+	}
+	return o
+}
@@ -0,0 +1,134 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+
+	"demoserv/internal/models"
+)
+
+const (
+	// ContentTypeHeader заголовок kafka-сообщения с форматом тела
+	ContentTypeHeader = "content-type"
+
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// ErrUnsupportedContentType возвращается для формата без кодека
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Format кодек одного формата сообщений
+type Format interface {
+	// ContentType основной content-type формата
+	ContentType() string
+	// Marshal кодирует заказ для отправки в kafka
+	Marshal(order models.Order) ([]byte, error)
+	// Unmarshal декодирует тело сообщения указанной версии схемы
+	Unmarshal(version string, body []byte) (models.Order, error)
+}
+
+// Codecs выбирает формат по content-type сообщения
+type Codecs struct {
+	mu      sync.RWMutex
+	formats map[string]Format
+}
+
+// NewCodecs создает набор кодеков JSON, Protobuf и Avro.
+// strict относится к JSON: неизвестные поля считаются ошибкой
+func NewCodecs(strict bool) *Codecs {
+	c := &Codecs{formats: make(map[string]Format)}
+	c.Register(NewJSONFormat(NewRegistry(strict)), "text/json")
+	c.Register(ProtobufFormat{}, "application/protobuf", "application/vnd.google.protobuf")
+	c.Register(AvroFormat{}, "avro/binary", "application/vnd.apache.avro+binary")
+	return c
+}
+
+// Register добавляет формат под его content-type и дополнительными алиасами
+func (c *Codecs) Register(f Format, aliases ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.formats[f.ContentType()] = f
+	for _, alias := range aliases {
+		c.formats[alias] = f
+	}
+}
+
+// Format возвращает кодек для content-type. Пустой content-type — это JSON,
+// так пишут старые продюсеры
+func (c *Codecs) Format(contentType string) (Format, error) {
+	mediaType := ContentTypeJSON
+	if strings.TrimSpace(contentType) != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrUnsupportedContentType, contentType, err)
+		}
+		mediaType = mt
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	f, ok := c.formats[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: %s)", ErrUnsupportedContentType, mediaType, strings.Join(c.contentTypes(), ", "))
+	}
+	return f, nil
+}
+
+// Decode декодирует сообщение по его content-type и версии схемы
+func (c *Codecs) Decode(contentType, version string, body []byte) (models.Order, error) {
+	f, err := c.Format(contentType)
+	if err != nil {
+		return models.Order{}, err
+	}
+	return f.Unmarshal(version, body)
+}
+
+func (c *Codecs) contentTypes() []string {
+	types := make([]string, 0, len(c.formats))
+	for ct := range c.formats {
+		types = append(types, ct)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// JSONFormat JSON с версиями схемы из Registry
+type JSONFormat struct {
+	registry *Registry
+}
+
+// NewJSONFormat создает JSON кодек поверх реестра версий
+func NewJSONFormat(registry *Registry) JSONFormat {
+	return JSONFormat{registry: registry}
+}
+
+func (JSONFormat) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONFormat) Marshal(order models.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (f JSONFormat) Unmarshal(version string, body []byte) (models.Order, error) {
+	return f.registry.Decode(version, body)
+}
+
+// checkVersion для бинарных форматов: совместимость обеспечивает сама схема,
+// поэтому допускается только текущая версия или ее отсутствие
+func checkVersion(version string) error {
+	version = strings.TrimSpace(version)
+	if version == "" || version == CurrentVersion {
+		return nil
+	}
+	return fmt.Errorf("%w %q (supported: %s)", ErrUnknownVersion, version, CurrentVersion)
+}
@@ -0,0 +1,102 @@
+package message_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"demoserv/internal/message"
+	"demoserv/internal/models"
+)
+
+func testOrder(t *testing.T) models.Order {
+	t.Helper()
+	var order models.Order
+	if err := json.Unmarshal(readTestOrder(t), &order); err != nil {
+		t.Fatalf("unmarshal test.json: %v", err)
+	}
+	return order
+}
+
+func TestFormats_RoundTrip(t *testing.T) {
+	codecs := message.NewCodecs(true)
+	want := testOrder(t)
+
+	for _, ct := range []string{
+		message.ContentTypeJSON,
+		message.ContentTypeProtobuf,
+		message.ContentTypeAvro,
+	} {
+		t.Run(ct, func(t *testing.T) {
+			f, err := codecs.Format(ct)
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+
+			body, err := f.Marshal(want)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			got, err := codecs.Decode(ct, message.CurrentVersion, body)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !got.DateCreated.Equal(want.DateCreated) {
+				t.Fatalf("date_created: got %v want %v", got.DateCreated, want.DateCreated)
+			}
+			got.DateCreated = want.DateCreated
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip mismatch:\ngot  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCodecs_ContentTypeSelection(t *testing.T) {
+	codecs := message.NewCodecs(false)
+
+	f, err := codecs.Format("")
+	if err != nil || f.ContentType() != message.ContentTypeJSON {
+		t.Fatalf("empty content type should be JSON, got %v, %v", f, err)
+	}
+
+	f, err = codecs.Format("application/json; charset=utf-8")
+	if err != nil || f.ContentType() != message.ContentTypeJSON {
+		t.Fatalf("content type params should be ignored, got %v, %v", f, err)
+	}
+
+	f, err = codecs.Format("avro/binary")
+	if err != nil || f.ContentType() != message.ContentTypeAvro {
+		t.Fatalf("avro alias not resolved, got %v, %v", f, err)
+	}
+
+	if _, err := codecs.Format("text/xml"); !errors.Is(err, message.ErrUnsupportedContentType) {
+		t.Fatalf("expected ErrUnsupportedContentType, got %v", err)
+	}
+}
+
+func TestCodecs_BinaryFormatsRejectUnknownVersion(t *testing.T) {
+	codecs := message.NewCodecs(false)
+	order := testOrder(t)
+
+	body, err := message.ProtobufFormat{}.Marshal(order)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if _, err := codecs.Decode(message.ContentTypeProtobuf, "3", body); !errors.Is(err, message.ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestCodecs_MalformedBody(t *testing.T) {
+	codecs := message.NewCodecs(false)
+
+	if _, err := codecs.Decode(message.ContentTypeAvro, "", []byte("not avro")); err == nil {
+		t.Fatalf("expected avro error, got nil")
+	}
+	if _, err := codecs.Decode(message.ContentTypeProtobuf, "", []byte{0xff, 0xff}); err == nil {
+		t.Fatalf("expected protobuf error, got nil")
+	}
+}
@@ -0,0 +1,149 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"demoserv/internal/models"
+)
+
+const (
+	// VersionHeader заголовок kafka-сообщения с версией схемы
+	VersionHeader = "schema-version"
+	// CurrentVersion версия, соответствующая текущей models.Order.
+	// Сообщения без заголовка и без конверта считаются этой версией
+	CurrentVersion = "1"
+)
+
+// ErrUnknownVersion возвращается для версии, у которой нет декодера
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// DecodeFunc декодирует payload конкретной версии в текущую models.Order
+type DecodeFunc func(payload []byte, strict bool) (models.Order, error)
+
+// Envelope конверт сообщения для продюсеров, которые не умеют в заголовки
+type Envelope struct {
+	SchemaVersion string          `json:"schema_version"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Registry хранит декодеры по версиям схемы
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]DecodeFunc
+	strict   bool
+}
+
+// NewRegistry создает реестр с декодерами всех поддерживаемых версий.
+// В strict режиме неизвестные поля JSON считаются ошибкой
+func NewRegistry(strict bool) *Registry {
+	r := &Registry{
+		decoders: make(map[string]DecodeFunc),
+		strict:   strict,
+	}
+	r.Register(CurrentVersion, decodeV1)
+	return r
+}
+
+// Register добавляет (или заменяет) декодер для версии
+func (r *Registry) Register(version string, fn DecodeFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoders[version] = fn
+}
+
+// Versions возвращает отсортированный список поддерживаемых версий
+func (r *Registry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]string, 0, len(r.decoders))
+	for v := range r.decoders {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Decode декодирует тело сообщения. Версия берется из заголовка, затем из
+// конверта, иначе считается CurrentVersion
+func (r *Registry) Decode(headerVersion string, body []byte) (models.Order, error) {
+	version := strings.TrimSpace(headerVersion)
+	payload := body
+
+	if env, ok := unwrap(body); ok {
+		if version == "" {
+			version = env.SchemaVersion
+		} else if env.SchemaVersion != "" && env.SchemaVersion != version {
+			return models.Order{}, fmt.Errorf("schema version mismatch: header %q, envelope %q", version, env.SchemaVersion)
+		}
+		payload = env.Payload
+	}
+	if version == "" {
+		version = CurrentVersion
+	}
+
+	r.mu.RLock()
+	fn, ok := r.decoders[version]
+	r.mu.RUnlock()
+	if !ok {
+		return models.Order{}, fmt.Errorf("%w %q (supported: %s)", ErrUnknownVersion, version, strings.Join(r.Versions(), ", "))
+	}
+
+	order, err := fn(payload, r.strict)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("decode schema version %s: %v", version, err)
+	}
+	return order, nil
+}
+
+// Payload возвращает заказ из JSON сообщения: payload конверта или все тело
+func Payload(body []byte) []byte {
+	if env, ok := unwrap(body); ok {
+		return env.Payload
+	}
+	return body
+}
+
+// unwrap проверяет, завернуто ли сообщение в конверт
+func unwrap(body []byte) (Envelope, bool) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, false
+	}
+	if len(env.Payload) == 0 || bytes.Equal(env.Payload, []byte("null")) {
+		return env, false
+	}
+	return env, true
+}
+
+// decodeV1 текущий формат: payload один в один совпадает с models.Order
+func decodeV1(payload []byte, strict bool) (models.Order, error) {
+	var order models.Order
+	if err := Unmarshal(payload, &order, strict); err != nil {
+		return order, err
+	}
+	return order, nil
+}
+
+// Unmarshal разбирает JSON, в strict режиме запрещая неизвестные поля.
+// Пригодится декодерам старых версий
+func Unmarshal(data []byte, v any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON object")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package message_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"demoserv/internal/message"
+	"demoserv/internal/models"
+)
+
+func readTestOrder(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../test.json")
+	if err != nil {
+		t.Fatalf("read test.json: %v", err)
+	}
+	return data
+}
+
+func TestDecode_NoVersionDefaultsToCurrent(t *testing.T) {
+	r := message.NewRegistry(false)
+
+	order, err := r.Decode("", readTestOrder(t))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if order.OrderUID != "c789def8c3c95a7test" {
+		t.Fatalf("unexpected uid: %s", order.OrderUID)
+	}
+	if len(order.Items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(order.Items))
+	}
+}
+
+func TestDecode_HeaderVersion(t *testing.T) {
+	r := message.NewRegistry(false)
+
+	if _, err := r.Decode("1", readTestOrder(t)); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+}
+
+func TestDecode_Envelope(t *testing.T) {
+	r := message.NewRegistry(true)
+	body := []byte(`{"schema_version":"1","payload":{"order_uid":"env-1","track_number":"T"}}`)
+
+	order, err := r.Decode("", body)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if order.OrderUID != "env-1" {
+		t.Fatalf("unexpected uid: %s", order.OrderUID)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	payload := `{"order_uid":"env-1","track_number":"T"}`
+	if got := message.Payload([]byte(`{"schema_version":"1","payload":` + payload + `}`)); string(got) != payload {
+		t.Fatalf("unexpected envelope payload: %s", got)
+	}
+	if got := message.Payload([]byte(payload)); string(got) != payload {
+		t.Fatalf("body without envelope must be returned as is: %s", got)
+	}
+}
+
+func TestDecode_UnknownVersion(t *testing.T) {
+	r := message.NewRegistry(false)
+
+	_, err := r.Decode("42", readTestOrder(t))
+	if !errors.Is(err, message.ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion, got %v", err)
+	}
+
+	body := []byte(`{"schema_version":"0","payload":{"order_uid":"x"}}`)
+	if _, err := r.Decode("", body); !errors.Is(err, message.ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion for envelope, got %v", err)
+	}
+}
+
+func TestDecode_VersionMismatch(t *testing.T) {
+	r := message.NewRegistry(false)
+	body := []byte(`{"schema_version":"2","payload":{"order_uid":"x"}}`)
+
+	if _, err := r.Decode("1", body); err == nil {
+		t.Fatalf("expected mismatch error, got nil")
+	}
+}
+
+func TestDecode_StrictMode(t *testing.T) {
+	body := []byte(`{"order_uid":"s-1","orderUid":"renamed"}`)
+
+	if _, err := message.NewRegistry(false).Decode("", body); err != nil {
+		t.Fatalf("lenient decode failed: %v", err)
+	}
+	if _, err := message.NewRegistry(true).Decode("", body); err == nil {
+		t.Fatalf("expected strict mode to reject unknown field")
+	}
+}
+
+func TestRegister_CustomVersion(t *testing.T) {
+	r := message.NewRegistry(false)
+	r.Register("2", func(payload []byte, strict bool) (models.Order, error) {
+		var v2 struct {
+			UID string `json:"uid"`
+		}
+		if err := message.Unmarshal(payload, &v2, strict); err != nil {
+			return models.Order{}, err
+		}
+		return models.Order{OrderUID: v2.UID}, nil
+	})
+
+	order, err := r.Decode("2", []byte(`{"uid":"v2-1"}`))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if order.OrderUID != "v2-1" {
+		t.Fatalf("unexpected uid: %s", order.OrderUID)
+	}
+}
@@ -0,0 +1,144 @@
+package message
+
+import (
+	"fmt"
+
+	"demoserv/internal/message/orderpb"
+	"demoserv/internal/models"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ProtobufFormat заказ в формате proto/order.proto
+type ProtobufFormat struct{}
+
+func (ProtobufFormat) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (ProtobufFormat) Marshal(order models.Order) ([]byte, error) {
+	return proto.Marshal(toProto(order))
+}
+
+func (ProtobufFormat) Unmarshal(version string, body []byte) (models.Order, error) {
+	if err := checkVersion(version); err != nil {
+		return models.Order{}, err
+	}
+
+	var pb orderpb.Order
+	if err := proto.Unmarshal(body, &pb); err != nil {
+		return models.Order{}, fmt.Errorf("decode protobuf: %v", err)
+	}
+	return fromProto(&pb), nil
+}
+
+func toProto(o models.Order) *orderpb.Order {
+	pb := &orderpb.Order{
+		OrderUid:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		OofShard:          o.OofShard,
+		Delivery: &orderpb.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+	}
+	if !o.DateCreated.IsZero() {
+		pb.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	for _, it := range o.Items {
+		pb.Items = append(pb.Items, &orderpb.Item{
+			ChrtId:      it.ChrtID,
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return pb
+}
+
+func fromProto(pb *orderpb.Order) models.Order {
+	o := models.Order{
+		OrderUID:          pb.GetOrderUid(),
+		TrackNumber:       pb.GetTrackNumber(),
+		Entry:             pb.GetEntry(),
+		Locale:            pb.GetLocale(),
+		InternalSignature: pb.GetInternalSignature(),
+		CustomerID:        pb.GetCustomerId(),
+		DeliveryService:   pb.GetDeliveryService(),
+		ShardKey:          pb.GetShardkey(),
+		SmID:              int(pb.GetSmId()),
+		OofShard:          pb.GetOofShard(),
+		Delivery: models.Delivery{
+			Name:    pb.GetDelivery().GetName(),
+			Phone:   pb.GetDelivery().GetPhone(),
+			Zip:     pb.GetDelivery().GetZip(),
+			City:    pb.GetDelivery().GetCity(),
+			Address: pb.GetDelivery().GetAddress(),
+			Region:  pb.GetDelivery().GetRegion(),
+			Email:   pb.GetDelivery().GetEmail(),
+		},
+		Payment: models.Payment{
+			Transaction:  pb.GetPayment().GetTransaction(),
+			RequestID:    pb.GetPayment().GetRequestId(),
+			Currency:     pb.GetPayment().GetCurrency(),
+			Provider:     pb.GetPayment().GetProvider(),
+			Amount:       int(pb.GetPayment().GetAmount()),
+			PaymentDT:    pb.GetPayment().GetPaymentDt(),
+			Bank:         pb.GetPayment().GetBank(),
+			DeliveryCost: int(pb.GetPayment().GetDeliveryCost()),
+			GoodsTotal:   int(pb.GetPayment().GetGoodsTotal()),
+			CustomFee:    int(pb.GetPayment().GetCustomFee()),
+		},
+	}
+	if pb.GetDateCreated() != nil {
+		o.DateCreated = pb.GetDateCreated().AsTime()
+	}
+	for _, it := range pb.GetItems() {
+		o.Items = append(o.Items, models.Item{
+			ChrtID:      it.GetChrtId(),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			RID:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        it.GetNmId(),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	return o
+}
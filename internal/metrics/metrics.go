@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"demoserv/internal/kafka"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "demoserv"
+
+// New реестр метрик сервиса: рантайм Go, процесс и состояние консьюмера Kafka
+func New(monitor *kafka.Monitor) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newConsumerCollector(monitor),
+	)
+	return reg
+}
+
+// Handler отдает метрики в формате Prometheus
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// consumerCollector снимает метрики с kafka.Monitor в момент запроса
+type consumerCollector struct {
+	monitor *kafka.Monitor
+
+	lag              *prometheus.Desc
+	committedOffset  *prometheus.Desc
+	highWaterMark    *prometheus.Desc
+	lastMessage      *prometheus.Desc
+	stalled          *prometheus.Desc
+	errors           *prometheus.Desc
+	readerMessages   *prometheus.Desc
+	readerErrors     *prometheus.Desc
+	readerTimeouts   *prometheus.Desc
+	readerRebalances *prometheus.Desc
+}
+
+func newConsumerCollector(monitor *kafka.Monitor) *consumerCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "kafka_consumer", name), help, labels, nil)
+	}
+	return &consumerCollector{
+		monitor:          monitor,
+		lag:              desc("lag", "Messages not yet committed by the consumer group.", "partition"),
+		committedOffset:  desc("committed_offset", "Last offset committed by the consumer group.", "partition"),
+		highWaterMark:    desc("high_water_mark", "Offset of the next message to be written to the partition.", "partition"),
+		lastMessage:      desc("last_message_timestamp_seconds", "Time the last message was read."),
+		stalled:          desc("stalled", "1 if the consumer made no progress while lagging."),
+		errors:           desc("errors_total", "Message processing errors by stage.", "stage"),
+		readerMessages:   desc("reader_messages_total", "Messages fetched by the reader."),
+		readerErrors:     desc("reader_errors_total", "Errors reported by the reader."),
+		readerTimeouts:   desc("reader_timeouts_total", "Fetch timeouts reported by the reader."),
+		readerRebalances: desc("rebalances_total", "Consumer group rebalances."),
+	}
+}
+
+func (c *consumerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+	ch <- c.committedOffset
+	ch <- c.highWaterMark
+	ch <- c.lastMessage
+	ch <- c.stalled
+	ch <- c.errors
+	ch <- c.readerMessages
+	ch <- c.readerErrors
+	ch <- c.readerTimeouts
+	ch <- c.readerRebalances
+}
+
+func (c *consumerCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.monitor.Status()
+
+	for _, p := range s.Partitions {
+		partition := strconv.Itoa(p.Partition)
+		ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(p.Lag), partition)
+		ch <- prometheus.MustNewConstMetric(c.committedOffset, prometheus.GaugeValue, float64(p.CommittedOffset), partition)
+		ch <- prometheus.MustNewConstMetric(c.highWaterMark, prometheus.GaugeValue, float64(p.HighWaterMark), partition)
+	}
+	if s.LastMessageAt != nil {
+		ch <- prometheus.MustNewConstMetric(c.lastMessage, prometheus.GaugeValue, float64(s.LastMessageAt.UnixMilli())/1000)
+	}
+	stalled := 0.0
+	if s.Stalled {
+		stalled = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.stalled, prometheus.GaugeValue, stalled)
+
+	// все этапы, чтобы счетчики были видны и с нулем
+	for _, stage := range []string{kafka.StageRead, kafka.StageDecode, kafka.StageValidate, kafka.StageInsert, kafka.StageCommit, kafka.StageDeadLetter} {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(s.Errors[stage]), stage)
+	}
+	ch <- prometheus.MustNewConstMetric(c.readerMessages, prometheus.CounterValue, float64(s.Reader.Messages))
+	ch <- prometheus.MustNewConstMetric(c.readerErrors, prometheus.CounterValue, float64(s.Reader.Errors))
+	ch <- prometheus.MustNewConstMetric(c.readerTimeouts, prometheus.CounterValue, float64(s.Reader.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.readerRebalances, prometheus.CounterValue, float64(s.Reader.Rebalances))
+}
@@ -0,0 +1,40 @@
+package metrics_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"demoserv/internal/kafka"
+	"demoserv/internal/metrics"
+	"demoserv/internal/models"
+)
+
+func TestHandler_ConsumerMetrics(t *testing.T) {
+	monitor := kafka.NewMonitor(models.KafkaConfig{KAFKA_TOPIC: "orders"}, func(context.Context) ([]models.PartitionStatus, error) {
+		return []models.PartitionStatus{{Partition: 0, CommittedOffset: 5, HighWaterMark: 12}}, nil
+	}, slog.New(slog.DiscardHandler))
+	monitor.Failed(kafka.StageInsert)
+	if err := monitor.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	metrics.Handler(metrics.New(monitor)).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		`demoserv_kafka_consumer_lag{partition="0"} 7`,
+		`demoserv_kafka_consumer_committed_offset{partition="0"} 5`,
+		`demoserv_kafka_consumer_errors_total{stage="insert"} 1`,
+		`demoserv_kafka_consumer_errors_total{stage="decode"} 0`,
+		`demoserv_kafka_consumer_stalled 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -9,6 +9,73 @@ type KafkaConfig struct {
     KAFKA_BROKER string `yaml:"KKAFKA_BROKER"`
     KAFKA_TOPIC   string `yaml:"KAFKA_TOPIC"`
     KAFKA_GROUP   string `yaml:"KAFKA_GROUP"`
+    // KAFKA_STRICT_DECODING отклонять сообщения с неизвестными полями
+    KAFKA_STRICT_DECODING bool `yaml:"KAFKA_STRICT_DECODING"`
+    // KAFKA_PRODUCER_FORMAT формат сообщений продюсера: json, protobuf, avro
+    KAFKA_PRODUCER_FORMAT string `yaml:"KAFKA_PRODUCER_FORMAT"`
+    // KAFKA_SCHEMA_VALIDATION проверять заказы по JSON Schema
+    KAFKA_SCHEMA_VALIDATION bool `yaml:"KAFKA_SCHEMA_VALIDATION"`
+    // KAFKA_LAG_CHECK_INTERVAL как часто проверять отставание группы
+    KAFKA_LAG_CHECK_INTERVAL time.Duration `yaml:"KAFKA_LAG_CHECK_INTERVAL" env-default:"15s"`
+    // KAFKA_STALL_TIMEOUT сколько можно не продвигаться при отставании, после этого сервис не готов
+    KAFKA_STALL_TIMEOUT time.Duration `yaml:"KAFKA_STALL_TIMEOUT" env-default:"2m"`
+    // KAFKA_DEAD_LETTER_TOPIC топик для заказов, которые база не принимает, по умолчанию <KAFKA_TOPIC>.dlq
+    KAFKA_DEAD_LETTER_TOPIC string `yaml:"KAFKA_DEAD_LETTER_TOPIC"`
+}
+
+// ConsumerStatus состояние консьюмера: отставание, смещения, ошибки
+type ConsumerStatus struct {
+	Topic          string            `json:"topic"`
+	Group          string            `json:"group"`
+	Stalled        bool              `json:"stalled"`
+	Paused         bool              `json:"paused"`
+	Lag            int64             `json:"lag"`
+	Partitions     []PartitionStatus `json:"partitions"`
+	LastMessageAt  *time.Time        `json:"last_message_at,omitempty"`
+	LastProgressAt time.Time         `json:"last_progress_at"`
+	LastCheckAt    *time.Time        `json:"last_check_at,omitempty"`
+	CheckError     string            `json:"check_error,omitempty"`
+	Errors         map[string]int64  `json:"errors"`
+	Reader         ReaderStats       `json:"reader"`
+}
+
+// ConsumerState состояние цикла консьюмера: running, pausing (дообрабатывает
+// текущее сообщение) или paused, и партиции, назначенные этому экземпляру
+type ConsumerState struct {
+	Status       string        `json:"status"`
+	Since        *time.Time    `json:"since,omitempty"`
+	By           string        `json:"by,omitempty"`
+	ClientID     string        `json:"client_id"`
+	Assignments  []int         `json:"assignments"`
+	Members      []GroupMember `json:"members"`
+	MembersError string        `json:"members_error,omitempty"`
+}
+
+// GroupMember участник группы консьюмеров и его партиции
+type GroupMember struct {
+	MemberID   string `json:"member_id"`
+	ClientID   string `json:"client_id"`
+	Host       string `json:"host"`
+	Partitions []int  `json:"partitions"`
+	Self       bool   `json:"self"`
+}
+
+// PartitionStatus смещения группы в партиции
+type PartitionStatus struct {
+	Partition       int   `json:"partition"`
+	CommittedOffset int64 `json:"committed_offset"`
+	HighWaterMark   int64 `json:"high_water_mark"`
+	Lag             int64 `json:"lag"`
+}
+
+// ReaderStats счетчики kafka.Reader с момента запуска
+type ReaderStats struct {
+	Messages   int64 `json:"messages"`
+	Fetches    int64 `json:"fetches"`
+	Dials      int64 `json:"dials"`
+	Errors     int64 `json:"errors"`
+	Timeouts   int64 `json:"timeouts"`
+	Rebalances int64 `json:"rebalances"`
 }
 
 type PostgresConfig struct {
@@ -23,6 +90,90 @@ type PostgresConfig struct {
 type HttpServerConfig struct {
 	Address     string        `yaml:"ADDRESS"`
 	Timeout     time.Duration `yaml:"TIMEOUT"`
+	// SchemaValidation проверять тело POST запросов по JSON Schema
+	SchemaValidation bool `yaml:"SCHEMA_VALIDATION"`
+	// CorsAllowedOrigins источники, которым разрешены запросы из браузера.
+	// Встроенному фронтенду CORS не нужен
+	CorsAllowedOrigins []string `yaml:"CORS_ALLOWED_ORIGINS"`
+	// RateLimits лимиты по группам маршрутов: orders, public
+	RateLimits map[string]RateLimitConfig `yaml:"RATE_LIMITS"`
+}
+
+// RateLimitConfig token bucket: RPS токенов в секунду, не больше BURST в корзине
+type RateLimitConfig struct {
+	RPS   float64 `yaml:"RPS"`
+	Burst int     `yaml:"BURST"`
+}
+
+type AuthConfig struct {
+	// Enabled выключать только для локальной разработки
+	Enabled bool           `yaml:"ENABLED"`
+	APIKeys []APIKeyConfig `yaml:"API_KEYS"`
+	JWT     JWTConfig      `yaml:"JWT"`
+	// AnonymousRoles роли клиента при выключенной аутентификации
+	AnonymousRoles []string `yaml:"ANONYMOUS_ROLES"`
+}
+
+// APIKeyConfig статический ключ. В конфиге хранится только sha256 ключа в hex
+type APIKeyConfig struct {
+	Name  string   `yaml:"NAME"`
+	Hash  string   `yaml:"HASH"`
+	Roles []string `yaml:"ROLES"`
+}
+
+type JWTConfig struct {
+	Issuer   string         `yaml:"ISSUER"`
+	Audience string         `yaml:"AUDIENCE"`
+	Keys     []JWTKeyConfig `yaml:"KEYS"`
+}
+
+// MaskingConfig переопределяет правила видимости персональных данных:
+// роль -> поле (delivery.phone, ...) -> full|partial|hidden
+type MaskingConfig struct {
+	Rules map[string]map[string]string `yaml:"RULES"`
+}
+
+// JWTKeyConfig ключ проверки подписи: секрет для HS256 или публичный ключ для RS256
+type JWTKeyConfig struct {
+	KID           string `yaml:"KID"`
+	Alg           string `yaml:"ALG"`
+	Secret        string `yaml:"SECRET"`
+	PublicKeyFile string `yaml:"PUBLIC_KEY_FILE"`
+}
+
+// LogConfig уровень (debug, info, warn, error) и формат (json, text) логов
+type LogConfig struct {
+	Level  string `yaml:"LEVEL" env-default:"info"`
+	Format string `yaml:"FORMAT" env-default:"json"`
+}
+
+// TracingConfig трассировка OpenTelemetry. EXPORTER: otlp (OTLP/HTTP на ENDPOINT)
+// или stdout для локальной отладки. SAMPLE_RATIO доля новых трасс, 0..1
+type TracingConfig struct {
+	Enabled     bool    `yaml:"ENABLED"`
+	Exporter    string  `yaml:"EXPORTER" env-default:"otlp"`
+	Endpoint    string  `yaml:"ENDPOINT" env-default:"localhost:4318"`
+	Insecure    bool    `yaml:"INSECURE" env-default:"true"`
+	ServiceName string  `yaml:"SERVICE_NAME" env-default:"demoserv"`
+	SampleRatio float64 `yaml:"SAMPLE_RATIO" env-default:"1"`
+}
+
+// AuditConfig журнал доступа к заказам. Записи копятся в буфере
+// и пишутся в базу пачками по BATCH_SIZE или раз в FLUSH_INTERVAL
+type AuditConfig struct {
+	Enabled       bool          `yaml:"ENABLED"`
+	BufferSize    int           `yaml:"BUFFER_SIZE" env-default:"10000"`
+	BatchSize     int           `yaml:"BATCH_SIZE" env-default:"100"`
+	FlushInterval time.Duration `yaml:"FLUSH_INTERVAL" env-default:"1s"`
+}
+
+// EncryptionConfig шифрование персональных данных в базе.
+// Мастер-ключ (32 байта в base64) читается из файла или из переменной окружения
+type EncryptionConfig struct {
+	Enabled       bool   `yaml:"ENABLED"`
+	MasterKeyID   string `yaml:"MASTER_KEY_ID"`
+	MasterKeyFile string `yaml:"MASTER_KEY_FILE"`
+	MasterKeyEnv  string `yaml:"MASTER_KEY_ENV" env-default:"DEMOSERV_MASTER_KEY"`
 }
 
 
@@ -80,3 +231,279 @@ type Item struct {
 	Brand       string `json:"brand"`
 	Status      int    `json:"status"`
 }
+
+// CustomerExport все заказы клиента для выгрузки по запросу на доступ к данным
+type CustomerExport struct {
+	CustomerID string    `json:"customer_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Orders     []Order   `json:"orders"`
+}
+
+// AuditEntry запись журнала доступа
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	Action    string    `json:"action"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Path      string    `json:"path"`
+	OrderUID  string    `json:"order_uid,omitempty"`
+	Status    int       `json:"status"`
+	SourceIP  string    `json:"source_ip"`
+}
+
+// AuditFilter условия выборки из журнала доступа. Пустые поля не фильтруют
+type AuditFilter struct {
+	Principal string
+	OrderUID  string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// SearchQuery параметры полнотекстового поиска заказов
+type SearchQuery struct {
+	Text   string
+	Prefix bool // последнее слово ищется как префикс, для подсказок при вводе
+	Limit  int
+	Offset int
+}
+
+// SearchResult найденный заказ с фрагментами, в которых нашлись слова
+type SearchResult struct {
+	OrderUID    string          `json:"order_uid"`
+	TrackNumber string          `json:"track_number"`
+	CustomerID  string          `json:"customer_id"`
+	DateCreated time.Time       `json:"date_created"`
+	Rank        float64         `json:"rank"`
+	Snippets    []SearchSnippet `json:"snippets"`
+}
+
+// SearchSnippet фрагмент поля, найденные слова выделены <mark></mark>
+type SearchSnippet struct {
+	Field string `json:"field"`
+	Text  string `json:"text"`
+}
+
+// CustomerOrder заказ в истории клиента
+type CustomerOrder struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	DateCreated     time.Time `json:"date_created"`
+	DeliveryService string    `json:"delivery_service"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	Items           int       `json:"items"`
+}
+
+// CustomerStats сводка по всем заказам клиента
+type CustomerStats struct {
+	TotalOrders        int              `json:"total_orders"`
+	TotalSpent         map[string]int64 `json:"total_spent"` // валюта -> сумма payment.amount
+	FirstOrderAt       *time.Time       `json:"first_order_at"`
+	LastOrderAt        *time.Time       `json:"last_order_at"`
+	TopDeliveryService string           `json:"top_delivery_service"`
+}
+
+// Отчеты аналитики
+const (
+	ReportSales            = "sales"
+	ReportBrands           = "brands"
+	ReportDeliveryServices = "delivery-services"
+	ReportBanks            = "banks"
+	ReportProviders        = "providers"
+)
+
+// Интервалы отчетов, значения для date_trunc
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// AnalyticsQuery параметры отчета: интервалы bucket (hour, day, week) в [From, To)
+type AnalyticsQuery struct {
+	Report   string
+	Bucket   string
+	From     time.Time
+	To       time.Time
+	Currency string // пусто — все валюты
+	Top      int    // сколько первых по выручке значений оставить в каждом интервале, 0 — все
+	Rollups  bool   // читать из сводных таблиц, если отчет и период это позволяют
+}
+
+// AnalyticsRow строка отчета: интервал, значение разреза и валюта.
+// Суммы в разных валютах не складываются
+type AnalyticsRow struct {
+	Bucket    time.Time `json:"bucket"`
+	Key       string    `json:"key,omitempty"`
+	Currency  string    `json:"currency"`
+	Orders    int64     `json:"orders"`
+	Items     int64     `json:"items"`
+	Revenue   int64     `json:"revenue"`
+	AvgBasket float64   `json:"avg_basket"`
+}
+
+// RollupTotals агрегаты строки сводной таблицы
+type RollupTotals struct {
+	Orders  int64 `json:"orders"`
+	Items   int64 `json:"items"`
+	Revenue int64 `json:"revenue"`
+}
+
+// RollupMismatch строка сводной таблицы, которая не совпала с агрегатами по сырым таблицам.
+// Нулевые Rollup или Raw — строки нет с этой стороны
+type RollupMismatch struct {
+	Table    string       `json:"table"`
+	Day      time.Time    `json:"day"`
+	Key      string       `json:"key"`
+	Currency string       `json:"currency"`
+	Rollup   RollupTotals `json:"rollup"`
+	Raw      RollupTotals `json:"raw"`
+}
+
+// RollupsConfig сводные таблицы продаж по дням. ANALYTICS — отчеты по дням и неделям
+// читаются из них (включать после rollup-backfill). Раз в REFRESH_INTERVAL пересчитываются
+// последние REFRESH_DAYS дней, 0 — только обновление при вставке заказа
+type RollupsConfig struct {
+	Analytics       bool          `yaml:"ANALYTICS"`
+	RefreshInterval time.Duration `yaml:"REFRESH_INTERVAL"`
+	RefreshDays     int           `yaml:"REFRESH_DAYS" env-default:"2"`
+}
+
+// PartitionTableStatus перенос одной таблицы: строк в старой таблице и уже в секционированной
+type PartitionTableStatus struct {
+	Table  string `json:"table"`
+	Rows   int64  `json:"rows"`
+	Copied int64  `json:"copied"`
+}
+
+// PartitionMigration состояние переноса заказов в секционированные таблицы.
+// Prepared — копии *_part созданы, Swapped — таблицы уже подменены.
+// Tables заполнен только между подготовкой и подменой
+type PartitionMigration struct {
+	Prepared bool                   `json:"prepared"`
+	Swapped  bool                   `json:"swapped"`
+	Tables   []PartitionTableStatus `json:"tables"`
+}
+
+// PartitionsConfig создание месячных секций таблиц заказов заранее:
+// при ENABLED раз в CHECK_INTERVAL создаются секции на MONTHS_AHEAD месяцев вперед.
+// Включается после demoservctl partition-prepare
+type PartitionsConfig struct {
+	Enabled       bool          `yaml:"ENABLED"`
+	MonthsAhead   int           `yaml:"MONTHS_AHEAD" env-default:"3"`
+	CheckInterval time.Duration `yaml:"CHECK_INTERVAL" env-default:"24h"`
+}
+
+// ArchiveConfig архивация старых заказов. Раз в INTERVAL заказы старше RETENTION_DAYS
+// выгружаются пачками по BATCH_SIZE в хранилище STORAGE (local — каталог DIR, s3 — бакет S3)
+// и удаляются из базы
+type ArchiveConfig struct {
+	Enabled       bool          `yaml:"ENABLED"`
+	RetentionDays int           `yaml:"RETENTION_DAYS" env-default:"365"`
+	Interval      time.Duration `yaml:"INTERVAL" env-default:"24h"`
+	BatchSize     int           `yaml:"BATCH_SIZE" env-default:"1000"`
+	Storage       string        `yaml:"STORAGE" env-default:"local"`
+	Dir           string        `yaml:"DIR" env-default:"archive"`
+	S3            S3Config      `yaml:"S3"`
+}
+
+// S3Config S3-совместимое хранилище (AWS S3, MinIO). Адреса в стиле path: ENDPOINT/BUCKET/ключ.
+// Секретный ключ читается из переменной окружения SECRET_KEY_ENV
+type S3Config struct {
+	Endpoint     string `yaml:"ENDPOINT"`
+	Region       string `yaml:"REGION" env-default:"us-east-1"`
+	Bucket       string `yaml:"BUCKET"`
+	Prefix       string `yaml:"PREFIX"`
+	AccessKey    string `yaml:"ACCESS_KEY"`
+	SecretKeyEnv string `yaml:"SECRET_KEY_ENV" env-default:"DEMOSERV_S3_SECRET_KEY"`
+}
+
+// ArchivedOrder заказ в архиве. Поля delivery зашифрованы ключом данных DeliveryKeyID,
+// как в базе; пустой DeliveryKeyID — поля открытым текстом
+type ArchivedOrder struct {
+	Order
+	DeliveryKeyID string `json:"delivery_key_id,omitempty"`
+}
+
+// ArchiveObject проверенный файл архива в хранилище
+type ArchiveObject struct {
+	Key      string
+	Storage  string
+	Location string
+	Format   string
+	Size     int64
+	SHA256   string
+}
+
+// Статусы файлов архива: restored — все заказы файла снова в базе
+const (
+	ArchiveStatusArchived = "archived"
+	ArchiveStatusRestored = "restored"
+)
+
+// ArchiveManifest запись о файле архива в archive_manifest
+type ArchiveManifest struct {
+	ID             int64      `json:"id"`
+	Key            string     `json:"key"`
+	Storage        string     `json:"storage"`
+	Location       string     `json:"location"`
+	Format         string     `json:"format"`
+	Status         string     `json:"status"`
+	Cutoff         time.Time  `json:"cutoff"`
+	Orders         int        `json:"orders"`
+	MinDateCreated *time.Time `json:"min_date_created"`
+	MaxDateCreated *time.Time `json:"max_date_created"`
+	Size           int64      `json:"size"`
+	SHA256         string     `json:"sha256"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// RestoreReport результат восстановления заказов из архива
+type RestoreReport struct {
+	// Manifests id файлов архива, из которых читались заказы
+	Manifests []int64 `json:"manifests"`
+	// Restored заказы, возвращенные в базу
+	Restored []string `json:"restored"`
+	// Skipped заказы, которые уже были в базе
+	Skipped []string `json:"skipped"`
+	// NotFound запрошенные заказы, которых нет в архиве
+	NotFound []string `json:"not_found"`
+	// Anonymized восстановленные заказы обезличенных клиентов, данные доставки затерты
+	Anonymized []string `json:"anonymized"`
+}
+
+// ImportReport результат загрузки заказов из файла
+type ImportReport struct {
+	// Read прочитано записей
+	Read int `json:"read"`
+	// Inserted новые заказы
+	Inserted int `json:"inserted"`
+	// Duplicates заказы, которые уже были в базе
+	Duplicates int `json:"duplicates"`
+	// Invalid записи, которые не разбираются или не проходят проверку
+	Invalid int `json:"invalid"`
+	// Failed корректные заказы, которые не удалось вставить
+	Failed int `json:"failed"`
+	// Errors первые ошибки по отдельным записям
+	Errors []ImportError `json:"errors"`
+}
+
+// ImportError ошибка одной записи файла, Record с 1
+type ImportError struct {
+	Record   int    `json:"record"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+}
+
+// OrderFilter условия выгрузки заказов. Пустые поля не фильтруют
+type OrderFilter struct {
+	From            time.Time
+	To              time.Time
+	CustomerID      string
+	DeliveryService string
+	Limit           int
+}
@@ -0,0 +1,133 @@
+package orderfile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"demoserv/internal/models"
+	"demoserv/internal/validate"
+)
+
+// maxImportErrors сколько ошибок по отдельным заказам попадает в отчет
+const maxImportErrors = 100
+
+// InsertFunc вставляет пачку заказов в одной транзакции, например postgress.InsertOrders.
+// Возвращает число новых заказов, остальные уже были в базе
+type InsertFunc func(ctx context.Context, orders []models.Order) (int, error)
+
+type record struct {
+	n     int
+	order models.Order
+}
+
+// importer общий отчет читателя и воркеров
+type importer struct {
+	mu     sync.Mutex
+	report models.ImportReport
+	insert InsertFunc
+}
+
+// Import читает заказы из r, проверяет их validate.ValidateOrder и вставляет пачками
+// по batchSize в workers потоков. Если пачка не вставилась, ее заказы вставляются по одному,
+// чтобы найти неудачные. Ошибка возвращается, только если файл не дочитан; отчет
+// в этом случае описывает уже обработанные заказы
+func Import(ctx context.Context, r *Reader, batchSize, workers int, insert InsertFunc) (models.ImportReport, error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	im := &importer{insert: insert, report: models.ImportReport{Errors: []models.ImportError{}}}
+
+	batches := make(chan []record)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				im.insertBatch(ctx, batch)
+			}
+		}()
+	}
+
+	var readErr error
+	batch := make([]record, 0, batchSize)
+	for ctx.Err() == nil {
+		order, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recErr *RecordError
+		if err != nil && !errors.As(err, &recErr) {
+			readErr = err
+			break
+		}
+		im.mu.Lock()
+		im.report.Read++
+		im.mu.Unlock()
+		if recErr != nil {
+			im.fail(&im.report.Invalid, models.ImportError{Record: recErr.Record, Error: recErr.Err.Error()})
+			continue
+		}
+		if err := validate.ValidateOrder(order); err != nil {
+			im.fail(&im.report.Invalid, models.ImportError{Record: r.Record(), OrderUID: order.OrderUID, Error: err.Error()})
+			continue
+		}
+
+		batch = append(batch, record{n: r.Record(), order: order})
+		if len(batch) == batchSize {
+			batches <- batch
+			batch = make([]record, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 && ctx.Err() == nil {
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	if readErr == nil {
+		readErr = ctx.Err()
+	}
+	return im.report, readErr
+}
+
+func (im *importer) insertBatch(ctx context.Context, batch []record) {
+	orders := make([]models.Order, len(batch))
+	for i, rec := range batch {
+		orders[i] = rec.order
+	}
+	if n, err := im.insert(ctx, orders); err == nil {
+		im.mu.Lock()
+		im.report.Inserted += n
+		im.report.Duplicates += len(orders) - n
+		im.mu.Unlock()
+		return
+	}
+
+	// пачка откатилась целиком, заказы вставляются по одному
+	for _, rec := range batch {
+		n, err := im.insert(ctx, []models.Order{rec.order})
+		if err != nil {
+			im.fail(&im.report.Failed, models.ImportError{Record: rec.n, OrderUID: rec.order.OrderUID, Error: err.Error()})
+			continue
+		}
+		im.mu.Lock()
+		im.report.Inserted += n
+		im.report.Duplicates += 1 - n
+		im.mu.Unlock()
+	}
+}
+
+func (im *importer) fail(counter *int, e models.ImportError) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	*counter++
+	if len(im.report.Errors) < maxImportErrors {
+		im.report.Errors = append(im.report.Errors, e)
+	}
+}
@@ -0,0 +1,206 @@
+package orderfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"demoserv/internal/message"
+	"demoserv/internal/models"
+)
+
+// Форматы выгрузки
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// RecordError запись файла не разбирается как заказ. Чтение можно продолжить
+type RecordError struct {
+	Record int
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader читает заказы из JSON массива, NDJSON или нескольких JSON объектов подряд
+// (как test.json). Сжатие gzip определяется по содержимому файла
+type Reader struct {
+	dec    *json.Decoder
+	array  bool
+	done   bool
+	strict bool
+	record int
+	closer io.Closer
+}
+
+// NewReader определяет формат по первым байтам r. В strict режиме неизвестные поля
+// заказа считаются ошибкой записи
+func NewReader(r io.Reader, strict bool) (*Reader, error) {
+	rd := &Reader{strict: strict}
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		rd.closer = zr
+		src = zr
+	}
+
+	// JSON массив определяется по первому символу, кроме пробелов
+	pr := bufio.NewReader(src)
+	for {
+		c, err := pr.ReadByte()
+		if errors.Is(err, io.EOF) {
+			rd.done = true
+			return rd, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read orders: %w", err)
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		rd.array = c == '['
+		pr.UnreadByte()
+		break
+	}
+	rd.dec = json.NewDecoder(pr)
+	if rd.array {
+		if _, err := rd.dec.Token(); err != nil {
+			return nil, fmt.Errorf("read orders: %w", err)
+		}
+	}
+	return rd, nil
+}
+
+// Next следующий заказ, io.EOF — заказы кончились. Если запись не разбирается как заказ,
+// возвращается *RecordError; ошибка синтаксиса JSON прерывает чтение
+func (r *Reader) Next() (models.Order, error) {
+	if r.done {
+		return models.Order{}, io.EOF
+	}
+	if !r.dec.More() {
+		r.done = true
+		if r.array {
+			if _, err := r.dec.Token(); err != nil {
+				return models.Order{}, fmt.Errorf("read orders after record %d: %w", r.record, err)
+			}
+		}
+		if r.dec.More() {
+			return models.Order{}, fmt.Errorf("unexpected data after record %d", r.record)
+		}
+		return models.Order{}, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := r.dec.Decode(&raw); err != nil {
+		r.done = true
+		return models.Order{}, fmt.Errorf("record %d at byte %d: %w", r.record+1, r.dec.InputOffset(), err)
+	}
+	r.record++
+	var order models.Order
+	if err := message.Unmarshal(raw, &order, r.strict); err != nil {
+		return models.Order{}, &RecordError{Record: r.record, Err: err}
+	}
+	return order, nil
+}
+
+// Record номер последней прочитанной записи, с 1
+func (r *Reader) Record() int {
+	return r.record
+}
+
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// Writer пишет заказы в файл выгрузки. Close дописывает буферы, но не закрывает сам файл
+type Writer interface {
+	Write(order models.Order) error
+	Close() error
+}
+
+// NewWriter создает Writer формата FormatNDJSON или FormatCSV, при gz — со сжатием gzip.
+// В CSV попадает сводка заказа без персональных данных
+func NewWriter(w io.Writer, format string, gz bool) (Writer, error) {
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w), zw: zw}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw, zw: zw}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatNDJSON, FormatCSV)
+	}
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+	zw  *gzip.Writer
+}
+
+func (w *ndjsonWriter) Write(order models.Order) error {
+	return w.enc.Encode(order)
+}
+
+func (w *ndjsonWriter) Close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"order_uid", "track_number", "date_created", "customer_id", "delivery_service", "locale",
+	"currency", "amount", "delivery_cost", "goods_total", "provider", "bank", "items",
+}
+
+type csvWriter struct {
+	w  *csv.Writer
+	zw *gzip.Writer
+}
+
+func (w *csvWriter) Write(o models.Order) error {
+	return w.w.Write([]string{
+		o.OrderUID, o.TrackNumber, o.DateCreated.UTC().Format(time.RFC3339), o.CustomerID, o.DeliveryService, o.Locale,
+		o.Payment.Currency, strconv.Itoa(o.Payment.Amount), strconv.Itoa(o.Payment.DeliveryCost),
+		strconv.Itoa(o.Payment.GoodsTotal), o.Payment.Provider, o.Payment.Bank, strconv.Itoa(len(o.Items)),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
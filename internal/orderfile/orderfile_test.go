@@ -0,0 +1,254 @@
+package orderfile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"demoserv/internal/models"
+	"demoserv/internal/orderfile"
+)
+
+func readTestOrder(t *testing.T, uid string) models.Order {
+	t.Helper()
+	data, err := os.ReadFile("../../test.json")
+	if err != nil {
+		t.Fatalf("read test.json: %v", err)
+	}
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatalf("decode test.json: %v", err)
+	}
+	order.OrderUID = uid
+	return order
+}
+
+func ndjson(t *testing.T, orders ...models.Order) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	}
+	return buf.String()
+}
+
+func readAll(t *testing.T, in io.Reader) ([]string, []int) {
+	t.Helper()
+	r, err := orderfile.NewReader(in, false)
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	defer r.Close()
+
+	var uids []string
+	var bad []int
+	for {
+		order, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return uids, bad
+		}
+		var recErr *orderfile.RecordError
+		if errors.As(err, &recErr) {
+			bad = append(bad, recErr.Record)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		uids = append(uids, order.OrderUID)
+	}
+}
+
+func TestReader_Formats(t *testing.T) {
+	a, b := readTestOrder(t, "a"), readTestOrder(t, "b")
+	array, _ := json.Marshal([]models.Order{a, b})
+	pretty, _ := json.MarshalIndent(a, "", "  ")
+	pretty2, _ := json.MarshalIndent(b, "", "  ")
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(ndjson(t, a, b)))
+	zw.Close()
+
+	tests := map[string]io.Reader{
+		"array":  bytes.NewReader(append([]byte("\n  "), array...)),
+		"ndjson": strings.NewReader(ndjson(t, a, b)),
+		"pretty": strings.NewReader(string(pretty) + "\n" + string(pretty2)),
+		"gzip":   &gz,
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			uids, bad := readAll(t, in)
+			if len(bad) != 0 || len(uids) != 2 || uids[0] != "a" || uids[1] != "b" {
+				t.Fatalf("uids %v, bad records %v", uids, bad)
+			}
+		})
+	}
+}
+
+func TestReader_RecordError(t *testing.T) {
+	in := ndjson(t, readTestOrder(t, "a")) + `{"order_uid": 42}` + "\n" + ndjson(t, readTestOrder(t, "c"))
+
+	uids, bad := readAll(t, strings.NewReader(in))
+	if len(uids) != 2 || len(bad) != 1 || bad[0] != 2 {
+		t.Fatalf("uids %v, bad records %v", uids, bad)
+	}
+}
+
+func TestReader_SyntaxError(t *testing.T) {
+	r, err := orderfile.NewReader(strings.NewReader(ndjson(t, readTestOrder(t, "a"))+"{broken"), false)
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("first record: %v", err)
+	}
+	_, err = r.Next()
+	var recErr *orderfile.RecordError
+	if err == nil || errors.Is(err, io.EOF) || errors.As(err, &recErr) {
+		t.Fatalf("expected fatal syntax error, got %v", err)
+	}
+}
+
+func TestWriter_NDJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := orderfile.NewWriter(&buf, orderfile.FormatNDJSON, true)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for _, uid := range []string{"a", "b"} {
+		if err := w.Write(readTestOrder(t, uid)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	uids, _ := readAll(t, &buf)
+	if len(uids) != 2 || uids[0] != "a" || uids[1] != "b" {
+		t.Fatalf("unexpected uids %v", uids)
+	}
+}
+
+func TestWriter_CSV(t *testing.T) {
+	order := readTestOrder(t, "a")
+	var buf bytes.Buffer
+	w, err := orderfile.NewWriter(&buf, orderfile.FormatCSV, false)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	if err := w.Write(order); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0][0] != "order_uid" || rows[1][0] != "a" || len(rows[1]) != len(rows[0]) {
+		t.Fatalf("unexpected csv %v", rows)
+	}
+	if strings.Contains(buf.String(), order.Delivery.Phone) {
+		t.Fatal("csv export contains delivery phone")
+	}
+}
+
+func TestNewWriter_UnknownFormat(t *testing.T) {
+	if _, err := orderfile.NewWriter(io.Discard, "xml", false); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+// fakeDB вставляет заказы, которых еще не было, и роняет пачки с заказом failUID
+type fakeDB struct {
+	mu      sync.Mutex
+	orders  map[string]bool
+	batches int
+	failUID string
+}
+
+func (db *fakeDB) insert(_ context.Context, orders []models.Order) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.batches++
+	for _, o := range orders {
+		if o.OrderUID == db.failUID {
+			return 0, fmt.Errorf("insert %s failed", o.OrderUID)
+		}
+	}
+	n := 0
+	for _, o := range orders {
+		if !db.orders[o.OrderUID] {
+			db.orders[o.OrderUID] = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestImport(t *testing.T) {
+	var orders []models.Order
+	for i := range 10 {
+		orders = append(orders, readTestOrder(t, fmt.Sprintf("order-%d", i)))
+	}
+	invalid := readTestOrder(t, "")
+	in := ndjson(t, orders...) + ndjson(t, invalid) + "[1]\n"
+
+	db := &fakeDB{orders: map[string]bool{"order-0": true}, failUID: "order-7"}
+	r, err := orderfile.NewReader(strings.NewReader(in), false)
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	report, err := orderfile.Import(context.Background(), r, 3, 2, db.insert)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	if report.Read != 12 || report.Inserted != 8 || report.Duplicates != 1 || report.Invalid != 2 || report.Failed != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got %+v", report.Errors)
+	}
+	for _, e := range report.Errors {
+		if e.OrderUID == "order-7" && e.Record != 8 {
+			t.Fatalf("failed order reported at record %d", e.Record)
+		}
+	}
+	// 4 пачки и 3 повтора по одному для пачки с order-7
+	if db.batches != 7 {
+		t.Fatalf("expected 7 insert calls, got %d", db.batches)
+	}
+}
+
+func TestImport_ReadError(t *testing.T) {
+	in := ndjson(t, readTestOrder(t, "a")) + "{broken"
+	db := &fakeDB{orders: map[string]bool{}}
+	r, err := orderfile.NewReader(strings.NewReader(in), false)
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	report, err := orderfile.Import(context.Background(), r, 10, 1, db.insert)
+	if err == nil {
+		t.Fatal("expected read error")
+	}
+	if report.Inserted != 1 {
+		t.Fatalf("orders read before the error must be inserted, got %+v", report)
+	}
+}
@@ -0,0 +1,75 @@
+package partition
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+)
+
+// CreateFunc создает месячные секции на months месяцев, начиная с месяца from,
+// и возвращает число созданных, например postgress.CreateOrderPartitions
+type CreateFunc func(ctx context.Context, from time.Time, months int) (int, error)
+
+// Maintainer заранее создает секции таблиц заказов на следующие месяцы,
+// чтобы новые заказы не попадали в секцию по умолчанию
+type Maintainer struct {
+	create        CreateFunc
+	monthsAhead   int
+	checkInterval time.Duration
+	log           *slog.Logger
+}
+
+// New создает задачу. Секции создаются после запуска Run
+func New(cfg models.PartitionsConfig, create CreateFunc, log *slog.Logger) *Maintainer {
+	if cfg.MonthsAhead <= 0 {
+		cfg.MonthsAhead = 3
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 24 * time.Hour
+	}
+	return &Maintainer{
+		create:        create,
+		monthsAhead:   cfg.MonthsAhead,
+		checkInterval: cfg.CheckInterval,
+		log:           log,
+	}
+}
+
+// Months с какого месяца и на сколько месяцев нужны секции: текущий месяц по UTC и ahead следующих
+func Months(now time.Time, ahead int) (from time.Time, months int) {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), ahead + 1
+}
+
+// Ensure создает недостающие секции
+func (m *Maintainer) Ensure(ctx context.Context) error {
+	from, months := Months(time.Now(), m.monthsAhead)
+	created, err := m.create(ctx, from, months)
+	if err != nil {
+		return err
+	}
+	if created > 0 {
+		m.log.Info("order partitions created", slog.Int("partitions", created),
+			slog.String("from", from.Format("2006-01")), slog.Int("months", months))
+	}
+	return nil
+}
+
+// Run проверяет секции сразу и затем раз в checkInterval до отмены ctx
+func (m *Maintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.Ensure(ctx); err != nil && ctx.Err() == nil {
+			m.log.Warn("unable to create order partitions", sl.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
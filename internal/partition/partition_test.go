@@ -0,0 +1,46 @@
+package partition_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"demoserv/internal/models"
+	"demoserv/internal/partition"
+)
+
+func TestMonths(t *testing.T) {
+	// по Москве уже январь, по UTC еще декабрь
+	now := time.Date(2027, 1, 1, 1, 30, 0, 0, time.FixedZone("MSK", 3*3600))
+	from, months := partition.Months(now, 3)
+	if want := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC); !from.Equal(want) || months != 4 {
+		t.Fatalf("expected %v and 4 months, got %v and %d", want, from, months)
+	}
+}
+
+func TestMaintainer_Ensure(t *testing.T) {
+	var gotMonths int
+	m := partition.New(models.PartitionsConfig{MonthsAhead: 2}, func(_ context.Context, from time.Time, months int) (int, error) {
+		if from.Day() != 1 || from.Hour() != 0 {
+			t.Fatalf("expected the first day of month, got %v", from)
+		}
+		gotMonths = months
+		return 12, nil
+	}, slog.New(slog.DiscardHandler))
+
+	if err := m.Ensure(context.Background()); err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if gotMonths != 3 {
+		t.Fatalf("expected 3 months, got %d", gotMonths)
+	}
+
+	failing := partition.New(models.PartitionsConfig{}, func(context.Context, time.Time, int) (int, error) {
+		return 0, errors.New("db is down")
+	}, slog.New(slog.DiscardHandler))
+	if err := failing.Ensure(context.Background()); err == nil {
+		t.Fatal("expected error from create")
+	}
+}
@@ -0,0 +1,119 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// analyticsOrders заказы с оплатой и числом товаров
+const analyticsOrders = `
+	FROM orders o
+	JOIN payment p ON p.order_uid = o.order_uid
+	LEFT JOIN LATERAL (SELECT count(*) AS n FROM items i WHERE i.order_uid = o.order_uid) ic ON true`
+
+// analyticsItems товары с заказом и оплатой
+const analyticsItems = `
+	FROM items i
+	JOIN orders o ON o.order_uid = i.order_uid
+	JOIN payment p ON p.order_uid = o.order_uid`
+
+// analyticsReport как считается отчет: разрез, источник и агрегаты.
+// rollup — сводная таблица по дням, из которой можно собрать тот же отчет, rollupKey — разрез в ней
+type analyticsReport struct {
+	key       string
+	from      string
+	orders    string
+	items     string
+	revenue   string
+	rollup    string
+	rollupKey string
+}
+
+var analyticsReports = map[string]analyticsReport{
+	models.ReportSales:            {key: "''", from: analyticsOrders, orders: "count(*)", items: "sum(ic.n)", revenue: "sum(p.amount)", rollup: "daily_delivery_sales", rollupKey: "''"},
+	models.ReportDeliveryServices: {key: "COALESCE(o.delivery_service, '')", from: analyticsOrders, orders: "count(*)", items: "sum(ic.n)", revenue: "sum(p.amount)", rollup: "daily_delivery_sales", rollupKey: "r.delivery_service"},
+	models.ReportBanks:            {key: "COALESCE(p.bank, '')", from: analyticsOrders, orders: "count(*)", items: "sum(ic.n)", revenue: "sum(p.amount)"},
+	models.ReportProviders:        {key: "COALESCE(p.provider, '')", from: analyticsOrders, orders: "count(*)", items: "sum(ic.n)", revenue: "sum(p.amount)", rollup: "daily_provider_sales", rollupKey: "r.provider"},
+	// выручка бренда — сумма total_price его товаров
+	models.ReportBrands: {key: "COALESCE(i.brand, '')", from: analyticsItems, orders: "count(DISTINCT o.order_uid)", items: "count(*)", revenue: "sum(i.total_price)", rollup: "daily_brand_sales", rollupKey: "r.brand"},
+}
+
+// aggregate агрегаты отчета по сырым таблицам с группировкой по bucket, разрезу и валюте
+func (r analyticsReport) aggregate(bucket, where string) string {
+	return fmt.Sprintf(`
+		SELECT %s AS bucket, %s AS key, COALESCE(p.currency, '') AS currency,
+		       %s::bigint AS orders, COALESCE(%s, 0)::bigint AS items, COALESCE(%s, 0)::bigint AS revenue
+		%s
+		WHERE %s
+		GROUP BY 1, 2, 3`, bucket, r.key, r.orders, r.items, r.revenue, r.from, where)
+}
+
+// useRollup можно ли собрать отчет из сводной таблицы: интервалы не меньше дня, границы периода по дням
+func (r analyticsReport) useRollup(q models.AnalyticsQuery) bool {
+	return q.Rollups && r.rollup != "" && q.Bucket != models.BucketHour && isDay(q.From) && isDay(q.To)
+}
+
+func isDay(t time.Time) bool {
+	return t.Equal(t.Truncate(24 * time.Hour))
+}
+
+// GetAnalytics считает отчет по интервалам и валютам, внутри интервала строки идут по убыванию выручки.
+// С q.Rollups отчеты по дням и неделям читаются из сводных таблиц
+func GetAnalytics(ctx context.Context, pool *pgxpool.Pool, q models.AnalyticsQuery) ([]models.AnalyticsRow, error) {
+	report, ok := analyticsReports[q.Report]
+	if !ok {
+		return nil, fmt.Errorf("unknown report %q", q.Report)
+	}
+	switch q.Bucket {
+	case models.BucketHour, models.BucketDay, models.BucketWeek:
+	default:
+		return nil, fmt.Errorf("unknown bucket %q", q.Bucket)
+	}
+
+	source := report.aggregate("date_trunc($1, o.date_created)",
+		"o.date_created >= $2 AND o.date_created < $3 AND ($4 = '' OR p.currency = $4)")
+	if report.useRollup(q) {
+		source = fmt.Sprintf(`
+		SELECT date_trunc($1, r.day::timestamp) AS bucket, %s AS key, r.currency,
+		       sum(r.orders)::bigint AS orders, sum(r.items)::bigint AS items, sum(r.revenue)::bigint AS revenue
+		FROM %s r
+		WHERE r.day >= $2 AND r.day < $3 AND ($4 = '' OR r.currency = $4)
+		GROUP BY 1, 2, 3`, report.rollupKey, report.rollup)
+	}
+
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT bucket, key, currency, orders, items, revenue FROM (
+			SELECT g.*, row_number() OVER (PARTITION BY bucket, currency ORDER BY revenue DESC, key) AS rn
+			FROM (%s) g
+		) t
+		WHERE $5 = 0 OR rn <= $5
+		ORDER BY bucket, currency, revenue DESC, key
+	`, source),
+		q.Bucket, q.From, q.To, q.Currency, q.Top)
+	if err != nil {
+		return nil, fmt.Errorf("select %s report: %w", q.Report, err)
+	}
+	defer rows.Close()
+
+	result := []models.AnalyticsRow{}
+	for rows.Next() {
+		var r models.AnalyticsRow
+		if err := rows.Scan(&r.Bucket, &r.Key, &r.Currency, &r.Orders, &r.Items, &r.Revenue); err != nil {
+			return nil, fmt.Errorf("scan %s report: %w", q.Report, err)
+		}
+		if r.Orders > 0 {
+			r.AvgBasket = math.Round(float64(r.Revenue)/float64(r.Orders)*100) / 100
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select %s report: %w", q.Report, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,262 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// manifestColumns столбцы archive_manifest в порядке scanManifest
+const manifestColumns = `id, object_key, storage, location, format, status, cutoff, orders,
+	min_date_created, max_date_created, size_bytes, sha256, created_at`
+
+// ArchiveOrders выгружает до limit заказов с date_created раньше cutoff, от старых к новым.
+// Заказы, восстановленные из архива позже cutoff, пропускаются.
+// Заказы блокируются, передаются в upload с зашифрованной активным ключом доставкой и после
+// успешной загрузки удаляются в одной транзакции с записью в archive_manifest.
+// Если upload вернул ошибку, заказы остаются в базе. Возвращает uid удаленных заказов
+func ArchiveOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, cutoff time.Time, limit int,
+	upload func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error),
+) ([]string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT order_uid FROM orders o
+		WHERE date_created < $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM archive_restores r
+			WHERE r.order_uid = o.order_uid AND r.restored_at >= $1
+		  )
+		ORDER BY date_created, order_uid
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select orders to archive: %w", err)
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select orders to archive: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	// Доставка блокируется, чтобы ее не перешифровали и не обезличили во время выгрузки
+	if _, err := tx.Exec(ctx, `
+		SELECT 1 FROM delivery WHERE order_uid = ANY($1) FOR UPDATE
+	`, uids); err != nil {
+		return nil, fmt.Errorf("lock delivery: %w", err)
+	}
+
+	orders := make([]models.ArchivedOrder, 0, len(uids))
+	customers := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		order, err := GetOrder(ctx, uid, pool, c)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", uid, err)
+		}
+		delivery, keyID, _, err := encryptDelivery(ctx, tx, c, uid, order.Delivery)
+		if err != nil {
+			return nil, err
+		}
+		order.Delivery = delivery
+		archived := models.ArchivedOrder{Order: order}
+		if keyID != nil {
+			archived.DeliveryKeyID = *keyID
+		}
+		orders = append(orders, archived)
+		customers = append(customers, order.CustomerID)
+	}
+
+	obj, err := upload(ctx, orders)
+	if err != nil {
+		return nil, fmt.Errorf("upload archive: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO archive_manifest (
+			object_key, storage, location, format, status, cutoff, orders,
+			min_date_created, max_date_created, order_uids, customer_ids, size_bytes, sha256
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, obj.Key, obj.Storage, obj.Location, obj.Format, models.ArchiveStatusArchived, cutoff, len(orders),
+		orders[0].DateCreated, orders[len(orders)-1].DateCreated, uids, customers, obj.Size, obj.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("insert archive manifest: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM archive_restores WHERE order_uid = ANY($1)`, uids); err != nil {
+		return nil, fmt.Errorf("delete archive restores: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM orders WHERE order_uid = ANY($1)`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("delete archived orders: %w", err)
+	}
+	if tag.RowsAffected() != int64(len(uids)) {
+		return nil, fmt.Errorf("deleted %d archived orders, want %d", tag.RowsAffected(), len(uids))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return uids, nil
+}
+
+// ListArchiveManifests возвращает limit последних файлов архива
+func ListArchiveManifests(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models.ArchiveManifest, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+manifestColumns+`
+		FROM archive_manifest
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select archive manifest: %w", err)
+	}
+	return collectManifests(rows)
+}
+
+// GetArchiveManifest возвращает файл архива по id. Если его нет, ошибка содержит pgx.ErrNoRows
+func GetArchiveManifest(ctx context.Context, pool *pgxpool.Pool, id int64) (models.ArchiveManifest, error) {
+	m, err := scanManifest(pool.QueryRow(ctx, `
+		SELECT `+manifestColumns+` FROM archive_manifest WHERE id = $1
+	`, id))
+	if err != nil {
+		return m, fmt.Errorf("get archive manifest %d: %w", id, err)
+	}
+	return m, nil
+}
+
+// FindArchiveManifests возвращает файлы архива, в которых есть хотя бы один из заказов uids,
+// от новых к старым
+func FindArchiveManifests(ctx context.Context, pool *pgxpool.Pool, uids []string) ([]models.ArchiveManifest, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+manifestColumns+`
+		FROM archive_manifest
+		WHERE order_uids && $1
+		ORDER BY id DESC
+	`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("select archive manifest: %w", err)
+	}
+	return collectManifests(rows)
+}
+
+func collectManifests(rows pgx.Rows) ([]models.ArchiveManifest, error) {
+	defer rows.Close()
+	manifests := []models.ArchiveManifest{}
+	for rows.Next() {
+		m, err := scanManifest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan archive manifest: %w", err)
+		}
+		manifests = append(manifests, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select archive manifest: %w", err)
+	}
+	return manifests, nil
+}
+
+func scanManifest(row pgx.Row) (models.ArchiveManifest, error) {
+	var m models.ArchiveManifest
+	err := row.Scan(&m.ID, &m.Key, &m.Storage, &m.Location, &m.Format, &m.Status, &m.Cutoff,
+		&m.Orders, &m.MinDateCreated, &m.MaxDateCreated, &m.Size, &m.SHA256, &m.CreatedAt)
+	return m, err
+}
+
+// RestoreOrder возвращает заказ из файла архива manifestID в базу через ту же вставку, что и
+// InsertOrder, но без сводных таблиц: архивные заказы в них уже учтены. Заказ, который уже
+// есть в базе, пропускается (restored=false). Если клиента обезличили, данные доставки
+// затираются снова (anonymized=true)
+func RestoreOrder(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, order models.ArchivedOrder, manifestID int64, actor string) (restored, anonymized bool, err error) {
+	if order.DeliveryKeyID != "" {
+		if err := decryptDelivery(ctx, pool, c, order.OrderUID, &order.DeliveryKeyID, &order.Delivery); err != nil {
+			return false, false, err
+		}
+	}
+	err = pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM privacy_audit WHERE action = $1 AND customer_id = $2)
+	`, AuditAnonymize, order.CustomerID).Scan(&anonymized)
+	if err != nil {
+		return false, false, fmt.Errorf("check anonymization: %w", err)
+	}
+	if anonymized {
+		order.Delivery = models.Delivery{}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, false, fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, order.OrderUID).Scan(&exists); err != nil {
+		return false, false, fmt.Errorf("unable to check order: %v", err)
+	}
+	if exists {
+		return false, anonymized, nil
+	}
+	if restored, err = insertOrder(ctx, tx, c, &order.Order); err != nil || !restored {
+		return false, anonymized, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO archive_restores (order_uid, manifest_id, actor) VALUES ($1, $2, $3)
+		ON CONFLICT (order_uid) DO UPDATE
+		SET manifest_id = EXCLUDED.manifest_id, actor = EXCLUDED.actor, restored_at = now()
+	`, order.OrderUID, manifestID, actor)
+	if err != nil {
+		return false, anonymized, fmt.Errorf("insert archive restore: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, anonymized, fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return true, anonymized, nil
+}
+
+// UpdateArchiveStatus помечает restored файлы архива, все заказы которых снова в базе
+func UpdateArchiveStatus(ctx context.Context, pool *pgxpool.Pool, ids []int64) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE archive_manifest m SET status = $2
+		WHERE m.id = ANY($1) AND m.status <> $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM unnest(m.order_uids) u(order_uid)
+			WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = u.order_uid)
+		  )
+	`, ids, models.ArchiveStatusRestored)
+	if err != nil {
+		return fmt.Errorf("update archive status: %w", err)
+	}
+	return nil
+}
+
+// ArchivedBefore самая поздняя граница архивации: заказы раньше нее могли быть удалены из базы
+func ArchivedBefore(ctx context.Context, pool *pgxpool.Pool) (time.Time, bool, error) {
+	var cutoff *time.Time
+	if err := pool.QueryRow(ctx, `SELECT max(cutoff) FROM archive_manifest`).Scan(&cutoff); err != nil {
+		return time.Time{}, false, fmt.Errorf("select archive cutoff: %w", err)
+	}
+	if cutoff == nil {
+		return time.Time{}, false, nil
+	}
+	return *cutoff, true, nil
+}
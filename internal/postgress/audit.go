@@ -0,0 +1,89 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InsertAuditEntries записывает пачку записей журнала доступа одним COPY
+func InsertAuditEntries(ctx context.Context, pool *pgxpool.Pool, entries []models.AuditEntry) error {
+	_, err := pool.CopyFrom(ctx,
+		pgx.Identifier{"audit_log"},
+		[]string{"created_at", "principal", "action", "method", "route", "path", "order_uid", "status", "source_ip"},
+		pgx.CopyFromSlice(len(entries), func(i int) ([]any, error) {
+			e := entries[i]
+			var orderUID *string
+			if e.OrderUID != "" {
+				orderUID = &e.OrderUID
+			}
+			return []any{e.Time, e.Principal, e.Action, e.Method, e.Route, e.Path, orderUID, e.Status, e.SourceIP}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("copy audit entries: %w", err)
+	}
+	return nil
+}
+
+// QueryAudit возвращает записи журнала по фильтру, от новых к старым, и общее число подходящих записей
+func QueryAudit(ctx context.Context, pool *pgxpool.Pool, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Principal != "" {
+		add("principal = $%d", f.Principal)
+	}
+	if f.OrderUID != "" {
+		add("order_uid = $%d", f.OrderUID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM audit_log "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count audit entries: %w", err)
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT id, created_at, principal, action, method, route, path, COALESCE(order_uid, ''), status, source_ip
+		FROM audit_log %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("select audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Time, &e.Principal, &e.Action, &e.Method,
+			&e.Route, &e.Path, &e.OrderUID, &e.Status, &e.SourceIP); err != nil {
+			return nil, 0, fmt.Errorf("scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("select audit entries: %w", err)
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,123 @@
+package postgress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// exportPageSize сколько заказов выбирается одним запросом при выгрузке
+const exportPageSize = 500
+
+// InsertOrders вставляет пачку заказов в одной транзакции так же, как InsertOrder.
+// При ошибке не вставляется ни один заказ. Возвращает число новых заказов
+func InsertOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, orders []models.Order) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inserted := 0
+	for i := range orders {
+		ok, err := insertOrder(ctx, tx, c, &orders[i])
+		if err != nil {
+			return 0, fmt.Errorf("order %s: %w", orders[i].OrderUID, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := updateRollups(ctx, tx, orders[i].OrderUID); err != nil {
+			return 0, err
+		}
+		inserted++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return inserted, nil
+}
+
+// ExportOrders передает в fn заказы, подходящие под фильтр, по порядку order_uid.
+// Заказы выбираются страницами, поэтому выгрузка не держит транзакцию и видит
+// заказы, добавленные во время выгрузки. Возвращает число выгруженных заказов
+func ExportOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, f models.OrderFilter, fn func(models.Order) error) (int, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.From.IsZero() {
+		add("date_created >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("date_created < $%d", f.To)
+	}
+	if f.CustomerID != "" {
+		add("customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("delivery_service = $%d", f.DeliveryService)
+	}
+	// последний order_uid предыдущей страницы
+	add("order_uid > $%d", "")
+	after := len(args) - 1
+	query := fmt.Sprintf(`
+		SELECT order_uid FROM orders
+		WHERE %s
+		ORDER BY order_uid
+		LIMIT %d
+	`, strings.Join(conds, " AND "), exportPageSize)
+
+	total := 0
+	for {
+		rows, err := pool.Query(ctx, query, args...)
+		if err != nil {
+			return total, fmt.Errorf("select orders: %w", err)
+		}
+		var uids []string
+		for rows.Next() {
+			var uid string
+			if err := rows.Scan(&uid); err != nil {
+				rows.Close()
+				return total, fmt.Errorf("scan order uid: %w", err)
+			}
+			uids = append(uids, uid)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, fmt.Errorf("select orders: %w", err)
+		}
+
+		for _, uid := range uids {
+			if f.Limit > 0 && total >= f.Limit {
+				return total, nil
+			}
+			order, err := GetOrder(ctx, uid, pool, c)
+			if errors.Is(err, pgx.ErrNoRows) {
+				// заказ удалили (например, архивировали) после выборки страницы
+				continue
+			}
+			if err != nil {
+				return total, fmt.Errorf("order %s: %w", uid, err)
+			}
+			if err := fn(order); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(uids) < exportPageSize {
+			return total, nil
+		}
+		args[after] = uids[len(uids)-1]
+	}
+}
@@ -0,0 +1,220 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Действия в privacy_audit
+const (
+	AuditExport    = "export"
+	AuditAnonymize = "anonymize"
+)
+
+// GetCustomerOrders возвращает все заказы клиента, от старых к новым
+func GetCustomerOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, customerID string) ([]models.Order, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT order_uid FROM orders
+		WHERE customer_id = $1
+		ORDER BY date_created, order_uid
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		order, err := GetOrder(ctx, uid, pool, c)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", uid, err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// GetCustomerStats считает сводку по всем заказам клиента одним запросом
+func GetCustomerStats(ctx context.Context, pool *pgxpool.Pool, customerID string) (models.CustomerStats, error) {
+	stats := models.CustomerStats{TotalSpent: map[string]int64{}}
+	var spent map[string]int64
+	err := pool.QueryRow(ctx, `
+		WITH o AS (
+			SELECT order_uid, date_created, delivery_service FROM orders WHERE customer_id = $1
+		)
+		SELECT
+			(SELECT count(*) FROM o),
+			(SELECT min(date_created) FROM o),
+			(SELECT max(date_created) FROM o),
+			COALESCE((SELECT delivery_service FROM o
+			          WHERE delivery_service <> ''
+			          GROUP BY delivery_service
+			          ORDER BY count(*) DESC, delivery_service
+			          LIMIT 1), ''),
+			(SELECT json_object_agg(currency, amount) FROM (
+				SELECT COALESCE(p.currency, '') AS currency, sum(p.amount) AS amount
+				FROM payment p JOIN o ON o.order_uid = p.order_uid
+				GROUP BY 1
+			) s)
+	`, customerID).Scan(&stats.TotalOrders, &stats.FirstOrderAt, &stats.LastOrderAt, &stats.TopDeliveryService, &spent)
+	if err != nil {
+		return stats, fmt.Errorf("customer stats: %w", err)
+	}
+	for currency, amount := range spent {
+		stats.TotalSpent[currency] = amount
+	}
+	return stats, nil
+}
+
+// GetCustomerOrderPage возвращает страницу заказов клиента от новых к старым
+func GetCustomerOrderPage(ctx context.Context, pool *pgxpool.Pool, customerID string, limit, offset int) ([]models.CustomerOrder, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT o.order_uid, o.track_number, o.date_created, COALESCE(o.delivery_service, ''),
+		       COALESCE(p.amount, 0), COALESCE(p.currency, ''),
+		       (SELECT count(*) FROM items i WHERE i.order_uid = o.order_uid)
+		FROM orders o
+		LEFT JOIN payment p ON p.order_uid = o.order_uid
+		WHERE o.customer_id = $1
+		ORDER BY o.date_created DESC, o.order_uid
+		LIMIT $2 OFFSET $3
+	`, customerID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.CustomerOrder{}
+	for rows.Next() {
+		var o models.CustomerOrder
+		if err := rows.Scan(&o.OrderUID, &o.TrackNumber, &o.DateCreated, &o.DeliveryService,
+			&o.Amount, &o.Currency, &o.Items); err != nil {
+			return nil, fmt.Errorf("scan customer order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+	return orders, nil
+}
+
+// AnonymizeCustomer затирает данные доставки во всех заказах клиента.
+// Заказы, платежи и товары остаются для финансовой отчетности.
+// Заказы клиента в архиве тоже считаются затронутыми: их данные затираются при восстановлении.
+// Действие записывается в privacy_audit в той же транзакции. Возвращает uid затронутых заказов
+func AnonymizeCustomer(ctx context.Context, pool *pgxpool.Pool, customerID, actor string) ([]string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT order_uid FROM orders
+		WHERE customer_id = $1
+		ORDER BY order_uid
+		FOR UPDATE
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+	uids := []string{}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select customer orders: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT DISTINCT a.order_uid
+		FROM archive_manifest m, unnest(m.order_uids, m.customer_ids) AS a(order_uid, customer_id)
+		WHERE m.customer_ids @> ARRAY[$1]::TEXT[] AND a.customer_id = $1 AND NOT a.order_uid = ANY($2)
+		ORDER BY a.order_uid
+	`, customerID, uids)
+	if err != nil {
+		return nil, fmt.Errorf("select archived customer orders: %w", err)
+	}
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select archived customer orders: %w", err)
+	}
+
+	if len(uids) == 0 {
+		return uids, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE delivery
+		SET name = '', phone = '', zip = '', city = '', address = '', region = '', email = '', key_id = NULL, name_index = NULL
+		WHERE order_uid = ANY($1)
+	`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("anonymize delivery: %w", err)
+	}
+
+	if err := insertAudit(ctx, tx, AuditAnonymize, customerID, actor, uids); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return uids, nil
+}
+
+// LogCustomerExport записывает выгрузку данных клиента в privacy_audit
+func LogCustomerExport(ctx context.Context, pool *pgxpool.Pool, customerID, actor string, orders []models.Order) error {
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	return insertAudit(ctx, pool, AuditExport, customerID, actor, uids)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertAudit(ctx context.Context, db execer, action, customerID, actor string, uids []string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO privacy_audit (action, customer_id, actor, order_uids)
+		VALUES ($1, $2, $3, $4)
+	`, action, customerID, actor, uids)
+	if err != nil {
+		return fmt.Errorf("insert privacy audit: %w", err)
+	}
+	return nil
+}
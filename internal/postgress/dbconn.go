@@ -5,6 +5,7 @@ import (
 	"demoserv/internal/models"
 	"errors"
 	"fmt"
+	"log/slog"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -13,7 +14,7 @@ import (
 )
 
 
-func New(ctx context.Context, config models.PostgresConfig) (*pgxpool.Pool, error) {
+func New(ctx context.Context, config models.PostgresConfig, log *slog.Logger) (*pgxpool.Pool, error) {
 	connString := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
 		config.POSTGRES_USER,
 		config.POSTGRES_PASSWORD,
@@ -21,7 +22,13 @@ func New(ctx context.Context, config models.PostgresConfig) (*pgxpool.Pool, erro
 		config.POSTGRES_PORT,
 		config.POSTGRES_DB,
 	)
-	conn, err := pgxpool.New(ctx, connString)
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string: %v", err)
+	}
+	// каждый запрос к базе попадает в трассу вызывающего
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to database: %v", err)
 	}
@@ -33,6 +40,9 @@ func New(ctx context.Context, config models.PostgresConfig) (*pgxpool.Pool, erro
 	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange){
 		return nil, fmt.Errorf("unable to migrate database: %v", err)
 	}
+	if version, dirty, err := m.Version(); err == nil {
+		log.Info("migrations applied", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+	}
 
 	return conn, nil
 }
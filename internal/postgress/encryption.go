@@ -0,0 +1,257 @@
+package postgress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrCipherRequired возвращается при записи delivery открытым текстом в базу,
+// где уже есть ключи данных, то есть шифрование включалось
+var ErrCipherRequired = errors.New("delivery encryption is enabled for this database, but no cipher is configured")
+
+// Функции, которые читают или пишут delivery, принимают шифр c явно:
+// name, phone, address и email шифруются им. nil - шифрование выключено
+
+// LoadDataKeys загружает ключи данных текущего мастер-ключа, последний становится активным.
+// Если ключей еще нет, создает первый
+func LoadDataKeys(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher) error {
+	rows, err := pool.Query(ctx, `
+		SELECT id, wrapped_key FROM data_keys
+		WHERE master_key_id = $1
+		ORDER BY created_at, id
+	`, c.MasterKeyID())
+	if err != nil {
+		return fmt.Errorf("select data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	var wrapped [][]byte
+	for rows.Next() {
+		var id string
+		var w []byte
+		if err := rows.Scan(&id, &w); err != nil {
+			return fmt.Errorf("scan data key: %w", err)
+		}
+		ids = append(ids, id)
+		wrapped = append(wrapped, w)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("select data keys: %w", err)
+	}
+
+	if len(ids) == 0 {
+		_, err := CreateDataKey(ctx, pool, c)
+		return err
+	}
+	for i := range ids {
+		if err := c.AddKey(ids[i], wrapped[i], i == len(ids)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateDataKey создает новый активный ключ данных и сохраняет его в data_keys
+func CreateDataKey(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher) (string, error) {
+	id, wrapped, err := c.GenerateKey()
+	if err != nil {
+		return "", fmt.Errorf("generate data key: %w", err)
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO data_keys (id, master_key_id, wrapped_key) VALUES ($1, $2, $3)
+	`, id, c.MasterKeyID(), wrapped)
+	if err != nil {
+		return "", fmt.Errorf("insert data key: %w", err)
+	}
+	return id, nil
+}
+
+// loadDataKey подгружает ключ, созданный после старта (например, ротацией из demoservctl)
+func loadDataKey(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, id string) error {
+	var masterKeyID string
+	var wrapped []byte
+	err := pool.QueryRow(ctx, `
+		SELECT master_key_id, wrapped_key FROM data_keys WHERE id = $1
+	`, id).Scan(&masterKeyID, &wrapped)
+	if err != nil {
+		return fmt.Errorf("get data key %s: %w", id, err)
+	}
+	if masterKeyID != c.MasterKeyID() {
+		return fmt.Errorf("data key %s is wrapped with master key %s", id, masterKeyID)
+	}
+	return c.AddKey(id, wrapped, false)
+}
+
+// deliveryFields поля delivery, которые шифруются
+func deliveryFields(d *models.Delivery) map[string]*string {
+	return map[string]*string{
+		"name":    &d.Name,
+		"phone":   &d.Phone,
+		"address": &d.Address,
+		"email":   &d.Email,
+	}
+}
+
+// encryptDelivery возвращает копию delivery с зашифрованными полями, id ключа
+// и слепой индекс имени для поиска (см. nameIndex).
+// Без шифра delivery пишется как есть, только если в базе нет ключей данных
+func encryptDelivery(ctx context.Context, tx pgx.Tx, c *encryption.Cipher, orderUID string, d models.Delivery) (models.Delivery, *string, []string, error) {
+	if c == nil {
+		var encrypted bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM data_keys)`).Scan(&encrypted); err != nil {
+			return d, nil, nil, fmt.Errorf("check data keys: %w", err)
+		}
+		if encrypted {
+			return d, nil, nil, ErrCipherRequired
+		}
+		return d, nil, nil, nil
+	}
+	index := nameIndex(c, d.Name)
+	keyID := c.ActiveKeyID()
+	for name, field := range deliveryFields(&d) {
+		ct, err := c.Encrypt(keyID, *field, orderUID+"/"+name)
+		if err != nil {
+			return d, nil, nil, fmt.Errorf("encrypt delivery.%s: %w", name, err)
+		}
+		*field = ct
+	}
+	return d, &keyID, index, nil
+}
+
+// nameIndex токены слепого индекса по словам имени получателя. Слова выделяются
+// так же, как в поисковом запросе, поэтому зашифрованное имя находится по целым словам.
+// Пустой массив, а не nil: NULL в name_index означает, что индекс еще не построен
+func nameIndex(c *encryption.Cipher, name string) []string {
+	index := []string{}
+	for _, term := range storage.Words(name) {
+		if token := c.BlindIndex(term); !slices.Contains(index, token) {
+			index = append(index, token)
+		}
+	}
+	return index
+}
+
+// decryptDelivery расшифровывает поля delivery. Строки без key_id хранятся открытым текстом
+func decryptDelivery(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, orderUID string, keyID *string, d *models.Delivery) error {
+	if keyID == nil {
+		return nil
+	}
+	if c == nil {
+		return fmt.Errorf("delivery of %s is encrypted, but encryption is not configured", orderUID)
+	}
+	if !c.HasKey(*keyID) {
+		if err := loadDataKey(ctx, pool, c, *keyID); err != nil {
+			return err
+		}
+	}
+	for name, field := range deliveryFields(d) {
+		plain, err := c.Decrypt(*keyID, *field, orderUID+"/"+name)
+		if err != nil {
+			return fmt.Errorf("decrypt delivery.%s: %w", name, err)
+		}
+		*field = plain
+	}
+	return nil
+}
+
+// EncryptDeliveries перешифровывает активным ключом строки delivery, которые
+// хранятся открытым текстом, зашифрованы другим ключом или еще без слепого индекса имени.
+// Возвращает число обновленных строк
+func EncryptDeliveries(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, batchSize int) (int, error) {
+	if c == nil {
+		return 0, fmt.Errorf("encryption is not configured")
+	}
+
+	total := 0
+	for {
+		n, err := encryptBatch(ctx, pool, c, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
+
+func encryptBatch(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, batchSize int) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT order_uid, name, phone, address, email, key_id
+		FROM delivery
+		WHERE key_id IS DISTINCT FROM $1 OR name_index IS NULL
+		ORDER BY order_uid
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, c.ActiveKeyID(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("select delivery: %w", err)
+	}
+
+	type row struct {
+		orderUID string
+		delivery models.Delivery
+		keyID    *string
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.orderUID, &r.delivery.Name, &r.delivery.Phone,
+			&r.delivery.Address, &r.delivery.Email, &r.keyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan delivery: %w", err)
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("select delivery: %w", err)
+	}
+
+	for _, r := range batch {
+		if err := decryptDelivery(ctx, pool, c, r.orderUID, r.keyID, &r.delivery); err != nil {
+			return 0, err
+		}
+		enc, keyID, index, err := encryptDelivery(ctx, tx, c, r.orderUID, r.delivery)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE delivery SET name = $2, phone = $3, address = $4, email = $5, key_id = $6, name_index = $7
+			WHERE order_uid = $1
+		`, r.orderUID, enc.Name, enc.Phone, enc.Address, enc.Email, keyID, index)
+		if err != nil {
+			return 0, fmt.Errorf("update delivery %s: %w", r.orderUID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return len(batch), nil
+}
+
+// scanDelivery читает строку delivery вместе с key_id и расшифровывает ее
+func scanDelivery(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, orderUID string, row pgx.Row, d *models.Delivery) error {
+	var keyID *string
+	if err := row.Scan(&d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email, &keyID); err != nil {
+		return err
+	}
+	return decryptDelivery(ctx, pool, c, orderUID, keyID, d)
+}
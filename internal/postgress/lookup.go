@@ -0,0 +1,43 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetOrderByTrackNumber возвращает самый новый заказ с трек-номером.
+// Трек-номер не уникален в схеме, при совпадении берется последний заказ
+func GetOrderByTrackNumber(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, trackNumber string) (models.Order, error) {
+	var orderUID string
+	err := pool.QueryRow(ctx, `
+		SELECT order_uid FROM orders
+		WHERE track_number = $1
+		ORDER BY date_created DESC, order_uid
+		LIMIT 1
+	`, trackNumber).Scan(&orderUID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("get order by track number: %w", err)
+	}
+	return GetOrder(ctx, orderUID, pool, c)
+}
+
+// GetOrderByTransaction возвращает самый новый заказ с транзакцией оплаты
+func GetOrderByTransaction(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, transaction string) (models.Order, error) {
+	var orderUID string
+	err := pool.QueryRow(ctx, `
+		SELECT p.order_uid FROM payment p
+		JOIN orders o ON o.order_uid = p.order_uid
+		WHERE p.transaction = $1
+		ORDER BY o.date_created DESC, p.order_uid
+		LIMIT 1
+	`, transaction).Scan(&orderUID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("get order by transaction: %w", err)
+	}
+	return GetOrder(ctx, orderUID, pool, c)
+}
@@ -0,0 +1,191 @@
+package postgress
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrPartitionsSwapped таблицы заказов уже секционированы, переносить нечего
+	ErrPartitionsSwapped = errors.New("order tables are already partitioned")
+	// ErrPartitionsNotPrepared секционированные копии таблиц не созданы, см. PreparePartitions
+	ErrPartitionsNotPrepared = errors.New("order partitions are not prepared")
+	// ErrPartitionsPrepared секционированные копии таблиц уже созданы
+	ErrPartitionsPrepared = errors.New("order partitions are already prepared")
+)
+
+// Секционирование не входит в миграции: копии *_part и триггеры синхронизации
+// замедляют каждую запись заказа, поэтому они создаются только по команде оператора
+var (
+	//go:embed partitioning.up.sql
+	partitioningUp string
+	//go:embed partitioning.down.sql
+	partitioningDown string
+)
+
+// partitionedTables таблицы заказов, которые секционируются по date_created
+var partitionedTables = []string{"orders", "delivery", "payment", "items"}
+
+// CreateOrderPartitions создает месячные секции таблиц заказов на months месяцев, начиная с месяца from.
+// Существующие секции пропускаются, возвращает число созданных
+func CreateOrderPartitions(ctx context.Context, pool *pgxpool.Pool, from time.Time, months int) (int, error) {
+	var created int
+	if err := pool.QueryRow(ctx, `SELECT create_order_partitions($1, $2)`, from, months).Scan(&created); err != nil {
+		return 0, fmt.Errorf("create order partitions: %w", err)
+	}
+	return created, nil
+}
+
+// PreparePartitions создает секционированные копии таблиц заказов, секции для месяцев
+// с заказами и триггеры, которые повторяют в копиях изменения старых таблиц.
+// Выполняется после всех миграций, в одной транзакции
+func PreparePartitions(ctx context.Context, pool *pgxpool.Pool) error {
+	m, err := GetPartitionMigration(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if m.Swapped {
+		return ErrPartitionsSwapped
+	}
+	if m.Prepared {
+		return ErrPartitionsPrepared
+	}
+	return execScript(ctx, pool, "prepare order partitions", partitioningUp)
+}
+
+// RollbackPartitions удаляет копии *_part и триггеры. Возможно только до подмены таблиц
+func RollbackPartitions(ctx context.Context, pool *pgxpool.Pool) error {
+	m, err := GetPartitionMigration(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if m.Swapped {
+		return ErrPartitionsSwapped
+	}
+	return execScript(ctx, pool, "rollback order partitions", partitioningDown)
+}
+
+// execScript выполняет SQL скрипт из нескольких команд в одной транзакции
+func execScript(ctx context.Context, pool *pgxpool.Pool, name, script string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// GetPartitionMigration состояние переноса заказов в секционированные таблицы.
+// Строки считаются в одном снимке, поэтому пока идут вставки, числа все равно сравнимы
+func GetPartitionMigration(ctx context.Context, pool *pgxpool.Pool) (models.PartitionMigration, error) {
+	var m models.PartitionMigration
+	if err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'orders'::regclass),
+		       to_regclass('orders_part') IS NOT NULL`).Scan(&m.Swapped, &m.Prepared); err != nil {
+		return m, fmt.Errorf("check orders partitioning: %w", err)
+	}
+	if m.Swapped {
+		m.Prepared = true
+		return m, nil
+	}
+	if !m.Prepared {
+		return m, nil
+	}
+
+	m.Tables = make([]models.PartitionTableStatus, len(partitionedTables))
+	dest := make([]any, 0, 2*len(partitionedTables))
+	query := "SELECT "
+	for i, table := range partitionedTables {
+		m.Tables[i].Table = table
+		if i > 0 {
+			query += ", "
+		}
+		query += fmt.Sprintf("(SELECT count(*) FROM %[1]s), (SELECT count(*) FROM %[1]s_part)", table)
+		dest = append(dest, &m.Tables[i].Rows, &m.Tables[i].Copied)
+	}
+	if err := pool.QueryRow(ctx, query).Scan(dest...); err != nil {
+		return m, fmt.Errorf("count partition migration rows: %w", err)
+	}
+	return m, nil
+}
+
+// BackfillOrderPartitions переносит заказы в секционированные таблицы пачками по batch заказов,
+// каждая пачка в своей транзакции. progress вызывается после каждой пачки с последним order_uid
+func BackfillOrderPartitions(ctx context.Context, pool *pgxpool.Pool, batch int, progress func(lastUID string)) error {
+	m, err := GetPartitionMigration(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if m.Swapped {
+		return ErrPartitionsSwapped
+	}
+	if !m.Prepared {
+		return ErrPartitionsNotPrepared
+	}
+
+	var last string
+	for {
+		var next *string
+		if err := pool.QueryRow(ctx, `SELECT backfill_order_partitions($1, $2)`, last, batch).Scan(&next); err != nil {
+			return fmt.Errorf("backfill orders after %q: %w", last, err)
+		}
+		if next == nil {
+			return nil
+		}
+		last = *next
+		if progress != nil {
+			progress(last)
+		}
+	}
+}
+
+// SwapOrderPartitions подменяет таблицы заказов секционированными, если все строки перенесены.
+// Блокировка таблиц ждет не дольше lockTimeout, чтобы не останавливать запросы за долгой транзакцией
+func SwapOrderPartitions(ctx context.Context, pool *pgxpool.Pool, lockTimeout time.Duration) error {
+	m, err := GetPartitionMigration(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if m.Swapped {
+		return ErrPartitionsSwapped
+	}
+	if !m.Prepared {
+		return ErrPartitionsNotPrepared
+	}
+	for _, t := range m.Tables {
+		if t.Rows != t.Copied {
+			return fmt.Errorf("%s is not fully copied: %d of %d rows, run backfill first", t.Table, t.Copied, t.Rows)
+		}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", lockTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("set lock timeout: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT swap_order_partitions()`); err != nil {
+		return fmt.Errorf("swap order tables: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+-- Отмена partitioning.up.sql (demoservctl partition-prepare -rollback).
+-- Возможна только до переключения таблиц (demoservctl partition-migrate -swap)
+DROP FUNCTION IF EXISTS swap_order_partitions();
+DROP FUNCTION IF EXISTS backfill_order_partitions(TEXT, INT);
+
+DROP TRIGGER IF EXISTS items_part_sync ON items;
+DROP TRIGGER IF EXISTS payment_part_sync ON payment;
+DROP TRIGGER IF EXISTS delivery_part_sync ON delivery;
+DROP TRIGGER IF EXISTS orders_part_sync ON orders;
+DROP FUNCTION IF EXISTS items_part_sync();
+DROP FUNCTION IF EXISTS payment_part_sync();
+DROP FUNCTION IF EXISTS delivery_part_sync();
+DROP FUNCTION IF EXISTS delivery_part_columns();
+DROP FUNCTION IF EXISTS orders_part_sync();
+DROP FUNCTION IF EXISTS create_order_partitions(DATE, INT);
+
+DROP TABLE IF EXISTS items_part;
+DROP TABLE IF EXISTS payment_part;
+DROP TABLE IF EXISTS delivery_part;
+DROP TABLE IF EXISTS orders_part;
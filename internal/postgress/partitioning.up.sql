@@ -0,0 +1,352 @@
+-- Секционирование orders, delivery, payment и items по месяцам date_created.
+-- Не миграция: скрипт выполняет demoservctl partition-prepare, когда оператор решил
+-- переходить на секции, после всех миграций из db/migrations. Скрипт готовит
+-- секционированные копии *_part и триггеры, которые держат их в актуальном состоянии.
+-- Данные переносятся и таблицы подменяются командой demoservctl partition-migrate
+-- без остановки сервиса
+
+-- Уникальные ключи секционированной таблицы обязаны включать ключ секционирования,
+-- поэтому order_uid уникален в паре с date_created. Заказы без даты переносятся
+-- с датой 1970-01-01 и попадают в секцию по умолчанию
+CREATE TABLE orders_part (
+    order_uid VARCHAR(255) NOT NULL,
+    track_number VARCHAR(255) NOT NULL,
+    entry VARCHAR(50),
+    locale VARCHAR(10),
+    internal_signature VARCHAR(255),
+    customer_id VARCHAR(255),
+    delivery_service VARCHAR(255),
+    shardkey VARCHAR(10),
+    sm_id INT,
+    date_created TIMESTAMP NOT NULL,
+    oof_shard VARCHAR(10),
+    search_vector tsvector
+        GENERATED ALWAYS AS (setweight(to_tsvector('simple', coalesce(track_number, '')), 'A')) STORED,
+    PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
+
+CREATE INDEX idx_orders_part_track_number ON orders_part (track_number, date_created DESC);
+CREATE INDEX idx_orders_part_customer_id ON orders_part (customer_id, date_created DESC);
+CREATE INDEX idx_orders_part_date_created ON orders_part (date_created);
+CREATE INDEX idx_orders_part_search ON orders_part USING GIN (search_vector);
+
+-- id продолжают последовательности старых таблиц, при переключении последовательности
+-- переходят к новым таблицам
+CREATE TABLE delivery_part (
+    id INT NOT NULL DEFAULT nextval('delivery_id_seq'),
+    order_uid VARCHAR(255) NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    name TEXT,
+    phone TEXT,
+    zip VARCHAR(20),
+    city VARCHAR(255),
+    address TEXT,
+    region VARCHAR(255),
+    email TEXT,
+    key_id VARCHAR(64) REFERENCES data_keys(id),
+    name_index TEXT[],
+    search_vector tsvector
+        GENERATED ALWAYS AS (
+            CASE WHEN key_id IS NULL THEN setweight(to_tsvector('simple', coalesce(name, '')), 'C') END
+        ) STORED,
+    PRIMARY KEY (id, date_created),
+    UNIQUE (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders_part (order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE INDEX idx_delivery_part_search ON delivery_part USING GIN (search_vector);
+CREATE INDEX idx_delivery_part_name_index ON delivery_part USING GIN (name_index);
+
+CREATE TABLE payment_part (
+    id INT NOT NULL DEFAULT nextval('payment_id_seq'),
+    order_uid VARCHAR(255) NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    transaction VARCHAR(255),
+    request_id VARCHAR(255),
+    currency VARCHAR(10),
+    provider VARCHAR(50),
+    amount INT,
+    payment_dt BIGINT,
+    bank VARCHAR(50),
+    delivery_cost INT,
+    goods_total INT,
+    custom_fee INT,
+    PRIMARY KEY (id, date_created),
+    UNIQUE (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders_part (order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE INDEX idx_payment_part_transaction ON payment_part (transaction);
+
+CREATE TABLE items_part (
+    id INT NOT NULL DEFAULT nextval('items_id_seq'),
+    order_uid VARCHAR(255) NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    chrt_id BIGINT,
+    track_number VARCHAR(255),
+    price INT,
+    rid VARCHAR(255),
+    name VARCHAR(255),
+    sale INT,
+    size VARCHAR(50),
+    total_price INT,
+    nm_id BIGINT,
+    brand VARCHAR(255),
+    status INT,
+    search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('simple', coalesce(name, '')), 'B') ||
+            setweight(to_tsvector('simple', coalesce(brand, '')), 'B')
+        ) STORED,
+    PRIMARY KEY (id, date_created),
+    UNIQUE (order_uid, chrt_id, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders_part (order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE INDEX idx_items_part_search ON items_part USING GIN (search_vector);
+
+-- Строки вне созданных месячных секций
+CREATE TABLE orders_default PARTITION OF orders_part DEFAULT;
+CREATE TABLE delivery_default PARTITION OF delivery_part DEFAULT;
+CREATE TABLE payment_default PARTITION OF payment_part DEFAULT;
+CREATE TABLE items_default PARTITION OF items_part DEFAULT;
+
+-- create_order_partitions создает месячные секции всех четырех таблиц на months месяцев,
+-- начиная с месяца first_month, и возвращает число созданных секций.
+-- До переключения секционированы таблицы *_part, после — сами orders, delivery, payment, items.
+-- Если строки месяца уже лежат в секции по умолчанию, секция не создается (нужен ручной перенос)
+CREATE FUNCTION create_order_partitions(first_month DATE, months INT) RETURNS INT AS $$
+DECLARE
+    base TEXT;
+    parent TEXT;
+    month_start DATE;
+    part TEXT;
+    has_rows BOOLEAN;
+    created INT := 0;
+BEGIN
+    FOREACH base IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+        parent := base;
+        IF NOT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass(base)) THEN
+            parent := base || '_part';
+        END IF;
+        FOR i IN 0 .. months - 1 LOOP
+            month_start := (date_trunc('month', first_month::timestamp) + make_interval(months => i))::date;
+            part := format('%s_y%sm%s', base, to_char(month_start, 'YYYY'), to_char(month_start, 'MM'));
+            IF to_regclass(part) IS NOT NULL THEN
+                CONTINUE;
+            END IF;
+            EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE date_created >= %L AND date_created < %L)',
+                base || '_default', month_start, (month_start + interval '1 month')::date) INTO has_rows;
+            IF has_rows THEN
+                RAISE WARNING 'partition % is not created: %_default has rows for this month', part, base;
+                CONTINUE;
+            END IF;
+            EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                part, parent, month_start, (month_start + interval '1 month')::date);
+            created := created + 1;
+        END LOOP;
+    END LOOP;
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Секции для месяцев с заказами, текущего и трех следующих
+SELECT create_order_partitions(month, 1)
+FROM (SELECT DISTINCT date_trunc('month', date_created)::date AS month FROM orders WHERE date_created IS NOT NULL) m;
+SELECT create_order_partitions(current_date, 4);
+
+-- Пока данные переносятся, изменения старых таблиц повторяются в *_part.
+-- Строки дочерних таблиц копируются, только если заказ уже перенесен,
+-- остальные перенесет backfill_order_partitions
+CREATE FUNCTION orders_part_sync() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM orders_part WHERE order_uid = OLD.order_uid;
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE orders_part SET track_number = NEW.track_number, entry = NEW.entry, locale = NEW.locale,
+            internal_signature = NEW.internal_signature, customer_id = NEW.customer_id,
+            delivery_service = NEW.delivery_service, shardkey = NEW.shardkey, sm_id = NEW.sm_id,
+            oof_shard = NEW.oof_shard
+        WHERE order_uid = OLD.order_uid;
+    ELSE
+        INSERT INTO orders_part (order_uid, track_number, entry, locale, internal_signature,
+            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+        VALUES (NEW.order_uid, NEW.track_number, NEW.entry, NEW.locale, NEW.internal_signature,
+            NEW.customer_id, NEW.delivery_service, NEW.shardkey, NEW.sm_id,
+            COALESCE(NEW.date_created, '1970-01-01'), NEW.oof_shard)
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- delivery_part_columns столбцы delivery, которые копируются в delivery_part, кроме id,
+-- order_uid и date_created. Столбцы delivery меняются вместе с шифрованием и поиском,
+-- поэтому миграция, добавляющая столбец, заменяет только этот список
+CREATE FUNCTION delivery_part_columns() RETURNS TEXT[] AS $$
+    SELECT ARRAY['name', 'phone', 'zip', 'city', 'address', 'region', 'email', 'key_id', 'name_index']
+$$ LANGUAGE sql STABLE;
+
+CREATE FUNCTION delivery_part_sync() RETURNS trigger AS $$
+DECLARE
+    cols TEXT[] := delivery_part_columns();
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM delivery_part WHERE id = OLD.id;
+    ELSIF TG_OP = 'UPDATE' THEN
+        EXECUTE format('UPDATE delivery_part SET %s WHERE id = ($2).id',
+            (SELECT string_agg(format('%1$I = ($1).%1$I', c), ', ') FROM unnest(cols) c))
+        USING NEW, OLD;
+    ELSE
+        EXECUTE format('
+            INSERT INTO delivery_part (id, order_uid, date_created, %s)
+            SELECT ($1).id, ($1).order_uid, o.date_created, %s
+            FROM orders_part o WHERE o.order_uid = ($1).order_uid
+            ON CONFLICT DO NOTHING',
+            (SELECT string_agg(format('%I', c), ', ') FROM unnest(cols) c),
+            (SELECT string_agg(format('($1).%I', c), ', ') FROM unnest(cols) c))
+        USING NEW;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION payment_part_sync() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM payment_part WHERE id = OLD.id;
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE payment_part SET transaction = NEW.transaction, request_id = NEW.request_id,
+            currency = NEW.currency, provider = NEW.provider, amount = NEW.amount, payment_dt = NEW.payment_dt,
+            bank = NEW.bank, delivery_cost = NEW.delivery_cost, goods_total = NEW.goods_total,
+            custom_fee = NEW.custom_fee
+        WHERE id = OLD.id;
+    ELSE
+        INSERT INTO payment_part (id, order_uid, date_created, transaction, request_id, currency, provider,
+            amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+        SELECT NEW.id, NEW.order_uid, o.date_created, NEW.transaction, NEW.request_id, NEW.currency,
+            NEW.provider, NEW.amount, NEW.payment_dt, NEW.bank, NEW.delivery_cost, NEW.goods_total, NEW.custom_fee
+        FROM orders_part o WHERE o.order_uid = NEW.order_uid
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION items_part_sync() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM items_part WHERE id = OLD.id;
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE items_part SET chrt_id = NEW.chrt_id, track_number = NEW.track_number, price = NEW.price,
+            rid = NEW.rid, name = NEW.name, sale = NEW.sale, size = NEW.size, total_price = NEW.total_price,
+            nm_id = NEW.nm_id, brand = NEW.brand, status = NEW.status
+        WHERE id = OLD.id;
+    ELSE
+        INSERT INTO items_part (id, order_uid, date_created, chrt_id, track_number, price, rid, name,
+            sale, size, total_price, nm_id, brand, status)
+        SELECT NEW.id, NEW.order_uid, o.date_created, NEW.chrt_id, NEW.track_number, NEW.price, NEW.rid,
+            NEW.name, NEW.sale, NEW.size, NEW.total_price, NEW.nm_id, NEW.brand, NEW.status
+        FROM orders_part o WHERE o.order_uid = NEW.order_uid
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER orders_part_sync AFTER INSERT OR UPDATE OR DELETE ON orders
+    FOR EACH ROW EXECUTE FUNCTION orders_part_sync();
+CREATE TRIGGER delivery_part_sync AFTER INSERT OR UPDATE OR DELETE ON delivery
+    FOR EACH ROW EXECUTE FUNCTION delivery_part_sync();
+CREATE TRIGGER payment_part_sync AFTER INSERT OR UPDATE OR DELETE ON payment
+    FOR EACH ROW EXECUTE FUNCTION payment_part_sync();
+CREATE TRIGGER items_part_sync AFTER INSERT OR UPDATE OR DELETE ON items
+    FOR EACH ROW EXECUTE FUNCTION items_part_sync();
+
+-- backfill_order_partitions переносит заказы с order_uid больше after (не больше batch заказов)
+-- вместе с дочерними строками и возвращает последний перенесенный order_uid, NULL — переносить нечего.
+-- Строки блокируются до конца транзакции, чтобы параллельное изменение не потерялось
+CREATE FUNCTION backfill_order_partitions(after TEXT, batch INT) RETURNS TEXT AS $$
+DECLARE
+    uids TEXT[];
+    cols TEXT[] := delivery_part_columns();
+BEGIN
+    SELECT array_agg(order_uid ORDER BY order_uid) INTO uids FROM (
+        SELECT order_uid FROM orders WHERE order_uid > after ORDER BY order_uid LIMIT batch FOR SHARE
+    ) b;
+    IF uids IS NULL THEN
+        RETURN NULL;
+    END IF;
+    PERFORM 1 FROM delivery WHERE order_uid = ANY(uids) FOR SHARE;
+    PERFORM 1 FROM payment WHERE order_uid = ANY(uids) FOR SHARE;
+    PERFORM 1 FROM items WHERE order_uid = ANY(uids) FOR SHARE;
+
+    INSERT INTO orders_part (order_uid, track_number, entry, locale, internal_signature,
+        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+    SELECT order_uid, track_number, entry, locale, internal_signature,
+        customer_id, delivery_service, shardkey, sm_id, COALESCE(date_created, '1970-01-01'), oof_shard
+    FROM orders WHERE order_uid = ANY(uids)
+    ON CONFLICT DO NOTHING;
+
+    EXECUTE format('
+        INSERT INTO delivery_part (id, order_uid, date_created, %s)
+        SELECT d.id, d.order_uid, o.date_created, %s
+        FROM delivery d JOIN orders_part o ON o.order_uid = d.order_uid
+        WHERE d.order_uid = ANY($1)
+        ON CONFLICT DO NOTHING',
+        (SELECT string_agg(format('%I', c), ', ') FROM unnest(cols) c),
+        (SELECT string_agg(format('d.%I', c), ', ') FROM unnest(cols) c))
+    USING uids;
+
+    INSERT INTO payment_part (id, order_uid, date_created, transaction, request_id, currency, provider,
+        amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+    SELECT p.id, p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider,
+        p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+    FROM payment p JOIN orders_part o ON o.order_uid = p.order_uid
+    WHERE p.order_uid = ANY(uids)
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO items_part (id, order_uid, date_created, chrt_id, track_number, price, rid, name,
+        sale, size, total_price, nm_id, brand, status)
+    SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+        i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+    FROM items i JOIN orders_part o ON o.order_uid = i.order_uid
+    WHERE i.order_uid = ANY(uids)
+    ON CONFLICT DO NOTHING;
+
+    RETURN uids[array_length(uids, 1)];
+END;
+$$ LANGUAGE plpgsql;
+
+-- swap_order_partitions подменяет старые таблицы секционированными: старые остаются
+-- под именами *_legacy, триггеры синхронизации удаляются. Полнота переноса проверяется
+-- до вызова, таблицы блокируются только на время переименования
+CREATE FUNCTION swap_order_partitions() RETURNS void AS $$
+BEGIN
+    LOCK TABLE orders, delivery, payment, items IN ACCESS EXCLUSIVE MODE;
+
+    DROP TRIGGER orders_part_sync ON orders;
+    DROP TRIGGER delivery_part_sync ON delivery;
+    DROP TRIGGER payment_part_sync ON payment;
+    DROP TRIGGER items_part_sync ON items;
+    DROP FUNCTION orders_part_sync();
+    DROP FUNCTION delivery_part_sync();
+    DROP FUNCTION payment_part_sync();
+    DROP FUNCTION items_part_sync();
+
+    ALTER TABLE items RENAME TO items_legacy;
+    ALTER TABLE payment RENAME TO payment_legacy;
+    ALTER TABLE delivery RENAME TO delivery_legacy;
+    ALTER TABLE orders RENAME TO orders_legacy;
+
+    ALTER TABLE orders_part RENAME TO orders;
+    ALTER TABLE delivery_part RENAME TO delivery;
+    ALTER TABLE payment_part RENAME TO payment;
+    ALTER TABLE items_part RENAME TO items;
+
+    -- иначе последовательности удалятся вместе со старыми таблицами
+    ALTER SEQUENCE delivery_id_seq OWNED BY delivery.id;
+    ALTER SEQUENCE payment_id_seq OWNED BY payment.id;
+    ALTER SEQUENCE items_id_seq OWNED BY items.id;
+END;
+$$ LANGUAGE plpgsql;
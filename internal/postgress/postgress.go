@@ -2,60 +2,104 @@ package postgress
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"demoserv/internal/encryption"
 	"demoserv/internal/models"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InsertOrder(ctx context.Context, pool *pgxpool.Pool, order *models.Order) error {
+func InsertOrder(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, order *models.Order) error {
 	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to begin transaction: %v", err)
+		return fmt.Errorf("unable to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Вставка в orders
-	_, err = tx.Exec(ctx, `
-		INSERT INTO orders (
-			order_uid, track_number, entry, locale, internal_signature, 
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (order_uid) DO NOTHING`,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard,
-	)
+	inserted, err := insertOrder(ctx, tx, c, order)
 	if err != nil {
-		return fmt.Errorf("unable to insert into orders: %v", err)
+		return err
+	}
+
+	// Новый заказ учитывается в сводных таблицах, повторный пропускается
+	if inserted {
+		if err := updateRollups(ctx, tx, order.OrderUID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertOrder пишет заказ в транзакции tx. inserted=false, если заказ уже был в базе
+func insertOrder(ctx context.Context, tx pgx.Tx, c *encryption.Cipher, order *models.Order) (inserted bool, err error) {
+	// В секционированных таблицах order_uid уникален только вместе с date_created,
+	// поэтому повторный заказ ищется по order_uid, и его строки дописываются к нему.
+	// ON CONFLICT без ключа работает и со старыми таблицами, и с секционированными.
+	// Блокировка по order_uid до конца транзакции: иначе две параллельные вставки
+	// с разными date_created обе не найдут заказ и запишут его дважды
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, order.OrderUID); err != nil {
+		return false, fmt.Errorf("unable to lock order: %w", err)
+	}
+	dateCreated := &order.DateCreated
+	err = tx.QueryRow(ctx, `SELECT date_created FROM orders WHERE order_uid = $1`, order.OrderUID).Scan(&dateCreated)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// Вставка в orders
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO orders (
+				order_uid, track_number, entry, locale, internal_signature, 
+				customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT DO NOTHING`,
+			order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+			order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard,
+		)
+		if err != nil {
+			return false, fmt.Errorf("unable to insert into orders: %w", err)
+		}
+		inserted = tag.RowsAffected() == 1
+	case err != nil:
+		return false, fmt.Errorf("unable to check order: %w", err)
 	}
 
-	// Вставка в delivery
+	// Вставка в delivery, персональные данные шифруются
+	delivery, keyID, index, err := encryptDelivery(ctx, tx, c, order.OrderUID, order.Delivery)
+	if err != nil {
+		return false, err
+	}
 	_, err = tx.Exec(ctx, `
 		INSERT INTO delivery (
-			order_uid, name, phone, zip, city, address, region, email
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (order_uid) DO NOTHING`,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+			order_uid, name, phone, zip, city, address, region, email, key_id, date_created, name_index
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING`,
+		order.OrderUID, delivery.Name, delivery.Phone, delivery.Zip,
+		delivery.City, delivery.Address, delivery.Region, delivery.Email, keyID, dateCreated, index,
 	)
 	if err != nil {
-		return fmt.Errorf("unable to insert into delivery: %v", err)
+		return false, fmt.Errorf("unable to insert into delivery: %w", err)
 	}
 
 	// Вставка в payment
 	_, err = tx.Exec(ctx, `
 		INSERT INTO payment (
 			order_uid, transaction, request_id, currency, provider, amount, 
-			payment_dt, bank, delivery_cost, goods_total, custom_fee
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (order_uid) DO NOTHING`,
+			payment_dt, bank, delivery_cost, goods_total, custom_fee, date_created
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT DO NOTHING`,
 		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
 		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
-		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee, dateCreated,
 	)
 	if err != nil {
-		return fmt.Errorf("unable to insert into payment: %v", err)
+		return false, fmt.Errorf("unable to insert into payment: %w", err)
 	}
 
 	// Вставка в items для каждого элемента
@@ -63,25 +107,21 @@ func InsertOrder(ctx context.Context, pool *pgxpool.Pool, order *models.Order) e
 		_, err = tx.Exec(ctx, `
 			INSERT INTO items (
 				order_uid, chrt_id, track_number, price, rid, name, 
-				sale, size, total_price, nm_id, brand, status
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-			ON CONFLICT (order_uid, chrt_id) DO NOTHING`,
+				sale, size, total_price, nm_id, brand, status, date_created
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT DO NOTHING`,
 			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
-			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status, dateCreated,
 		)
 		if err != nil {
-			return fmt.Errorf("unable to insert into items: %v", err)
+			return false, fmt.Errorf("unable to insert into items: %w", err)
 		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("unable to commit transaction: %v", err)
-	}
-
-	return nil
+	return inserted, nil
 }
 
-func GetLastOrders(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models.Order, error) {
+func GetLastOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, limit int) ([]models.Order, error) {
 	rows, err := pool.Query(ctx, `
 		SELECT order_uid, track_number, entry, locale, internal_signature,
 		       customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
@@ -114,18 +154,10 @@ func GetLastOrders(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models
 		}
 
 		// достаем из Delivery
-		err = pool.QueryRow(ctx, `
-			SELECT name, phone, zip, city, address, region, email
+		err = scanDelivery(ctx, pool, c, o.OrderUID, pool.QueryRow(ctx, `
+			SELECT name, phone, zip, city, address, region, email, key_id
 			FROM delivery WHERE order_uid=$1
-		`, o.OrderUID).Scan(
-			&o.Delivery.Name,
-			&o.Delivery.Phone,
-			&o.Delivery.Zip,
-			&o.Delivery.City,
-			&o.Delivery.Address,
-			&o.Delivery.Region,
-			&o.Delivery.Email,
-		)
+		`, o.OrderUID), &o.Delivery)
 		if err != nil {
 			return nil, err
 		}
@@ -190,7 +222,7 @@ func GetLastOrders(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models
 	return orders, nil
 }
 
-func GetOrder(ctx context.Context, orderUID string, pool *pgxpool.Pool) (models.Order, error) {
+func GetOrder(ctx context.Context, orderUID string, pool *pgxpool.Pool, c *encryption.Cipher) (models.Order, error) {
 	var order models.Order
 
 	// получаем из Order
@@ -217,19 +249,11 @@ func GetOrder(ctx context.Context, orderUID string, pool *pgxpool.Pool) (models.
 	}
 
 	// получаем из Delivery
-	err = pool.QueryRow(ctx, `
-		SELECT name, phone, zip, city, address, region, email
+	err = scanDelivery(ctx, pool, c, orderUID, pool.QueryRow(ctx, `
+		SELECT name, phone, zip, city, address, region, email, key_id
 		FROM delivery
 		WHERE order_uid = $1
-		`, orderUID).Scan(
-		&order.Delivery.Name,
-		&order.Delivery.Phone,
-		&order.Delivery.Zip,
-		&order.Delivery.City,
-		&order.Delivery.Address,
-		&order.Delivery.Region,
-		&order.Delivery.Email,
-	)
+		`, orderUID), &order.Delivery)
 	if err != nil {
 		return order, fmt.Errorf("get delivery: %w", err)
 	}
@@ -1,14 +1,23 @@
 package postgress_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"demoserv/internal/archive"
+	"demoserv/internal/encryption"
 	"demoserv/internal/models"
 	"demoserv/internal/postgress"
+	"demoserv/internal/storage"
+	"demoserv/internal/storage/storagetest"
 	"demoserv/internal/testutils"
 
 	embedded "github.com/fergusstrange/embedded-postgres"
@@ -62,70 +71,6 @@ func startEmbeddedPG(t *testing.T, port int) (*embedded.EmbeddedPostgres, *pgxpo
 	return epg, pool
 }
 
-func createTables(t *testing.T, pool *pgxpool.Pool) {
-	t.Helper()
-	ctx := context.Background()
-	sql := `
-CREATE TABLE IF NOT EXISTS orders (
-    order_uid VARCHAR(255) PRIMARY KEY,
-    track_number VARCHAR(255) NOT NULL,
-    entry VARCHAR(50),
-    locale VARCHAR(10),
-    internal_signature VARCHAR(255),
-    customer_id VARCHAR(255),
-    delivery_service VARCHAR(255),
-    shardkey VARCHAR(10),
-    sm_id INT,
-    date_created TIMESTAMP,
-    oof_shard VARCHAR(10)
-);
-CREATE TABLE IF NOT EXISTS delivery (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    name VARCHAR(255),
-    phone VARCHAR(50),
-    zip VARCHAR(20),
-    city VARCHAR(255),
-    address VARCHAR(255),
-    region VARCHAR(255),
-    email VARCHAR(255)
-);
-CREATE TABLE IF NOT EXISTS payment (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) UNIQUE REFERENCES orders(order_uid) ON DELETE CASCADE,
-    transaction VARCHAR(255),
-    request_id VARCHAR(255),
-    currency VARCHAR(10),
-    provider VARCHAR(50),
-    amount INT,
-    payment_dt BIGINT,
-    bank VARCHAR(50),
-    delivery_cost INT,
-    goods_total INT,
-    custom_fee INT
-);
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(255) REFERENCES orders(order_uid) ON DELETE CASCADE,
-    chrt_id BIGINT,
-    track_number VARCHAR(255),
-    price INT,
-    rid VARCHAR(255),
-    name VARCHAR(255),
-    sale INT,
-    size VARCHAR(50),
-    total_price INT,
-    nm_id BIGINT,
-    brand VARCHAR(255),
-    status INT,
-    CONSTRAINT unique_order_item UNIQUE (order_uid, chrt_id)
-);
-`
-	if _, err := pool.Exec(ctx, sql); err != nil {
-		t.Fatalf("createTables exec: %v", err)
-	}
-}
-
 func makeOrder(uid string, created time.Time) *models.Order {
 	return &models.Order{
 		OrderUID:    uid,
@@ -154,16 +99,16 @@ func makeOrder(uid string, created time.Time) *models.Order {
 func TestInsertAndGetOrder_Embedded(t *testing.T) {
 	_, pool := testutils.StartEmbeddedPG(t)
 
-	createTables(t, pool)
+	testutils.ApplyMigrations(t, pool)
 
 	ctx := context.Background()
 	o := makeOrder("itest-1", time.Now().Add(-2*time.Hour))
 
-	if err := postgress.InsertOrder(ctx, pool, o); err != nil {
+	if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
 		t.Fatalf("InsertOrder failed: %v", err)
 	}
 
-	got, err := postgress.GetOrder(ctx, o.OrderUID, pool)
+	got, err := postgress.GetOrder(ctx, o.OrderUID, pool, nil)
 	if err != nil {
 		t.Fatalf("GetOrder failed: %v", err)
 	}
@@ -172,6 +117,83 @@ func TestInsertAndGetOrder_Embedded(t *testing.T) {
 	}
 }
 
+func TestRepository_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+
+	storagetest.Run(t, func(t *testing.T) storage.Repository {
+		if _, err := pool.Exec(context.Background(),
+			`TRUNCATE orders, privacy_audit, audit_log, archive_manifest, archive_restores CASCADE`); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return postgress.NewRepository(pool, nil)
+	})
+}
+
+func TestInsertOrder_SameUIDConcurrent_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	// после подмены order_uid уникален только вместе с date_created
+	if err := postgress.PreparePartitions(ctx, pool); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if err := postgress.SwapOrderPartitions(ctx, pool, time.Second); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+
+	// повтор заказа с другим date_created не должен стать вторым заказом
+	now := time.Now().UTC().Truncate(time.Second)
+	const inserts = 8
+	errs := make(chan error, inserts)
+	for i := range inserts {
+		go func() {
+			errs <- postgress.InsertOrder(ctx, pool, nil, makeOrder("dup", now.Add(time.Duration(i)*time.Minute)))
+		}()
+	}
+	for range inserts {
+		if err := <-errs; err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	for _, table := range []string{"orders", "delivery", "payment"} {
+		var n int
+		if err := pool.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s WHERE order_uid = 'dup'`, table)).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 1 {
+			t.Fatalf("expected 1 row in %s, got %d", table, n)
+		}
+	}
+}
+
+func TestRepository_InsertRejected_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+	repo := postgress.NewRepository(pool, nil)
+
+	// locale длиннее VARCHAR(10): ошибка класса 22, повтор ее не исправит
+	o := makeOrder("rejected-1", time.Now())
+	o.Locale = strings.Repeat("x", 11)
+	if err := repo.InsertOrder(ctx, o); !errors.Is(err, storage.ErrRejected) {
+		t.Fatalf("expected ErrRejected for too long locale, got %v", err)
+	}
+
+	// закрытый пул - ошибка соединения, ее можно повторить
+	closed, err := pgxpool.New(ctx, pool.Config().ConnString())
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	closed.Close()
+	err = postgress.NewRepository(closed, nil).InsertOrder(ctx, makeOrder("rejected-2", time.Now()))
+	if err == nil || errors.Is(err, storage.ErrRejected) {
+		t.Fatalf("expected retryable error on closed pool, got %v", err)
+	}
+}
+
 func TestGetLastOrders_Embedded(t *testing.T) {
 	port := getFreePort(t)
 	epg, pool := startEmbeddedPG(t, port)
@@ -180,20 +202,20 @@ func TestGetLastOrders_Embedded(t *testing.T) {
 		_ = epg.Stop()
 	}()
 
-	createTables(t, pool)
+	testutils.ApplyMigrations(t, pool)
 
 	ctx := context.Background()
 	o1 := makeOrder("o-old", time.Now().Add(-2*time.Hour))
 	o2 := makeOrder("o-new", time.Now().Add(-1*time.Hour))
 
-	if err := postgress.InsertOrder(ctx, pool, o1); err != nil {
+	if err := postgress.InsertOrder(ctx, pool, nil, o1); err != nil {
 		t.Fatalf("insert o1: %v", err)
 	}
-	if err := postgress.InsertOrder(ctx, pool, o2); err != nil {
+	if err := postgress.InsertOrder(ctx, pool, nil, o2); err != nil {
 		t.Fatalf("insert o2: %v", err)
 	}
 
-	got, err := postgress.GetLastOrders(ctx, pool, 1)
+	got, err := postgress.GetLastOrders(ctx, pool, nil, 1)
 	if err != nil {
 		t.Fatalf("GetLastOrders failed: %v", err)
 	}
@@ -204,3 +226,779 @@ func TestGetLastOrders_Embedded(t *testing.T) {
 		t.Fatalf("expected latest o-new, got %s", got[0].OrderUID)
 	}
 }
+
+func TestEncryptedDelivery_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	// строка, записанная до включения шифрования
+	plain := makeOrder("enc-plain", time.Now().Add(-time.Hour))
+	if err := postgress.InsertOrder(ctx, pool, nil, plain); err != nil {
+		t.Fatalf("insert plain: %v", err)
+	}
+
+	c, err := encryption.NewCipher("master-1", bytes.Repeat([]byte{7}, encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	if err := postgress.LoadDataKeys(ctx, pool, c); err != nil {
+		t.Fatalf("LoadDataKeys: %v", err)
+	}
+
+	o := makeOrder("enc-1", time.Now())
+	if err := postgress.InsertOrder(ctx, pool, c, o); err != nil {
+		t.Fatalf("insert encrypted: %v", err)
+	}
+	// база с ключами данных не принимает открытый текст
+	if err := postgress.InsertOrder(ctx, pool, nil, makeOrder("enc-2", time.Now())); !errors.Is(err, postgress.ErrCipherRequired) {
+		t.Fatalf("expected ErrCipherRequired without cipher, got %v", err)
+	}
+	if err := postgress.NewRepository(pool, nil).InsertOrder(ctx, makeOrder("enc-2", time.Now())); !errors.Is(err, storage.ErrRejected) {
+		t.Fatalf("expected ErrRejected without cipher, got %v", err)
+	}
+	if _, err := postgress.GetOrder(ctx, o.OrderUID, pool, nil); err == nil {
+		t.Fatalf("expected error reading encrypted delivery without cipher")
+	}
+
+	var storedPhone string
+	if err := pool.QueryRow(ctx, `SELECT phone FROM delivery WHERE order_uid = $1`, o.OrderUID).Scan(&storedPhone); err != nil {
+		t.Fatalf("select phone: %v", err)
+	}
+	if storedPhone == o.Delivery.Phone {
+		t.Fatalf("phone is stored in plaintext")
+	}
+
+	got, err := postgress.GetOrder(ctx, o.OrderUID, pool, c)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got.Delivery != o.Delivery {
+		t.Fatalf("delivery mismatch: got %+v want %+v", got.Delivery, o.Delivery)
+	}
+
+	// ротация: новый ключ и перешифровка всех строк, включая открытые
+	if _, err := postgress.CreateDataKey(ctx, pool, c); err != nil {
+		t.Fatalf("CreateDataKey: %v", err)
+	}
+	n, err := postgress.EncryptDeliveries(ctx, pool, c, 1)
+	if err != nil {
+		t.Fatalf("EncryptDeliveries: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows re-encrypted, got %d", n)
+	}
+
+	orders, err := postgress.GetLastOrders(ctx, pool, c, 10)
+	if err != nil {
+		t.Fatalf("GetLastOrders: %v", err)
+	}
+	for _, got := range orders {
+		if got.Delivery.Phone != "P" || got.Delivery.Name != "N" {
+			t.Fatalf("unexpected delivery after rotation: %+v", got.Delivery)
+		}
+	}
+}
+
+func TestCustomerExportAndAnonymize_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	o1 := makeOrder("cust-1", time.Now().Add(-2*time.Hour))
+	o2 := makeOrder("cust-2", time.Now().Add(-time.Hour))
+	other := makeOrder("other-1", time.Now())
+	other.CustomerID = "someone-else"
+	for _, o := range []*models.Order{o1, o2, other} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+
+	orders, err := postgress.GetCustomerOrders(ctx, pool, nil, "cust")
+	if err != nil {
+		t.Fatalf("GetCustomerOrders: %v", err)
+	}
+	if len(orders) != 2 || orders[0].OrderUID != "cust-1" || orders[1].OrderUID != "cust-2" {
+		t.Fatalf("unexpected customer orders: %+v", orders)
+	}
+	if err := postgress.LogCustomerExport(ctx, pool, "cust", "jwt:alice", orders); err != nil {
+		t.Fatalf("LogCustomerExport: %v", err)
+	}
+
+	uids, err := postgress.AnonymizeCustomer(ctx, pool, "cust", "jwt:alice")
+	if err != nil {
+		t.Fatalf("AnonymizeCustomer: %v", err)
+	}
+	if len(uids) != 2 {
+		t.Fatalf("expected 2 anonymized orders, got %v", uids)
+	}
+
+	got, err := postgress.GetOrder(ctx, "cust-1", pool, nil)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got.Delivery != (models.Delivery{}) {
+		t.Fatalf("expected empty delivery, got %+v", got.Delivery)
+	}
+	if got.Payment.Amount != o1.Payment.Amount || len(got.Items) != 1 {
+		t.Fatalf("financial data must be kept: %+v", got)
+	}
+
+	untouched, err := postgress.GetOrder(ctx, "other-1", pool, nil)
+	if err != nil || untouched.Delivery.Name != "N" {
+		t.Fatalf("other customer must be untouched: %+v, %v", untouched.Delivery, err)
+	}
+
+	var audits int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM privacy_audit WHERE customer_id = 'cust'`).Scan(&audits); err != nil {
+		t.Fatalf("count audit: %v", err)
+	}
+	if audits != 2 {
+		t.Fatalf("expected export and anonymize in audit, got %d rows", audits)
+	}
+}
+
+func TestAuditLog_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	entries := []models.AuditEntry{
+		{Time: base, Principal: "jwt:alice", Action: "read", Method: "GET", Route: "/order/{order_uid}", Path: "/order/o-1", OrderUID: "o-1", Status: 200, SourceIP: "10.0.0.1"},
+		{Time: base.Add(time.Hour), Principal: "api_key:support-bot", Action: "read", Method: "GET", Route: "/order/{order_uid}", Path: "/order/o-1", OrderUID: "o-1", Status: 200, SourceIP: "10.0.0.2"},
+		{Time: base.Add(2 * time.Hour), Principal: "jwt:alice", Action: "ingest", Method: "POST", Route: "/order", Path: "/order", Status: 400, SourceIP: "10.0.0.1"},
+	}
+	if err := postgress.InsertAuditEntries(ctx, pool, entries); err != nil {
+		t.Fatalf("InsertAuditEntries: %v", err)
+	}
+
+	got, total, err := postgress.QueryAudit(ctx, pool, models.AuditFilter{Principal: "jwt:alice", Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryAudit: %v", err)
+	}
+	if total != 2 || len(got) != 1 || got[0].Action != "ingest" {
+		t.Fatalf("expected newest of 2 alice entries, got total %d: %+v", total, got)
+	}
+
+	got, total, err = postgress.QueryAudit(ctx, pool, models.AuditFilter{
+		OrderUID: "o-1",
+		From:     base.Add(30 * time.Minute),
+		To:       base.Add(90 * time.Minute),
+		Limit:    10,
+	})
+	if err != nil {
+		t.Fatalf("QueryAudit: %v", err)
+	}
+	if total != 1 || got[0].Principal != "api_key:support-bot" {
+		t.Fatalf("unexpected entries for o-1 in range: %+v", got)
+	}
+
+	if _, err := pool.Exec(ctx, `DELETE FROM audit_log`); err == nil {
+		t.Fatalf("audit_log must be append-only")
+	}
+}
+
+func TestToTSQuery(t *testing.T) {
+	cases := []struct {
+		text   string
+		prefix bool
+		want   string
+	}{
+		{"Nike", false, "nike"},
+		{"  air  MAX ", false, "air & max"},
+		{"air max", true, "air & max:*"},
+		{"a&b | !c:*", false, "a & b & c"},
+		{"Тестов Иван", true, "тестов & иван:*"},
+	}
+	for _, c := range cases {
+		got, err := postgress.ToTSQuery(c.text, c.prefix)
+		if err != nil || got != c.want {
+			t.Fatalf("ToTSQuery(%q, %v) = %q, %v; want %q", c.text, c.prefix, got, err, c.want)
+		}
+	}
+	if _, err := postgress.ToTSQuery(" -!- ", true); !errors.Is(err, storage.ErrEmptySearch) {
+		t.Fatalf("expected ErrEmptySearch, got %v", err)
+	}
+}
+
+func TestSearchOrders_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	shoes := makeOrder("s-shoes", time.Now().Add(-time.Hour))
+	shoes.Items[0].Name = "Air Max"
+	shoes.Items[0].Brand = "Nike"
+	shoes.Delivery.Name = "Ivan Petrov"
+	bag := makeOrder("s-bag", time.Now())
+	bag.Items[0].Name = "Sport bag"
+	bag.Items[0].Brand = "Nike"
+	other := makeOrder("s-other", time.Now())
+	for _, o := range []*models.Order{shoes, bag, other} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+
+	got, total, err := postgress.SearchOrders(ctx, pool, nil, models.SearchQuery{Text: "nike", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchOrders: %v", err)
+	}
+	if total != 2 || len(got) != 2 {
+		t.Fatalf("expected 2 orders with nike, got total %d: %+v", total, got)
+	}
+	if len(got[0].Snippets) == 0 || !strings.Contains(got[0].Snippets[0].Text, "<mark>Nike</mark>") {
+		t.Fatalf("expected highlighted snippet, got %+v", got[0].Snippets)
+	}
+
+	got, _, err = postgress.SearchOrders(ctx, pool, nil, models.SearchQuery{Text: "air ma", Prefix: true, Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchOrders prefix: %v", err)
+	}
+	if len(got) != 1 || got[0].OrderUID != "s-shoes" {
+		t.Fatalf("expected s-shoes by prefix, got %+v", got)
+	}
+
+	got, _, err = postgress.SearchOrders(ctx, pool, nil, models.SearchQuery{Text: "petrov", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchOrders by name: %v", err)
+	}
+	if len(got) != 1 || got[0].Snippets[0].Field != "delivery.name" {
+		t.Fatalf("expected s-shoes by delivery name, got %+v", got)
+	}
+
+	got, _, err = postgress.SearchOrders(ctx, pool, nil, models.SearchQuery{Text: "T-s-bag", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchOrders by track number: %v", err)
+	}
+	if len(got) != 1 || got[0].OrderUID != "s-bag" {
+		t.Fatalf("expected s-bag by track number, got %+v", got)
+	}
+}
+
+func TestSearchOrders_EncryptedName_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	c, err := encryption.NewCipher("master-1", bytes.Repeat([]byte{7}, encryption.KeySize))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	if err := postgress.LoadDataKeys(ctx, pool, c); err != nil {
+		t.Fatalf("LoadDataKeys: %v", err)
+	}
+	o := makeOrder("s-enc", time.Now())
+	o.Delivery.Name = "Ivan Petrov"
+	if err := postgress.InsertOrder(ctx, pool, c, o); err != nil {
+		t.Fatalf("insert encrypted: %v", err)
+	}
+	// шифртекст имени не попадает в полнотекстовый индекс
+	var indexed bool
+	if err := pool.QueryRow(ctx, `SELECT search_vector IS NOT NULL FROM delivery WHERE order_uid = $1`, o.OrderUID).Scan(&indexed); err != nil {
+		t.Fatalf("select search_vector: %v", err)
+	}
+	if indexed {
+		t.Fatalf("encrypted name is in search_vector")
+	}
+
+	got, total, err := postgress.SearchOrders(ctx, pool, c, models.SearchQuery{Text: "PETROV ivan", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchOrders: %v", err)
+	}
+	if total != 1 || len(got) != 1 || got[0].OrderUID != "s-enc" {
+		t.Fatalf("expected s-enc by encrypted name, got total %d: %+v", total, got)
+	}
+	want := models.SearchSnippet{Field: "delivery.name", Text: "<mark>Ivan</mark> <mark>Petrov</mark>"}
+	if len(got[0].Snippets) != 1 || got[0].Snippets[0] != want {
+		t.Fatalf("unexpected snippets %+v", got[0].Snippets)
+	}
+
+	// слепой индекс знает только целые слова, без шифра токенов нет
+	for _, sq := range []models.SearchQuery{{Text: "petr", Prefix: true, Limit: 10}, {Text: "petro", Limit: 10}} {
+		if got, _, err := postgress.SearchOrders(ctx, pool, c, sq); err != nil || len(got) != 0 {
+			t.Fatalf("%+v: expected no results, got %+v, %v", sq, got, err)
+		}
+	}
+	if got, _, err := postgress.SearchOrders(ctx, pool, nil, models.SearchQuery{Text: "petrov", Limit: 10}); err != nil || len(got) != 0 {
+		t.Fatalf("expected no results without cipher, got %+v, %v", got, err)
+	}
+}
+
+func TestCustomerHistory_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	o1 := makeOrder("h-1", base)
+	o2 := makeOrder("h-2", base.Add(24*time.Hour))
+	o2.Payment.Amount = 15
+	o3 := makeOrder("h-3", base.Add(48*time.Hour))
+	o3.Payment.Currency = "EUR"
+	o3.DeliveryService = "dhl"
+	o3.Items = append(o3.Items, models.Item{ChrtID: 2, TrackNumber: "T-h-3", Name: "m", Brand: "b"})
+	other := makeOrder("h-other", base)
+	other.CustomerID = "someone-else"
+	for _, o := range []*models.Order{o1, o2, o3, other} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+
+	stats, err := postgress.GetCustomerStats(ctx, pool, "cust")
+	if err != nil {
+		t.Fatalf("GetCustomerStats: %v", err)
+	}
+	if stats.TotalOrders != 3 || stats.TotalSpent["USD"] != 25 || stats.TotalSpent["EUR"] != 10 {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+	if stats.FirstOrderAt == nil || !stats.FirstOrderAt.Equal(base) ||
+		stats.LastOrderAt == nil || !stats.LastOrderAt.Equal(base.Add(48*time.Hour)) {
+		t.Fatalf("unexpected order dates: %v, %v", stats.FirstOrderAt, stats.LastOrderAt)
+	}
+	if stats.TopDeliveryService != "meest" {
+		t.Fatalf("expected meest as top delivery service, got %q", stats.TopDeliveryService)
+	}
+
+	page, err := postgress.GetCustomerOrderPage(ctx, pool, "cust", 2, 0)
+	if err != nil {
+		t.Fatalf("GetCustomerOrderPage: %v", err)
+	}
+	if len(page) != 2 || page[0].OrderUID != "h-3" || page[0].Items != 2 || page[0].Currency != "EUR" || page[1].OrderUID != "h-2" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	empty, err := postgress.GetCustomerStats(ctx, pool, "nobody")
+	if err != nil {
+		t.Fatalf("GetCustomerStats for unknown customer: %v", err)
+	}
+	if empty.TotalOrders != 0 || empty.FirstOrderAt != nil || len(empty.TotalSpent) != 0 {
+		t.Fatalf("expected empty stats, got %+v", empty)
+	}
+}
+
+func TestAnalytics_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	day := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	o1 := makeOrder("a-1", day.Add(time.Hour))
+	o1.Payment.Amount = 30
+	o1.Items = append(o1.Items, models.Item{ChrtID: 2, TrackNumber: "T-a-1", Name: "m", TotalPrice: 25, Brand: "c"})
+	o2 := makeOrder("a-2", day.Add(2*time.Hour))
+	o2.Payment.Amount = 50
+	o2.DeliveryService = "dhl"
+	o3 := makeOrder("a-3", day.Add(26*time.Hour))
+	o4 := makeOrder("a-4", day.Add(3*time.Hour))
+	o4.Payment.Currency = "EUR"
+	for _, o := range []*models.Order{o1, o2, o3, o4} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+
+	q := models.AnalyticsQuery{
+		Report:   models.ReportSales,
+		Bucket:   models.BucketDay,
+		From:     day,
+		To:       day.Add(48 * time.Hour),
+		Currency: "USD",
+	}
+	rows, err := postgress.GetAnalytics(ctx, pool, q)
+	if err != nil {
+		t.Fatalf("sales: %v", err)
+	}
+	if len(rows) != 2 || !rows[0].Bucket.Equal(day) || rows[0].Orders != 2 || rows[0].Items != 3 ||
+		rows[0].Revenue != 80 || rows[0].AvgBasket != 40 || rows[1].Orders != 1 {
+		t.Fatalf("unexpected sales: %+v", rows)
+	}
+
+	q.Report = models.ReportBrands
+	q.To = day.Add(24 * time.Hour)
+	q.Top = 1
+	rows, err = postgress.GetAnalytics(ctx, pool, q)
+	if err != nil {
+		t.Fatalf("brands: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Key != "c" || rows[0].Revenue != 25 || rows[0].Orders != 1 {
+		t.Fatalf("unexpected brands: %+v", rows)
+	}
+
+	q.Report = models.ReportDeliveryServices
+	q.Currency = ""
+	q.Top = 0
+	rows, err = postgress.GetAnalytics(ctx, pool, q)
+	if err != nil {
+		t.Fatalf("delivery services: %v", err)
+	}
+	if len(rows) != 3 || rows[0].Currency != "EUR" || rows[1].Key != "dhl" || rows[2].Key != "meest" || rows[2].Revenue != 30 {
+		t.Fatalf("unexpected delivery services: %+v", rows)
+	}
+}
+
+func TestRollups_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	from, to := day, day.Add(72*time.Hour)
+	o1 := makeOrder("r-1", day.Add(time.Hour))
+	o1.Items = append(o1.Items, models.Item{ChrtID: 2, TrackNumber: "T-r-1", Name: "m", TotalPrice: 7, Brand: "c"})
+	o2 := makeOrder("r-2", day.Add(30*time.Hour))
+	o2.Payment.Provider = "q"
+	for _, o := range []*models.Order{o1, o2, o1} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+
+	// повторная вставка не учитывается дважды
+	mismatches, err := postgress.CheckRollups(ctx, pool, from, to)
+	if err != nil {
+		t.Fatalf("CheckRollups: %v", err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("expected consistent rollups, got %+v", mismatches)
+	}
+
+	q := models.AnalyticsQuery{Report: models.ReportBrands, Bucket: models.BucketWeek, From: from, To: to}
+	raw, err := postgress.GetAnalytics(ctx, pool, q)
+	if err != nil {
+		t.Fatalf("raw brands: %v", err)
+	}
+	q.Rollups = true
+	rolled, err := postgress.GetAnalytics(ctx, pool, q)
+	if err != nil {
+		t.Fatalf("rollup brands: %v", err)
+	}
+	if !reflect.DeepEqual(raw, rolled) || len(rolled) != 2 || rolled[0].Key != "b" || rolled[0].Orders != 2 {
+		t.Fatalf("rollup report differs from raw:\n%+v\n%+v", raw, rolled)
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE daily_provider_sales SET revenue = 0 WHERE provider = 'q'`); err != nil {
+		t.Fatalf("corrupt rollup: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `DELETE FROM daily_brand_sales WHERE brand = 'c'`); err != nil {
+		t.Fatalf("corrupt rollup: %v", err)
+	}
+	mismatches, err = postgress.CheckRollups(ctx, pool, from, to)
+	if err != nil {
+		t.Fatalf("CheckRollups: %v", err)
+	}
+	if len(mismatches) != 2 || mismatches[0].Table != "daily_brand_sales" || mismatches[0].Raw.Revenue != 7 ||
+		mismatches[1].Key != "q" || mismatches[1].Raw.Revenue != 10 {
+		t.Fatalf("unexpected mismatches: %+v", mismatches)
+	}
+
+	if err := postgress.RefreshRollups(ctx, pool, from, to); err != nil {
+		t.Fatalf("RefreshRollups: %v", err)
+	}
+	if mismatches, err = postgress.CheckRollups(ctx, pool, from, to); err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected consistent rollups after refresh, got %+v, %v", mismatches, err)
+	}
+}
+
+func TestPartitionMigration_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	month := fmt.Sprintf("orders_y%04dm%02d", now.Year(), int(now.Month()))
+	// заказ, записанный до подготовки секций: в секционированные таблицы его перенесет backfill
+	o1 := makeOrder("p-1", now)
+	if err := postgress.InsertOrder(ctx, pool, nil, o1); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// миграции секции не создают, это отдельный шаг
+	if err := postgress.BackfillOrderPartitions(ctx, pool, 10, nil); !errors.Is(err, postgress.ErrPartitionsNotPrepared) {
+		t.Fatalf("expected ErrPartitionsNotPrepared, got %v", err)
+	}
+	if err := postgress.PreparePartitions(ctx, pool); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if err := postgress.PreparePartitions(ctx, pool); !errors.Is(err, postgress.ErrPartitionsPrepared) {
+		t.Fatalf("expected ErrPartitionsPrepared, got %v", err)
+	}
+	if err := postgress.SwapOrderPartitions(ctx, pool, time.Second); err == nil {
+		t.Fatal("expected swap to fail before backfill")
+	}
+
+	if err := postgress.BackfillOrderPartitions(ctx, pool, 10, nil); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	// новые заказы попадают в секционированные таблицы триггерами
+	o2 := makeOrder("p-2", now)
+	if err := postgress.InsertOrder(ctx, pool, nil, o2); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	status, err := postgress.GetPartitionMigration(ctx, pool)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, table := range status.Tables {
+		if table.Rows != 2 || table.Copied != 2 {
+			t.Fatalf("unexpected copy status: %+v", status)
+		}
+	}
+
+	if err := postgress.SwapOrderPartitions(ctx, pool, time.Second); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	if err := postgress.BackfillOrderPartitions(ctx, pool, 10, nil); !errors.Is(err, postgress.ErrPartitionsSwapped) {
+		t.Fatalf("expected ErrPartitionsSwapped, got %v", err)
+	}
+	if err := postgress.RollbackPartitions(ctx, pool); !errors.Is(err, postgress.ErrPartitionsSwapped) {
+		t.Fatalf("expected no rollback after swap, got %v", err)
+	}
+
+	// повторная вставка после переключения не создает второй заказ
+	for _, o := range []*models.Order{o1, makeOrder("p-3", now)} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert after swap: %v", err)
+		}
+	}
+	var inMonth int
+	if err := pool.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s`, month)).Scan(&inMonth); err != nil {
+		t.Fatalf("count %s: %v", month, err)
+	}
+	if inMonth != 3 {
+		t.Fatalf("expected 3 orders in %s, got %d", month, inMonth)
+	}
+	got, err := postgress.GetOrder(ctx, "p-1", pool, nil)
+	if err != nil || len(got.Items) != 1 || got.Payment.Amount != 10 {
+		t.Fatalf("unexpected order after swap: %+v, %v", got, err)
+	}
+
+	created, err := postgress.CreateOrderPartitions(ctx, pool, now.AddDate(2, 0, 0), 1)
+	if err != nil || created != 4 {
+		t.Fatalf("expected 4 new partitions, got %d, %v", created, err)
+	}
+}
+
+func TestArchiveOrders_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, o := range []*models.Order{
+		makeOrder("old-1", now.AddDate(0, 0, -400)),
+		makeOrder("old-2", now.AddDate(0, 0, -380)),
+		makeOrder("new-1", now),
+	} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+	cutoff := archive.Cutoff(now, 365)
+
+	// при ошибке загрузки заказы остаются в базе
+	_, err := postgress.ArchiveOrders(ctx, pool, nil, cutoff, 10, func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error) {
+		return models.ArchiveObject{}, errors.New("store is down")
+	})
+	if err == nil {
+		t.Fatal("expected upload error")
+	}
+	if _, err := postgress.GetOrder(ctx, "old-1", pool, nil); err != nil {
+		t.Fatalf("order deleted after failed upload: %v", err)
+	}
+
+	store, err := archive.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	a := archive.New(models.ArchiveConfig{RetentionDays: 365, BatchSize: 1}, func(ctx context.Context, cutoff time.Time, limit int, upload archive.UploadFunc) (int, error) {
+		uids, err := postgress.ArchiveOrders(ctx, pool, nil, cutoff, limit, upload)
+		return len(uids), err
+	}, store, slog.New(slog.DiscardHandler))
+	n, err := a.Archive(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 orders archived, got %d, %v", n, err)
+	}
+
+	if _, err := postgress.GetOrder(ctx, "old-1", pool, nil); err == nil {
+		t.Fatal("archived order is still in database")
+	}
+	if _, err := postgress.GetOrder(ctx, "new-1", pool, nil); err != nil {
+		t.Fatalf("new order deleted: %v", err)
+	}
+	var items int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM items WHERE order_uid LIKE 'old-%'`).Scan(&items); err != nil || items != 0 {
+		t.Fatalf("expected archived items deleted, got %d, %v", items, err)
+	}
+
+	manifests, err := postgress.ListArchiveManifests(ctx, pool, 10)
+	if err != nil || len(manifests) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %+v, %v", manifests, err)
+	}
+	m := manifests[1]
+	if m.Status != models.ArchiveStatusArchived || m.Orders != 1 || !m.Cutoff.Equal(cutoff) {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	data, err := archive.Fetch(ctx, store, models.ArchiveObject{Key: m.Key, Size: m.Size, SHA256: m.SHA256})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	orders, err := archive.Decode(bytes.NewReader(data))
+	if err != nil || len(orders) != 1 || orders[0].OrderUID != "old-1" || orders[0].Delivery.Phone != "P" {
+		t.Fatalf("unexpected archived orders: %+v, %v", orders, err)
+	}
+
+	archivedBefore, ok, err := postgress.ArchivedBefore(ctx, pool)
+	if err != nil || !ok || !archivedBefore.Equal(cutoff) {
+		t.Fatalf("unexpected archive cutoff %v, %v, %v", archivedBefore, ok, err)
+	}
+}
+
+func TestRestoreArchivedOrders_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	kept := makeOrder("r-1", now.AddDate(0, 0, -400))
+	erased := makeOrder("r-2", now.AddDate(0, 0, -400))
+	erased.CustomerID = "erased"
+	for _, o := range []*models.Order{kept, erased} {
+		if err := postgress.InsertOrder(ctx, pool, nil, o); err != nil {
+			t.Fatalf("insert %s: %v", o.OrderUID, err)
+		}
+	}
+	day := now.AddDate(0, 0, -400).Truncate(24 * time.Hour)
+	rollupBefore, err := postgress.CheckRollups(ctx, pool, day, day.AddDate(0, 0, 1))
+	if err != nil || len(rollupBefore) != 0 {
+		t.Fatalf("unexpected rollups before archive: %+v, %v", rollupBefore, err)
+	}
+
+	store, err := archive.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	archiveOnce := func() int {
+		a := archive.New(models.ArchiveConfig{RetentionDays: 365}, func(ctx context.Context, cutoff time.Time, limit int, upload archive.UploadFunc) (int, error) {
+			uids, err := postgress.ArchiveOrders(ctx, pool, nil, cutoff, limit, upload)
+			return len(uids), err
+		}, store, slog.New(slog.DiscardHandler))
+		n, err := a.Archive(ctx)
+		if err != nil {
+			t.Fatalf("Archive: %v", err)
+		}
+		return n
+	}
+	if n := archiveOnce(); n != 2 {
+		t.Fatalf("expected 2 orders archived, got %d", n)
+	}
+
+	// обезличивание клиента, все заказы которого уже в архиве
+	uids, err := postgress.AnonymizeCustomer(ctx, pool, "erased", "test")
+	if err != nil || !reflect.DeepEqual(uids, []string{"r-2"}) {
+		t.Fatalf("expected archived order anonymized, got %v, %v", uids, err)
+	}
+
+	manifests, err := postgress.FindArchiveManifests(ctx, pool, []string{"r-1", "r-2", "missing"})
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("expected 1 manifest, got %+v, %v", manifests, err)
+	}
+	restore := func(ctx context.Context, o models.ArchivedOrder, manifestID int64) (bool, bool, error) {
+		return postgress.RestoreOrder(ctx, pool, nil, o, manifestID, "test")
+	}
+	report, err := archive.Restore(ctx, store, manifests, []string{"r-1", "r-2", "missing"}, restore)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !reflect.DeepEqual(report.Restored, []string{"r-1", "r-2"}) || !reflect.DeepEqual(report.NotFound, []string{"missing"}) ||
+		!reflect.DeepEqual(report.Anonymized, []string{"r-2"}) || len(report.Skipped) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if err := postgress.UpdateArchiveStatus(ctx, pool, report.Manifests); err != nil {
+		t.Fatalf("UpdateArchiveStatus: %v", err)
+	}
+	if m, err := postgress.GetArchiveManifest(ctx, pool, manifests[0].ID); err != nil || m.Status != models.ArchiveStatusRestored {
+		t.Fatalf("expected manifest restored, got %+v, %v", m, err)
+	}
+
+	got, err := postgress.GetOrder(ctx, "r-1", pool, nil)
+	if err != nil || got.Delivery != kept.Delivery || len(got.Items) != 1 {
+		t.Fatalf("unexpected restored order: %+v, %v", got, err)
+	}
+	if got, err := postgress.GetOrder(ctx, "r-2", pool, nil); err != nil || got.Delivery != (models.Delivery{}) {
+		t.Fatalf("expected anonymized delivery, got %+v, %v", got.Delivery, err)
+	}
+	// восстановленные заказы уже учтены в сводных таблицах
+	if mismatches, err := postgress.CheckRollups(ctx, pool, day, day.AddDate(0, 0, 1)); err != nil || len(mismatches) != 0 {
+		t.Fatalf("rollups changed after restore: %+v, %v", mismatches, err)
+	}
+
+	// повторное восстановление пропускает заказы, а архивация — только что восстановленные
+	report, err = archive.Restore(ctx, store, manifests, nil, restore)
+	if err != nil || len(report.Restored) != 0 || len(report.Skipped) != 2 {
+		t.Fatalf("unexpected second restore: %+v, %v", report, err)
+	}
+	if n := archiveOnce(); n != 0 {
+		t.Fatalf("expected restored orders kept, %d archived", n)
+	}
+}
+
+func TestImportExportOrders_Embedded(t *testing.T) {
+	_, pool := testutils.StartEmbeddedPG(t)
+	testutils.ApplyMigrations(t, pool)
+	ctx := context.Background()
+
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	var orders []models.Order
+	for i := range 5 {
+		o := makeOrder(fmt.Sprintf("bulk-%d", i), day.Add(time.Duration(i)*24*time.Hour))
+		if i%2 == 1 {
+			o.CustomerID = "other"
+		}
+		orders = append(orders, *o)
+	}
+	if err := postgress.InsertOrder(ctx, pool, nil, &orders[0]); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+
+	n, err := postgress.InsertOrders(ctx, pool, nil, orders)
+	if err != nil {
+		t.Fatalf("InsertOrders: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 new orders, got %d", n)
+	}
+	if mismatches, err := postgress.CheckRollups(ctx, pool, day, day.Add(5*24*time.Hour)); err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected consistent rollups, got %+v, %v", mismatches, err)
+	}
+
+	// пачка с ошибкой откатывается целиком
+	broken := []models.Order{*makeOrder("bulk-new", day), *makeOrder("bulk-bad", day)}
+	broken[1].Items[0].Name = strings.Repeat("x", 300)
+	if _, err := postgress.InsertOrders(ctx, pool, nil, broken); err == nil {
+		t.Fatal("expected error for broken batch")
+	}
+	if _, err := postgress.GetOrder(ctx, "bulk-new", pool, nil); err == nil {
+		t.Fatal("order from rolled back batch was inserted")
+	}
+
+	var uids []string
+	collect := func(o models.Order) error {
+		uids = append(uids, o.OrderUID)
+		return nil
+	}
+	filter := models.OrderFilter{From: day.Add(24 * time.Hour), CustomerID: "cust"}
+	if n, err = postgress.ExportOrders(ctx, pool, nil, filter, collect); err != nil {
+		t.Fatalf("ExportOrders: %v", err)
+	}
+	if n != 2 || !reflect.DeepEqual(uids, []string{"bulk-2", "bulk-4"}) {
+		t.Fatalf("unexpected export %v", uids)
+	}
+
+	uids = nil
+	if n, err = postgress.ExportOrders(ctx, pool, nil, models.OrderFilter{Limit: 3}, collect); err != nil || n != 3 {
+		t.Fatalf("ExportOrders with limit: %d, %v", n, err)
+	}
+}
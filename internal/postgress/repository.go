@@ -0,0 +1,164 @@
+package postgress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repository хранилище заказов в Postgres
+type Repository struct {
+	pool   *pgxpool.Pool
+	cipher *encryption.Cipher
+}
+
+var _ storage.Repository = (*Repository)(nil)
+
+// NewRepository создает хранилище поверх пула соединений.
+// cipher шифрует данные доставки, nil - шифрование выключено
+func NewRepository(pool *pgxpool.Pool, cipher *encryption.Cipher) *Repository {
+	return &Repository{pool: pool, cipher: cipher}
+}
+
+// InsertOrder сохраняет заказ в одной транзакции. Ошибки, которые повтор не исправит,
+// содержат storage.ErrRejected
+func (r *Repository) InsertOrder(ctx context.Context, order *models.Order) error {
+	err := InsertOrder(ctx, r.pool, r.cipher, order)
+	if rejected(err) {
+		return fmt.Errorf("%w: %w", storage.ErrRejected, err)
+	}
+	return err
+}
+
+// rejected ошибка из-за данных заказа или настроек сервиса: неверные данные (класс 22),
+// нарушение ограничения (класс 23) или запись delivery без шифра
+func rejected(err error) bool {
+	if errors.Is(err, ErrCipherRequired) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+	}
+	return false
+}
+
+// GetOrder возвращает заказ. Если заказа нет, ошибка содержит storage.ErrOrderNotFound
+func (r *Repository) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	order, err := GetOrder(ctx, orderUID, r.pool, r.cipher)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return order, fmt.Errorf("get order %s: %w", orderUID, storage.ErrOrderNotFound)
+	}
+	return order, err
+}
+
+// GetOrderByTrackNumber возвращает самый новый заказ с трек-номером
+func (r *Repository) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (models.Order, error) {
+	order, err := GetOrderByTrackNumber(ctx, r.pool, r.cipher, trackNumber)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return order, fmt.Errorf("get order by track number %s: %w", trackNumber, storage.ErrOrderNotFound)
+	}
+	return order, err
+}
+
+// GetOrderByTransaction возвращает самый новый заказ с транзакцией оплаты
+func (r *Repository) GetOrderByTransaction(ctx context.Context, transaction string) (models.Order, error) {
+	order, err := GetOrderByTransaction(ctx, r.pool, r.cipher, transaction)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return order, fmt.Errorf("get order by transaction %s: %w", transaction, storage.ErrOrderNotFound)
+	}
+	return order, err
+}
+
+// GetLastOrders возвращает limit последних заказов по date_created
+func (r *Repository) GetLastOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	return GetLastOrders(ctx, r.pool, r.cipher, limit)
+}
+
+// SearchOrders ищет заказы полнотекстовым поиском Postgres
+func (r *Repository) SearchOrders(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, int, error) {
+	return SearchOrders(ctx, r.pool, r.cipher, q)
+}
+
+// CustomerStats считает сводку по заказам клиента одним запросом
+func (r *Repository) CustomerStats(ctx context.Context, customerID string) (models.CustomerStats, error) {
+	return GetCustomerStats(ctx, r.pool, customerID)
+}
+
+// CustomerOrders возвращает страницу заказов клиента от новых к старым
+func (r *Repository) CustomerOrders(ctx context.Context, customerID string, limit, offset int) ([]models.CustomerOrder, error) {
+	return GetCustomerOrderPage(ctx, r.pool, customerID, limit, offset)
+}
+
+// Analytics считает отчет по сырым таблицам или, с q.Rollups, по сводным
+func (r *Repository) Analytics(ctx context.Context, q models.AnalyticsQuery) ([]models.AnalyticsRow, error) {
+	return GetAnalytics(ctx, r.pool, q)
+}
+
+// ExportCustomer возвращает все заказы клиента и записывает выгрузку в privacy_audit
+func (r *Repository) ExportCustomer(ctx context.Context, customerID, actor string) ([]models.Order, error) {
+	orders, err := GetCustomerOrders(ctx, r.pool, r.cipher, customerID)
+	if err != nil || len(orders) == 0 {
+		return orders, err
+	}
+	if err := LogCustomerExport(ctx, r.pool, customerID, actor, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// AnonymizeCustomer затирает данные доставки клиента в одной транзакции с записью в privacy_audit
+func (r *Repository) AnonymizeCustomer(ctx context.Context, customerID, actor string) ([]string, error) {
+	return AnonymizeCustomer(ctx, r.pool, customerID, actor)
+}
+
+// ArchiveOrders выгружает и удаляет пачку старых заказов
+func (r *Repository) ArchiveOrders(ctx context.Context, cutoff time.Time, limit int,
+	upload func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error),
+) ([]string, error) {
+	return ArchiveOrders(ctx, r.pool, r.cipher, cutoff, limit, upload)
+}
+
+// ArchiveManifest возвращает файл архива. Если его нет, ошибка содержит storage.ErrManifestNotFound
+func (r *Repository) ArchiveManifest(ctx context.Context, id int64) (models.ArchiveManifest, error) {
+	m, err := GetArchiveManifest(ctx, r.pool, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return m, fmt.Errorf("get archive manifest %d: %w", id, storage.ErrManifestNotFound)
+	}
+	return m, err
+}
+
+// FindArchiveManifests возвращает файлы архива с заказами uids
+func (r *Repository) FindArchiveManifests(ctx context.Context, uids []string) ([]models.ArchiveManifest, error) {
+	return FindArchiveManifests(ctx, r.pool, uids)
+}
+
+// RestoreOrder возвращает заказ из архива через ту же вставку, что и InsertOrder
+func (r *Repository) RestoreOrder(ctx context.Context, order models.ArchivedOrder, manifestID int64, actor string) (bool, bool, error) {
+	return RestoreOrder(ctx, r.pool, r.cipher, order, manifestID, actor)
+}
+
+// UpdateArchiveStatus помечает полностью восстановленные файлы архива
+func (r *Repository) UpdateArchiveStatus(ctx context.Context, ids []int64) error {
+	return UpdateArchiveStatus(ctx, r.pool, ids)
+}
+
+// InsertAuditEntries записывает пачку записей журнала доступа одним COPY
+func (r *Repository) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	return InsertAuditEntries(ctx, r.pool, entries)
+}
+
+// QueryAudit возвращает записи журнала доступа по фильтру
+func (r *Repository) QueryAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	return QueryAudit(ctx, r.pool, f)
+}
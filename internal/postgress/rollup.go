@@ -0,0 +1,138 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"demoserv/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxRollupMismatches сколько расхождений с сырыми таблицами возвращать по каждой сводной таблице
+const maxRollupMismatches = 100
+
+// rollup сводная таблица по дням: разрез column, агрегаты как у отчета report
+type rollup struct {
+	table  string
+	column string
+	report string
+}
+
+var rollups = []rollup{
+	{table: "daily_brand_sales", column: "brand", report: models.ReportBrands},
+	{table: "daily_delivery_sales", column: "delivery_service", report: models.ReportDeliveryServices},
+	{table: "daily_provider_sales", column: "provider", report: models.ReportProviders},
+}
+
+// aggregate агрегаты по дням из сырых таблиц
+func (r rollup) aggregate(where string) string {
+	return analyticsReports[r.report].aggregate("o.date_created::date", where)
+}
+
+// updateRollups добавляет только что вставленный заказ в сводные таблицы
+func updateRollups(ctx context.Context, tx pgx.Tx, orderUID string) error {
+	for _, r := range rollups {
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %[1]s AS r (day, %[2]s, currency, orders, items, revenue)
+			%[3]s
+			ON CONFLICT (day, %[2]s, currency) DO UPDATE SET
+				orders = r.orders + EXCLUDED.orders,
+				items = r.items + EXCLUDED.items,
+				revenue = r.revenue + EXCLUDED.revenue`,
+			r.table, r.column, r.aggregate("o.order_uid = $1 AND o.date_created IS NOT NULL")), orderUID)
+		if err != nil {
+			return fmt.Errorf("unable to update %s: %w", r.table, err)
+		}
+	}
+	return nil
+}
+
+// RefreshRollups пересчитывает сводные таблицы за дни [from, to) по сырым таблицам.
+// Таблицы блокируются от вставок до конца пересчета, чтобы новый заказ не потерялся и не учелся дважды
+func RefreshRollups(ctx context.Context, pool *pgxpool.Pool, from, to time.Time) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		LOCK TABLE daily_brand_sales, daily_delivery_sales, daily_provider_sales IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("unable to lock rollups: %v", err)
+	}
+	for _, r := range rollups {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE day >= $1 AND day < $2`, r.table),
+			from, to); err != nil {
+			return fmt.Errorf("unable to clear %s: %v", r.table, err)
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %s (day, %s, currency, orders, items, revenue)
+			%s`, r.table, r.column, r.aggregate("o.date_created >= $1 AND o.date_created < $2")),
+			from, to); err != nil {
+			return fmt.Errorf("unable to fill %s: %v", r.table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// CheckRollups сравнивает сводные таблицы за дни [from, to) с агрегатами по сырым таблицам
+func CheckRollups(ctx context.Context, pool *pgxpool.Pool, from, to time.Time) ([]models.RollupMismatch, error) {
+	result := []models.RollupMismatch{}
+	for _, r := range rollups {
+		rows, err := pool.Query(ctx, fmt.Sprintf(`
+			WITH summary AS (
+				SELECT day, %[2]s AS key, currency, orders, items, revenue
+				FROM %[1]s
+				WHERE day >= $1 AND day < $2
+			), raw AS (%[3]s)
+			SELECT COALESCE(r.day, w.bucket), COALESCE(r.key, w.key), COALESCE(r.currency, w.currency),
+			       COALESCE(r.orders, 0), COALESCE(r.items, 0), COALESCE(r.revenue, 0),
+			       COALESCE(w.orders, 0), COALESCE(w.items, 0), COALESCE(w.revenue, 0)
+			FROM summary r
+			FULL JOIN raw w ON w.bucket = r.day AND w.key = r.key AND w.currency = r.currency
+			WHERE (r.orders, r.items, r.revenue) IS DISTINCT FROM (w.orders, w.items, w.revenue)
+			ORDER BY 1, 2, 3
+			LIMIT $3`,
+			r.table, r.column, r.aggregate("o.date_created >= $1 AND o.date_created < $2")),
+			from, to, maxRollupMismatches)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", r.table, err)
+		}
+
+		for rows.Next() {
+			m := models.RollupMismatch{Table: r.table}
+			if err := rows.Scan(&m.Day, &m.Key, &m.Currency,
+				&m.Rollup.Orders, &m.Rollup.Items, &m.Rollup.Revenue,
+				&m.Raw.Orders, &m.Raw.Items, &m.Raw.Revenue); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan %s mismatch: %w", r.table, err)
+			}
+			result = append(result, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("check %s: %w", r.table, err)
+		}
+	}
+	return result, nil
+}
+
+// OrdersPeriod первый день с заказами и день после последнего, ok=false если заказов нет
+func OrdersPeriod(ctx context.Context, pool *pgxpool.Pool) (from, to time.Time, ok bool, err error) {
+	var first, last *time.Time
+	if err := pool.QueryRow(ctx, `
+		SELECT min(date_created)::date, max(date_created)::date + 1 FROM orders`).Scan(&first, &last); err != nil {
+		return from, to, false, fmt.Errorf("select orders period: %w", err)
+	}
+	if first == nil {
+		return from, to, false, nil
+	}
+	return *first, *last, true, nil
+}
@@ -0,0 +1,190 @@
+package postgress
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"demoserv/internal/encryption"
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// searchMatches совпадения по всем полям поиска: заказ, поле, текст и ранг.
+// Все слова запроса должны найтись в одной строке: в трек-номере,
+// в товаре (название и бренд) или в имени получателя.
+// Зашифрованные имена не попадают в search_vector и ищутся по слепому индексу
+// name_index ($2): ранг по шифртексту не посчитать, он берется как у совпадения
+// с весом C, а текст фрагмента заполняется после расшифровки
+const searchMatches = `
+	WITH q AS (SELECT to_tsquery('simple', $1) AS query),
+	matches AS (
+		SELECT o.order_uid, 'track_number' AS field, o.track_number AS text,
+		       ts_rank(o.search_vector, q.query) AS rank
+		FROM orders o, q
+		WHERE o.search_vector @@ q.query
+		UNION ALL
+		SELECT i.order_uid, 'items', concat_ws(' ', i.name, i.brand),
+		       ts_rank(i.search_vector, q.query)
+		FROM items i, q
+		WHERE i.search_vector @@ q.query
+		UNION ALL
+		SELECT d.order_uid, 'delivery.name', d.name,
+		       ts_rank(d.search_vector, q.query)
+		FROM delivery d, q
+		WHERE d.search_vector @@ q.query
+		UNION ALL
+		SELECT d.order_uid, 'delivery.name', NULL, 0.1::real
+		FROM delivery d
+		WHERE d.name_index @> $2::text[] AND d.key_id IS NOT NULL
+	)`
+
+// SearchOrders ищет заказы по названию и бренду товаров, имени получателя и трек-номеру.
+// Возвращает страницу заказов от самых релевантных и общее число найденных заказов.
+// Зашифрованные имена находятся только по целым словам, prefix к ним не применяется
+func SearchOrders(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, sq models.SearchQuery) ([]models.SearchResult, int, error) {
+	terms, err := storage.SearchTerms(sq.Text)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := toTSQuery(terms, sq.Prefix)
+	// без шифра NULL: по зашифрованным именам ничего не находится
+	var tokens []string
+	if c != nil {
+		tokens = nameIndex(c, strings.Join(terms, " "))
+	}
+
+	var total int
+	err = pool.QueryRow(ctx, searchMatches+`
+		SELECT count(DISTINCT order_uid) FROM matches
+	`, query, tokens).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count search results: %w", err)
+	}
+
+	// фрагменты строятся только для заказов страницы: ts_headline дорогой
+	rows, err := pool.Query(ctx, searchMatches+`,
+	ranked AS (
+		SELECT order_uid, sum(rank) AS rank FROM matches GROUP BY order_uid
+	)
+	SELECT r.order_uid, o.track_number, COALESCE(o.customer_id, ''), o.date_created, r.rank,
+	       (SELECT json_agg(json_build_object(
+	                   'field', s.field,
+	                   'text', ts_headline('simple', s.text, q.query,
+	                                       'StartSel="<mark>", StopSel="</mark>", MaxWords=20, MinWords=5'))
+	                   ORDER BY s.rank DESC, s.field)
+	        FROM (SELECT * FROM matches m WHERE m.order_uid = r.order_uid
+	              ORDER BY m.rank DESC, m.field LIMIT 3) s, q)
+	FROM ranked r
+	JOIN orders o ON o.order_uid = r.order_uid
+	ORDER BY r.rank DESC, o.date_created DESC, r.order_uid
+	LIMIT $3 OFFSET $4
+	`, query, tokens, sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search orders: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.OrderUID, &r.TrackNumber, &r.CustomerID, &r.DateCreated, &r.Rank, &r.Snippets); err != nil {
+			return nil, 0, fmt.Errorf("scan search result: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("search orders: %w", err)
+	}
+	rows.Close()
+
+	if c != nil {
+		if err := encryptedNameSnippets(ctx, pool, c, results, terms); err != nil {
+			return nil, 0, err
+		}
+	}
+	return results, total, nil
+}
+
+// encryptedNameSnippets расшифровывает имена для фрагментов, найденных по слепому индексу,
+// и выделяет в них слова запроса
+func encryptedNameSnippets(ctx context.Context, pool *pgxpool.Pool, c *encryption.Cipher, results []models.SearchResult, terms []string) error {
+	var uids []string
+	for _, r := range results {
+		for _, s := range r.Snippets {
+			if s.Field == "delivery.name" && s.Text == "" {
+				uids = append(uids, r.OrderUID)
+			}
+		}
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT order_uid, name, key_id FROM delivery
+		WHERE order_uid = ANY($1) AND key_id IS NOT NULL
+	`, uids)
+	if err != nil {
+		return fmt.Errorf("select encrypted names: %w", err)
+	}
+	names := make(map[string]string, len(uids))
+	keys := make(map[string]string, len(uids))
+	for rows.Next() {
+		var uid, name, keyID string
+		if err := rows.Scan(&uid, &name, &keyID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan encrypted name: %w", err)
+		}
+		names[uid], keys[uid] = name, keyID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("select encrypted names: %w", err)
+	}
+
+	for i := range results {
+		uid := results[i].OrderUID
+		keyID, ok := keys[uid]
+		if !ok {
+			continue
+		}
+		if !c.HasKey(keyID) {
+			if err := loadDataKey(ctx, pool, c, keyID); err != nil {
+				return err
+			}
+		}
+		name, err := c.Decrypt(keyID, names[uid], uid+"/name")
+		if err != nil {
+			return fmt.Errorf("decrypt delivery.name of %s: %w", uid, err)
+		}
+		for j, s := range results[i].Snippets {
+			if s.Field == "delivery.name" && s.Text == "" {
+				results[i].Snippets[j].Text = storage.Highlight(name, terms, false)
+			}
+		}
+	}
+	return nil
+}
+
+// ToTSQuery строит tsquery из текста: слова из букв и цифр через &.
+// Остальные символы отбрасываются, поэтому синтаксис tsquery из запроса не проходит.
+// При prefix последнее слово ищется как префикс: "nik" найдет "nike"
+func ToTSQuery(text string, prefix bool) (string, error) {
+	terms, err := storage.SearchTerms(text)
+	if err != nil {
+		return "", err
+	}
+	return toTSQuery(terms, prefix), nil
+}
+
+func toTSQuery(terms []string, prefix bool) string {
+	terms = slices.Clone(terms)
+	if prefix {
+		terms[len(terms)-1] += ":*"
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,62 @@
+package postgress
+
+import (
+	"context"
+	"strings"
+
+	"demoserv/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("demoserv/internal/postgress")
+
+// queryTracer пишет спан на каждый запрос и COPY пула. Аргументы запросов
+// в спан не попадают, в них персональные данные
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	sql := strings.TrimSpace(data.SQL)
+	ctx, _ = tracer.Start(ctx, "postgres "+operation(sql),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBQueryText(sql),
+		),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	tracing.End(span, data.Err)
+}
+
+func (queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx, _ = tracer.Start(ctx, "postgres COPY",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			attribute.String("db.collection.name", data.TableName.Sanitize()),
+		),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	tracing.End(span, data.Err)
+}
+
+// operation первое слово запроса: SELECT, INSERT, BEGIN...
+func operation(sql string) string {
+	if i := strings.IndexAny(sql, " \t\r\n"); i > 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
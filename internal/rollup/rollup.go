@@ -0,0 +1,71 @@
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"demoserv/internal/lib/logger/sl"
+	"demoserv/internal/models"
+)
+
+const day = 24 * time.Hour
+
+// RefreshFunc пересчитывает сводные таблицы за дни [from, to), например postgress.RefreshRollups
+type RefreshFunc func(ctx context.Context, from, to time.Time) error
+
+// Refresher по расписанию пересчитывает сводные таблицы за последние дни,
+// чтобы исправить расхождения, если заказ попал в базу в обход вставки сервиса
+type Refresher struct {
+	refresh  RefreshFunc
+	interval time.Duration
+	days     int
+	log      *slog.Logger
+}
+
+// New создает задачу пересчета. Пересчет начинается после запуска Run
+func New(cfg models.RollupsConfig, refresh RefreshFunc, log *slog.Logger) *Refresher {
+	if cfg.RefreshDays <= 0 {
+		cfg.RefreshDays = 2
+	}
+	return &Refresher{
+		refresh:  refresh,
+		interval: cfg.RefreshInterval,
+		days:     cfg.RefreshDays,
+		log:      log,
+	}
+}
+
+// Period последние days дней по UTC, включая текущий
+func Period(now time.Time, days int) (from, to time.Time) {
+	to = now.UTC().Truncate(day).Add(day)
+	return to.Add(-time.Duration(days) * day), to
+}
+
+// Refresh один пересчет
+func (r *Refresher) Refresh(ctx context.Context) error {
+	from, to := Period(time.Now(), r.days)
+	start := time.Now()
+	if err := r.refresh(ctx, from, to); err != nil {
+		return err
+	}
+	r.log.Info("rollups refreshed",
+		slog.Time("from", from), slog.Time("to", to), slog.Duration("took", time.Since(start)))
+	return nil
+}
+
+// Run пересчитывает раз в interval до отмены ctx
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+			r.log.Warn("unable to refresh rollups", sl.Err(err))
+		}
+	}
+}
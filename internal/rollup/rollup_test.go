@@ -0,0 +1,39 @@
+package rollup_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"demoserv/internal/models"
+	"demoserv/internal/rollup"
+)
+
+func TestPeriod(t *testing.T) {
+	now := time.Date(2026, 4, 10, 15, 30, 0, 0, time.FixedZone("MSK", 3*3600))
+	from, to := rollup.Period(now, 2)
+	if want := time.Date(2026, 4, 9, 0, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Fatalf("expected from %v, got %v", want, from)
+	}
+	if want := time.Date(2026, 4, 11, 0, 0, 0, 0, time.UTC); !to.Equal(want) {
+		t.Fatalf("expected to %v, got %v", want, to)
+	}
+}
+
+func TestRefresher_Refresh(t *testing.T) {
+	var calls int
+	var gotFrom, gotTo time.Time
+	r := rollup.New(models.RollupsConfig{RefreshDays: 3}, func(_ context.Context, from, to time.Time) error {
+		calls++
+		gotFrom, gotTo = from, to
+		return nil
+	}, slog.New(slog.DiscardHandler))
+
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if calls != 1 || gotTo.Sub(gotFrom) != 3*24*time.Hour || gotTo.Before(time.Now()) {
+		t.Fatalf("unexpected refresh: calls=%d from=%v to=%v", calls, gotFrom, gotTo)
+	}
+}
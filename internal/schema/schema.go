@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"demoserv/internal/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+//go:embed order.schema.json
+var orderSchema []byte
+
+const orderSchemaURL = "order.schema.json"
+
+var (
+	compileOnce sync.Once
+	compiled    *jsonschema.Schema
+	compileErr  error
+)
+
+// Order возвращает JSON Schema заказа в исходном виде
+func Order() []byte {
+	return orderSchema
+}
+
+// Validate проверяет JSON заказа по схеме
+func Validate(data []byte) error {
+	sch, err := orderSchemaCompiled()
+	if err != nil {
+		return err
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid json: %v", err)
+	}
+	if err := sch.Validate(inst); err != nil {
+		return fmt.Errorf("schema validation failed: %v", err)
+	}
+	return nil
+}
+
+// ValidateOrder проверяет по схеме уже декодированный заказ.
+// Так проверяются сообщения в Protobuf и Avro. JSON проверяется через Validate
+// до декодирования: в структуре уже не видно лишних полей, неверных типов
+// и пропущенных полей с нулевым значением
+func ValidateOrder(order models.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("marshal order: %v", err)
+	}
+	return Validate(data)
+}
+
+func orderSchemaCompiled() (*jsonschema.Schema, error) {
+	compileOnce.Do(func() {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(orderSchema))
+		if err != nil {
+			compileErr = fmt.Errorf("parse order schema: %v", err)
+			return
+		}
+
+		c := jsonschema.NewCompiler()
+		c.AssertFormat()
+		if err := c.AddResource(orderSchemaURL, doc); err != nil {
+			compileErr = fmt.Errorf("add order schema: %v", err)
+			return
+		}
+		compiled, compileErr = c.Compile(orderSchemaURL)
+	})
+	return compiled, compileErr
+}
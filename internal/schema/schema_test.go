@@ -0,0 +1,140 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"demoserv/internal/models"
+	"demoserv/internal/schema"
+)
+
+func readTestOrder(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../test.json")
+	if err != nil {
+		t.Fatalf("read test.json: %v", err)
+	}
+	return data
+}
+
+func TestValidate_TestJSON(t *testing.T) {
+	if err := schema.Validate(readTestOrder(t)); err != nil {
+		t.Fatalf("test.json must match schema: %v", err)
+	}
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	cases := map[string]func(o map[string]any){
+		"missing order_uid": func(o map[string]any) { delete(o, "order_uid") },
+		"wrong type":        func(o map[string]any) { o["sm_id"] = "98" },
+		"unknown field":     func(o map[string]any) { o["orderUid"] = "x" },
+		"empty items":       func(o map[string]any) { o["items"] = []any{} },
+		"bad date":          func(o map[string]any) { o["date_created"] = "yesterday" },
+		"zero amount": func(o map[string]any) {
+			o["payment"].(map[string]any)["amount"] = 0
+		},
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			var o map[string]any
+			if err := json.Unmarshal(readTestOrder(t), &o); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			mutate(o)
+			data, _ := json.Marshal(o)
+			if err := schema.Validate(data); err == nil {
+				t.Fatalf("expected schema error, got nil")
+			}
+		})
+	}
+}
+
+func TestValidateOrder_Decoded(t *testing.T) {
+	var order models.Order
+	if err := json.Unmarshal(readTestOrder(t), &order); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := schema.ValidateOrder(order); err != nil {
+		t.Fatalf("expected valid order, got %v", err)
+	}
+
+	order.Delivery.Phone = ""
+	if err := schema.ValidateOrder(order); err == nil {
+		t.Fatalf("expected error for empty delivery.phone, got nil")
+	}
+}
+
+// Лишние поля и неверные типы видны только в исходном JSON
+func TestValidate_RawCatchesWhatDecodedMisses(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(readTestOrder(t), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	doc["orderUid"] = "x"
+	raw, _ := json.Marshal(doc)
+
+	var order models.Order
+	if err := json.Unmarshal(raw, &order); err != nil {
+		t.Fatalf("unmarshal order: %v", err)
+	}
+	if err := schema.ValidateOrder(order); err != nil {
+		t.Fatalf("decoded order loses unknown fields, got %v", err)
+	}
+	if err := schema.Validate(raw); err == nil {
+		t.Fatal("expected raw JSON with unknown field to fail")
+	}
+}
+
+// Схема должна описывать ровно те поля, что есть в models.Order
+func TestSchema_InSyncWithModel(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(schema.Order(), &doc); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	defs := doc["$defs"].(map[string]any)
+
+	var check func(path string, typ reflect.Type, node map[string]any)
+	check = func(path string, typ reflect.Type, node map[string]any) {
+		if ref, ok := node["$ref"].(string); ok {
+			node = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		}
+		props := node["properties"].(map[string]any)
+
+		var modelFields []string
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			modelFields = append(modelFields, name)
+
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				t.Errorf("%s%s: field is missing in schema", path, name)
+				continue
+			}
+
+			switch ft := f.Type; {
+			case ft.Kind() == reflect.Struct && ft.String() != "time.Time":
+				check(path+name+".", ft, prop)
+			case ft.Kind() == reflect.Slice:
+				check(path+name+"[].", ft.Elem(), prop["items"].(map[string]any))
+			}
+		}
+
+		var schemaFields []string
+		for name := range props {
+			schemaFields = append(schemaFields, name)
+		}
+		sort.Strings(modelFields)
+		sort.Strings(schemaFields)
+		if !reflect.DeepEqual(modelFields, schemaFields) {
+			t.Errorf("%s: schema fields %v differ from model fields %v", path, schemaFields, modelFields)
+		}
+	}
+
+	check("", reflect.TypeOf(models.Order{}), doc)
+}
@@ -0,0 +1,320 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+)
+
+// Действия в журнале обработки персональных данных, как в privacy_audit
+const (
+	privacyExport    = "export"
+	privacyAnonymize = "anonymize"
+)
+
+type privacyEntry struct {
+	action     string
+	customerID string
+	actor      string
+	orderUIDs  []string
+}
+
+// manifest файл архива с заказами и их клиентами, как в archive_manifest
+type manifest struct {
+	models.ArchiveManifest
+	orderUIDs   []string
+	customerIDs []string
+}
+
+// ExportCustomer возвращает все заказы клиента от старых к новым и записывает выгрузку
+func (r *Repository) ExportCustomer(ctx context.Context, customerID, actor string) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := []models.Order{}
+	for _, o := range r.orders {
+		if o.CustomerID == customerID {
+			orders = append(orders, clone(o))
+		}
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.Before(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	r.privacy = append(r.privacy, privacyEntry{action: privacyExport, customerID: customerID, actor: actor, orderUIDs: uids})
+	return orders, nil
+}
+
+// AnonymizeCustomer затирает данные доставки клиента и записывает это в журнал
+func (r *Repository) AnonymizeCustomer(ctx context.Context, customerID, actor string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uids := []string{}
+	for uid, o := range r.orders {
+		if o.CustomerID == customerID {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+
+	var archived []string
+	for _, m := range r.manifests {
+		for i, uid := range m.orderUIDs {
+			if m.customerIDs[i] == customerID && !slices.Contains(uids, uid) && !slices.Contains(archived, uid) {
+				archived = append(archived, uid)
+			}
+		}
+	}
+	sort.Strings(archived)
+	uids = append(uids, archived...)
+	if len(uids) == 0 {
+		return uids, nil
+	}
+
+	for _, uid := range uids {
+		if o, ok := r.orders[uid]; ok {
+			o.Delivery = models.Delivery{}
+			r.orders[uid] = o
+		}
+	}
+	r.privacy = append(r.privacy, privacyEntry{action: privacyAnonymize, customerID: customerID, actor: actor, orderUIDs: uids})
+	return uids, nil
+}
+
+// anonymized обезличивали ли клиента. Вызывается под mu
+func (r *Repository) anonymized(customerID string) bool {
+	for _, e := range r.privacy {
+		if e.action == privacyAnonymize && e.customerID == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveOrders выгружает и удаляет пачку старых заказов. upload вызывается под блокировкой,
+// поэтому заказы не меняются, пока выгружаются
+func (r *Repository) ArchiveOrders(ctx context.Context, cutoff time.Time, limit int,
+	upload func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error),
+) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var candidates []models.Order
+	for uid, o := range r.orders {
+		if restoredAt, ok := r.restores[uid]; ok && !restoredAt.Before(cutoff) {
+			continue
+		}
+		if o.DateCreated.Before(cutoff) {
+			candidates = append(candidates, o)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].DateCreated.Equal(candidates[j].DateCreated) {
+			return candidates[i].DateCreated.Before(candidates[j].DateCreated)
+		}
+		return candidates[i].OrderUID < candidates[j].OrderUID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	orders := make([]models.ArchivedOrder, 0, len(candidates))
+	uids := make([]string, 0, len(candidates))
+	customers := make([]string, 0, len(candidates))
+	for _, o := range candidates {
+		orders = append(orders, models.ArchivedOrder{Order: clone(o)})
+		uids = append(uids, o.OrderUID)
+		customers = append(customers, o.CustomerID)
+	}
+
+	obj, err := upload(ctx, orders)
+	if err != nil {
+		return nil, fmt.Errorf("upload archive: %w", err)
+	}
+
+	first, last := candidates[0].DateCreated, candidates[len(candidates)-1].DateCreated
+	r.manifests = append(r.manifests, manifest{
+		ArchiveManifest: models.ArchiveManifest{
+			ID:             int64(len(r.manifests) + 1),
+			Key:            obj.Key,
+			Storage:        obj.Storage,
+			Location:       obj.Location,
+			Format:         obj.Format,
+			Status:         models.ArchiveStatusArchived,
+			Cutoff:         cutoff,
+			Orders:         len(orders),
+			MinDateCreated: &first,
+			MaxDateCreated: &last,
+			Size:           obj.Size,
+			SHA256:         obj.SHA256,
+			CreatedAt:      time.Now(),
+		},
+		orderUIDs:   uids,
+		customerIDs: customers,
+	})
+	for _, uid := range uids {
+		delete(r.restores, uid)
+		delete(r.orders, uid)
+	}
+	return uids, nil
+}
+
+// ArchiveManifest возвращает файл архива по id
+func (r *Repository) ArchiveManifest(ctx context.Context, id int64) (models.ArchiveManifest, error) {
+	if err := ctx.Err(); err != nil {
+		return models.ArchiveManifest{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, m := range r.manifests {
+		if m.ID == id {
+			return m.ArchiveManifest, nil
+		}
+	}
+	return models.ArchiveManifest{}, fmt.Errorf("get archive manifest %d: %w", id, storage.ErrManifestNotFound)
+}
+
+// FindArchiveManifests возвращает файлы архива с заказами uids, от новых к старым
+func (r *Repository) FindArchiveManifests(ctx context.Context, uids []string) ([]models.ArchiveManifest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	manifests := []models.ArchiveManifest{}
+	for i := len(r.manifests) - 1; i >= 0; i-- {
+		m := r.manifests[i]
+		if slices.ContainsFunc(m.orderUIDs, func(uid string) bool { return slices.Contains(uids, uid) }) {
+			manifests = append(manifests, m.ArchiveManifest)
+		}
+	}
+	return manifests, nil
+}
+
+// RestoreOrder возвращает заказ из архива. Шифрования в памяти нет,
+// поэтому заказ с зашифрованной доставкой не восстанавливается
+func (r *Repository) RestoreOrder(ctx context.Context, order models.ArchivedOrder, manifestID int64, actor string) (bool, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, false, err
+	}
+	if order.DeliveryKeyID != "" {
+		return false, false, fmt.Errorf("order %s: delivery is encrypted with data key %s", order.OrderUID, order.DeliveryKeyID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	anonymized := r.anonymized(order.CustomerID)
+	if anonymized {
+		order.Delivery = models.Delivery{}
+	}
+	if _, ok := r.orders[order.OrderUID]; ok {
+		return false, anonymized, nil
+	}
+	r.orders[order.OrderUID] = clone(order.Order)
+	r.restores[order.OrderUID] = time.Now()
+	return true, anonymized, nil
+}
+
+// UpdateArchiveStatus помечает restored файлы архива, все заказы которых снова в хранилище
+func (r *Repository) UpdateArchiveStatus(ctx context.Context, ids []int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.manifests {
+		m := &r.manifests[i]
+		if !slices.Contains(ids, m.ID) || m.Status == models.ArchiveStatusRestored {
+			continue
+		}
+		restored := true
+		for _, uid := range m.orderUIDs {
+			if _, ok := r.orders[uid]; !ok {
+				restored = false
+				break
+			}
+		}
+		if restored {
+			m.Status = models.ArchiveStatusRestored
+		}
+	}
+	return nil
+}
+
+// InsertAuditEntries записывает пачку записей журнала доступа, id выдаются по порядку
+func (r *Repository) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range entries {
+		e.ID = int64(len(r.audit) + 1)
+		r.audit = append(r.audit, e)
+	}
+	return nil
+}
+
+// QueryAudit возвращает записи журнала доступа по фильтру, от новых к старым
+func (r *Repository) QueryAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []models.AuditEntry
+	for _, e := range r.audit {
+		if (f.Principal != "" && e.Principal != f.Principal) ||
+			(f.OrderUID != "" && e.OrderUID != f.OrderUID) ||
+			(!f.From.IsZero() && e.Time.Before(f.From)) ||
+			(!f.To.IsZero() && !e.Time.Before(f.To)) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].Time.Equal(matched[j].Time) {
+			return matched[i].Time.After(matched[j].Time)
+		}
+		return matched[i].ID > matched[j].ID
+	})
+
+	entries := []models.AuditEntry{}
+	if f.Offset < len(matched) {
+		entries = append(entries, matched[f.Offset:min(f.Offset+f.Limit, len(matched))]...)
+	}
+	return entries, len(matched), nil
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"demoserv/internal/models"
+)
+
+// analyticsKey строка отчета до агрегации: интервал, значение разреза и валюта
+type analyticsKey struct {
+	bucket   time.Time
+	key      string
+	currency string
+}
+
+// Analytics считает отчет перебором заказов. Сводных таблиц нет, q.Rollups не учитывается:
+// отчет по ним совпадает с отчетом по сырым данным
+func (r *Repository) Analytics(ctx context.Context, q models.AnalyticsQuery) ([]models.AnalyticsRow, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch q.Report {
+	case models.ReportSales, models.ReportBrands, models.ReportDeliveryServices, models.ReportBanks, models.ReportProviders:
+	default:
+		return nil, fmt.Errorf("unknown report %q", q.Report)
+	}
+	switch q.Bucket {
+	case models.BucketHour, models.BucketDay, models.BucketWeek:
+	default:
+		return nil, fmt.Errorf("unknown bucket %q", q.Bucket)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rows := make(map[analyticsKey]*models.AnalyticsRow)
+	add := func(k analyticsKey, orders, items, revenue int64) {
+		row, ok := rows[k]
+		if !ok {
+			row = &models.AnalyticsRow{Bucket: k.bucket, Key: k.key, Currency: k.currency}
+			rows[k] = row
+		}
+		row.Orders += orders
+		row.Items += items
+		row.Revenue += revenue
+	}
+	for _, o := range r.orders {
+		if o.DateCreated.Before(q.From) || !o.DateCreated.Before(q.To) {
+			continue
+		}
+		if q.Currency != "" && o.Payment.Currency != q.Currency {
+			continue
+		}
+		k := analyticsKey{bucket: truncate(o.DateCreated, q.Bucket), currency: o.Payment.Currency}
+		switch q.Report {
+		case models.ReportBrands:
+			// выручка бренда — сумма total_price его товаров, заказ считается в каждом своем бренде один раз
+			brands := make(map[string]bool)
+			for _, item := range o.Items {
+				k.key = item.Brand
+				orders := int64(0)
+				if !brands[item.Brand] {
+					brands[item.Brand] = true
+					orders = 1
+				}
+				add(k, orders, 1, int64(item.TotalPrice))
+			}
+			continue
+		case models.ReportDeliveryServices:
+			k.key = o.DeliveryService
+		case models.ReportBanks:
+			k.key = o.Payment.Bank
+		case models.ReportProviders:
+			k.key = o.Payment.Provider
+		}
+		add(k, 1, int64(len(o.Items)), int64(o.Payment.Amount))
+	}
+
+	result := make([]models.AnalyticsRow, 0, len(rows))
+	for _, row := range rows {
+		if row.Orders > 0 {
+			row.AvgBasket = math.Round(float64(row.Revenue)/float64(row.Orders)*100) / 100
+		}
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.Bucket.Equal(b.Bucket) {
+			return a.Bucket.Before(b.Bucket)
+		}
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.Key < b.Key
+	})
+	if q.Top == 0 {
+		return result, nil
+	}
+
+	// первые q.Top строк в каждой паре интервала и валюты
+	top := []models.AnalyticsRow{}
+	n := 0
+	for i, row := range result {
+		if i > 0 && (!row.Bucket.Equal(result[i-1].Bucket) || row.Currency != result[i-1].Currency) {
+			n = 0
+		}
+		if n++; n <= q.Top {
+			top = append(top, row)
+		}
+	}
+	return top, nil
+}
+
+// truncate начало интервала, как date_trunc в Postgres: неделя начинается в понедельник
+func truncate(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case models.BucketHour:
+		return t.Truncate(time.Hour)
+	case models.BucketWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return day
+	}
+}
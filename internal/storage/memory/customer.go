@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"demoserv/internal/models"
+)
+
+// customerOrders заказы клиента от новых к старым, при равном date_created — по order_uid. Вызывается под mu
+func (r *Repository) customerOrders(customerID string) []models.Order {
+	var orders []models.Order
+	for _, o := range r.orders {
+		if o.CustomerID == customerID {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+	return orders
+}
+
+// CustomerStats считает сводку по заказам клиента
+func (r *Repository) CustomerStats(ctx context.Context, customerID string) (models.CustomerStats, error) {
+	stats := models.CustomerStats{TotalSpent: map[string]int64{}}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := r.customerOrders(customerID)
+	if len(orders) == 0 {
+		return stats, nil
+	}
+	stats.TotalOrders = len(orders)
+	last, first := orders[0].DateCreated, orders[len(orders)-1].DateCreated
+	stats.FirstOrderAt, stats.LastOrderAt = &first, &last
+
+	services := make(map[string]int)
+	for _, o := range orders {
+		stats.TotalSpent[o.Payment.Currency] += int64(o.Payment.Amount)
+		if o.DeliveryService != "" {
+			services[o.DeliveryService]++
+		}
+	}
+	// самая частая служба доставки, при равенстве — первая по алфавиту
+	for service, n := range services {
+		top := services[stats.TopDeliveryService]
+		if n > top || n == top && service < stats.TopDeliveryService {
+			stats.TopDeliveryService = service
+		}
+	}
+	return stats, nil
+}
+
+// CustomerOrders возвращает страницу заказов клиента от новых к старым
+func (r *Repository) CustomerOrders(ctx context.Context, customerID string, limit, offset int) ([]models.CustomerOrder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := r.customerOrders(customerID)
+	page := []models.CustomerOrder{}
+	for _, o := range orders[min(offset, len(orders)):min(offset+limit, len(orders))] {
+		page = append(page, models.CustomerOrder{
+			OrderUID:        o.OrderUID,
+			TrackNumber:     o.TrackNumber,
+			DateCreated:     o.DateCreated,
+			DeliveryService: o.DeliveryService,
+			Amount:          o.Payment.Amount,
+			Currency:        o.Payment.Currency,
+			Items:           len(o.Items),
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+)
+
+// Repository хранит заказы в памяти процесса
+type Repository struct {
+	mu     sync.RWMutex
+	orders map[string]models.Order
+
+	// данные админских маршрутов, см. admin.go
+	privacy   []privacyEntry
+	manifests []manifest           // по возрастанию id
+	restores  map[string]time.Time // order_uid -> когда восстановлен из архива
+	audit     []models.AuditEntry
+}
+
+var _ storage.Repository = (*Repository)(nil)
+
+// New создает пустое хранилище
+func New() *Repository {
+	return &Repository{
+		orders:   make(map[string]models.Order),
+		restores: make(map[string]time.Time),
+	}
+}
+
+// InsertOrder сохраняет копию заказа. Как и в Postgres, существующий заказ не перезаписывается
+func (r *Repository) InsertOrder(ctx context.Context, order *models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[order.OrderUID]; ok {
+		return nil
+	}
+	r.orders[order.OrderUID] = clone(*order)
+	return nil
+}
+
+// GetOrder возвращает копию заказа
+func (r *Repository) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	order, ok := r.orders[orderUID]
+	if !ok {
+		return models.Order{}, fmt.Errorf("get order %s: %w", orderUID, storage.ErrOrderNotFound)
+	}
+	return clone(order), nil
+}
+
+// GetOrderByTrackNumber возвращает самый новый заказ с трек-номером
+func (r *Repository) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (models.Order, error) {
+	return r.find(ctx, "track number "+trackNumber, func(o models.Order) bool {
+		return o.TrackNumber == trackNumber
+	})
+}
+
+// GetOrderByTransaction возвращает самый новый заказ с транзакцией оплаты
+func (r *Repository) GetOrderByTransaction(ctx context.Context, transaction string) (models.Order, error) {
+	return r.find(ctx, "transaction "+transaction, func(o models.Order) bool {
+		return o.Payment.Transaction == transaction
+	})
+}
+
+// find возвращает самый новый подходящий заказ, при равном date_created — с меньшим order_uid, как в Postgres
+func (r *Repository) find(ctx context.Context, key string, match func(models.Order) bool) (models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *models.Order
+	for _, o := range r.orders {
+		if !match(o) {
+			continue
+		}
+		if found == nil || o.DateCreated.After(found.DateCreated) ||
+			(o.DateCreated.Equal(found.DateCreated) && o.OrderUID < found.OrderUID) {
+			found = &o
+		}
+	}
+	if found == nil {
+		return models.Order{}, fmt.Errorf("get order by %s: %w", key, storage.ErrOrderNotFound)
+	}
+	return clone(*found), nil
+}
+
+// GetLastOrders возвращает limit последних заказов по date_created
+func (r *Repository) GetLastOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := make([]models.Order, 0, len(r.orders))
+	for _, o := range r.orders {
+		orders = append(orders, clone(o))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DateCreated.After(orders[j].DateCreated)
+	})
+	if limit >= 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// clone копирует заказ, чтобы вызывающий не менял хранимые данные
+func clone(o models.Order) models.Order {
+	o.Items = slices.Clone(o.Items)
+	return o
+}
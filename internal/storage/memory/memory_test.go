@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"demoserv/internal/storage"
+	"demoserv/internal/storage/memory"
+	"demoserv/internal/storage/storagetest"
+)
+
+func TestRepository(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Repository {
+		return memory.New()
+	})
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+)
+
+// Веса совпадения в поле: примерно как ts_rank одного слова с весами A, B и C в Postgres
+const (
+	rankTrackNumber = 0.6
+	rankItem        = 0.24
+	rankName        = 0.12
+)
+
+// maxSnippets фрагментов на заказ
+const maxSnippets = 3
+
+type match struct {
+	field string
+	text  string
+	rank  float64
+}
+
+// SearchOrders ищет заказы перебором. Ранг заказа — сумма весов совпавших полей
+func (r *Repository) SearchOrders(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	terms, err := storage.SearchTerms(q.Text)
+	if err != nil {
+		return nil, 0, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := []models.SearchResult{}
+	for _, o := range r.orders {
+		var matches []match
+		add := func(field, text string, rank float64) {
+			if matchAll(storage.Words(text), terms, q.Prefix) {
+				matches = append(matches, match{field: field, text: text, rank: rank})
+			}
+		}
+		add("track_number", o.TrackNumber, rankTrackNumber)
+		for _, item := range o.Items {
+			add("items", strings.TrimSpace(item.Name+" "+item.Brand), rankItem)
+		}
+		add("delivery.name", o.Delivery.Name, rankName)
+		if len(matches) == 0 {
+			continue
+		}
+
+		res := models.SearchResult{
+			OrderUID:    o.OrderUID,
+			TrackNumber: o.TrackNumber,
+			CustomerID:  o.CustomerID,
+			DateCreated: o.DateCreated,
+		}
+		for _, m := range matches {
+			res.Rank += m.rank
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].rank != matches[j].rank {
+				return matches[i].rank > matches[j].rank
+			}
+			return matches[i].field < matches[j].field
+		})
+		for _, m := range matches[:min(len(matches), maxSnippets)] {
+			res.Snippets = append(res.Snippets, models.SearchSnippet{
+				Field: m.field,
+				Text:  storage.Highlight(m.text, terms, q.Prefix),
+			})
+		}
+		results = append(results, res)
+	}
+
+	// как в Postgres: по рангу, затем от новых к старым
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.DateCreated.Equal(b.DateCreated) {
+			return a.DateCreated.After(b.DateCreated)
+		}
+		return a.OrderUID < b.OrderUID
+	})
+	total := len(results)
+	return results[min(q.Offset, total):min(q.Offset+q.Limit, total)], total, nil
+}
+
+// matchAll есть ли каждое слово запроса среди слов поля
+func matchAll(words, terms []string, prefix bool) bool {
+	for i, term := range terms {
+		last := prefix && i == len(terms)-1
+		found := false
+		for _, w := range words {
+			if w == term || last && strings.HasPrefix(w, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms больше слов в запросе не учитывается
+const maxSearchTerms = 10
+
+// ErrEmptySearch в запросе нет ни одного слова
+var ErrEmptySearch = errors.New("search query has no words")
+
+// Words слова текста в нижнем регистре: последовательности букв и цифр.
+// Так делятся на слова и запрос, и поля, по которым ищут
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchTerms слова поискового запроса, не больше maxSearchTerms.
+// Запрос без слов — ошибка ErrEmptySearch
+func SearchTerms(text string) ([]string, error) {
+	terms := Words(text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	return terms[:min(len(terms), maxSearchTerms)], nil
+}
+
+// Highlight выделяет в тексте слова из terms в <mark></mark>, как фрагменты Postgres.
+// При prefix последнее слово запроса совпадает с началом слова текста
+func Highlight(text string, terms []string, prefix bool) string {
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if w := text[start:end]; matchWord(strings.ToLower(w), terms, prefix) {
+			b.WriteString("<mark>" + w + "</mark>")
+		} else {
+			b.WriteString(w)
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		b.WriteRune(r)
+	}
+	flush(len(text))
+	return b.String()
+}
+
+// matchWord совпадает ли слово в нижнем регистре с одним из слов запроса
+func matchWord(word string, terms []string, prefix bool) bool {
+	if slices.Contains(terms, word) {
+		return true
+	}
+	return prefix && len(terms) > 0 && strings.HasPrefix(word, terms[len(terms)-1])
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"demoserv/internal/models"
+)
+
+var (
+	// ErrOrderNotFound заказа с таким order_uid нет
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrManifestNotFound файла архива с таким id нет
+	ErrManifestNotFound = errors.New("archive manifest not found")
+	// ErrRejected заказ не записать из-за самих данных: повторная запись снова не удастся
+	ErrRejected = errors.New("order rejected by storage")
+)
+
+// Repository все данные сервиса: заказы и данные админских маршрутов
+type Repository interface {
+	OrderRepository
+	AdminRepository
+}
+
+// OrderRepository хранилище заказов. Реализации: postgress.Repository
+// и memory.Repository для тестов. Поведение обеих проверяет storagetest.Run
+type OrderRepository interface {
+	// InsertOrder сохраняет заказ. Повторная вставка того же order_uid ничего не меняет.
+	// Если заказ не записать из-за самих данных, ошибка содержит ErrRejected
+	InsertOrder(ctx context.Context, order *models.Order) error
+	// GetOrder возвращает заказ или ошибку с ErrOrderNotFound
+	GetOrder(ctx context.Context, orderUID string) (models.Order, error)
+	// GetOrderByTrackNumber возвращает самый новый заказ с трек-номером или ошибку с ErrOrderNotFound
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) (models.Order, error)
+	// GetOrderByTransaction возвращает самый новый заказ с транзакцией оплаты или ошибку с ErrOrderNotFound
+	GetOrderByTransaction(ctx context.Context, transaction string) (models.Order, error)
+	// GetLastOrders возвращает limit последних заказов, от новых к старым по date_created
+	GetLastOrders(ctx context.Context, limit int) ([]models.Order, error)
+	// SearchOrders ищет заказы, у которых все слова запроса есть в одном поле: трек-номере,
+	// товаре (название и бренд) или имени получателя. Возвращает страницу от самых релевантных
+	// и общее число найденных заказов, для запроса без слов — ошибку ErrEmptySearch
+	SearchOrders(ctx context.Context, q models.SearchQuery) ([]models.SearchResult, int, error)
+	// CustomerStats возвращает сводку по всем заказам клиента. У клиента без заказов TotalOrders = 0
+	CustomerStats(ctx context.Context, customerID string) (models.CustomerStats, error)
+	// CustomerOrders возвращает страницу заказов клиента от новых к старым по date_created
+	CustomerOrders(ctx context.Context, customerID string, limit, offset int) ([]models.CustomerOrder, error)
+	// Analytics считает отчет q.Report по интервалам и валютам. Внутри интервала и валюты
+	// строки идут по убыванию выручки, затем по значению разреза
+	Analytics(ctx context.Context, q models.AnalyticsQuery) ([]models.AnalyticsRow, error)
+}
+
+// AdminRepository данные админских маршрутов: персональные данные клиентов, архив
+// и журнал доступа. Реализации и проверки те же, что у OrderRepository
+type AdminRepository interface {
+	// ExportCustomer возвращает все заказы клиента от старых к новым без маскирования
+	// и записывает выгрузку от имени actor в журнал обработки персональных данных.
+	// Для клиента без заказов ничего не записывается
+	ExportCustomer(ctx context.Context, customerID, actor string) ([]models.Order, error)
+	// AnonymizeCustomer затирает данные доставки во всех заказах клиента и записывает это
+	// в журнал обработки персональных данных. Заказы клиента в архиве тоже считаются затронутыми:
+	// их данные затираются при восстановлении. Возвращает uid затронутых заказов
+	AnonymizeCustomer(ctx context.Context, customerID, actor string) ([]string, error)
+
+	// ArchiveOrders передает в upload до limit заказов с date_created раньше cutoff, от старых к новым,
+	// кроме восстановленных из архива позже cutoff, и после успешной загрузки удаляет их,
+	// записывая файл архива. Если upload вернул ошибку, заказы остаются. Возвращает uid удаленных заказов
+	ArchiveOrders(ctx context.Context, cutoff time.Time, limit int,
+		upload func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error)) ([]string, error)
+	// ArchiveManifest возвращает файл архива или ошибку с ErrManifestNotFound
+	ArchiveManifest(ctx context.Context, id int64) (models.ArchiveManifest, error)
+	// FindArchiveManifests возвращает файлы архива хотя бы с одним из заказов uids, от новых к старым
+	FindArchiveManifests(ctx context.Context, uids []string) ([]models.ArchiveManifest, error)
+	// RestoreOrder возвращает заказ из файла архива manifestID. Заказ, который уже есть,
+	// пропускается (restored=false). Если клиента обезличили, данные доставки затираются снова
+	// (anonymized=true)
+	RestoreOrder(ctx context.Context, order models.ArchivedOrder, manifestID int64, actor string) (restored, anonymized bool, err error)
+	// UpdateArchiveStatus помечает restored файлы архива из ids, все заказы которых снова в хранилище
+	UpdateArchiveStatus(ctx context.Context, ids []int64) error
+
+	// InsertAuditEntries записывает пачку записей журнала доступа
+	InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error
+	// QueryAudit возвращает страницу записей журнала доступа по фильтру, от новых к старым,
+	// и общее число подходящих записей
+	QueryAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error)
+}
@@ -0,0 +1,591 @@
+// Package storagetest общий набор проверок для реализаций storage.Repository
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"demoserv/internal/models"
+	"demoserv/internal/storage"
+)
+
+// Run проверяет, что реализация ведет себя так же, как остальные.
+// newRepo вызывается в каждом подтесте и должен возвращать пустое хранилище
+func Run(t *testing.T, newRepo func(t *testing.T) storage.Repository) {
+	t.Run("InsertAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := Order("st-1", time.Now().Add(-time.Hour))
+		if err := repo.InsertOrder(ctx, want); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		got, err := repo.GetOrder(ctx, want.OrderUID)
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		assertOrder(t, got, *want)
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.GetOrder(context.Background(), "st-missing")
+		if !errors.Is(err, storage.ErrOrderNotFound) {
+			t.Fatalf("expected ErrOrderNotFound, got %v", err)
+		}
+	})
+
+	t.Run("InsertDuplicateKeepsFirst", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		first := Order("st-dup", time.Now().Add(-time.Hour))
+		if err := repo.InsertOrder(ctx, first); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		second := Order("st-dup", time.Now())
+		second.TrackNumber = "CHANGED"
+		if err := repo.InsertOrder(ctx, second); err != nil {
+			t.Fatalf("repeated InsertOrder: %v", err)
+		}
+		got, err := repo.GetOrder(ctx, "st-dup")
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		assertOrder(t, got, *first)
+	})
+
+	t.Run("GetLastOrders", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		now := time.Now()
+		for i, uid := range []string{"st-b", "st-c", "st-a"} {
+			if err := repo.InsertOrder(ctx, Order(uid, now.Add(time.Duration(i-3)*time.Hour))); err != nil {
+				t.Fatalf("InsertOrder %s: %v", uid, err)
+			}
+		}
+
+		got, err := repo.GetLastOrders(ctx, 2)
+		if err != nil {
+			t.Fatalf("GetLastOrders: %v", err)
+		}
+		if len(got) != 2 || got[0].OrderUID != "st-a" || got[1].OrderUID != "st-c" {
+			t.Fatalf("expected [st-a st-c], got %v", uids(got))
+		}
+
+		all, err := repo.GetLastOrders(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetLastOrders: %v", err)
+		}
+		if len(all) != 3 {
+			t.Fatalf("expected 3 orders, got %v", uids(all))
+		}
+	})
+
+	t.Run("GetByTrackNumberAndTransaction", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		now := time.Now()
+		older := Order("st-older", now.Add(-2*time.Hour))
+		newer := Order("st-newer", now.Add(-time.Hour))
+		other := Order("st-other", now)
+		older.TrackNumber, newer.TrackNumber = "TRACK-1", "TRACK-1"
+		older.Payment.Transaction, newer.Payment.Transaction = "tx-1", "tx-1"
+		for _, o := range []*models.Order{older, newer, other} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+
+		got, err := repo.GetOrderByTrackNumber(ctx, "TRACK-1")
+		if err != nil {
+			t.Fatalf("GetOrderByTrackNumber: %v", err)
+		}
+		assertOrder(t, got, *newer)
+		got, err = repo.GetOrderByTransaction(ctx, "tx-1")
+		if err != nil {
+			t.Fatalf("GetOrderByTransaction: %v", err)
+		}
+		assertOrder(t, got, *newer)
+		got, err = repo.GetOrderByTransaction(ctx, other.Payment.Transaction)
+		if err != nil {
+			t.Fatalf("GetOrderByTransaction: %v", err)
+		}
+		assertOrder(t, got, *other)
+
+		if _, err := repo.GetOrderByTrackNumber(ctx, "TRACK-missing"); !errors.Is(err, storage.ErrOrderNotFound) {
+			t.Fatalf("expected ErrOrderNotFound by track number, got %v", err)
+		}
+		if _, err := repo.GetOrderByTransaction(ctx, "tx-missing"); !errors.Is(err, storage.ErrOrderNotFound) {
+			t.Fatalf("expected ErrOrderNotFound by transaction, got %v", err)
+		}
+	})
+
+	t.Run("SearchOrders", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		now := time.Now()
+		shoes := Order("st-shoes", now.Add(-time.Hour))
+		shoes.Items[0].Name, shoes.Items[0].Brand = "Air Max", "Nike"
+		shoes.Delivery.Name = "Ivan Petrov"
+		bag := Order("st-bag", now)
+		bag.Items[0].Name, bag.Items[0].Brand = "Sport bag", "Nike"
+		for _, o := range []*models.Order{shoes, bag, Order("st-other", now)} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+
+		// при равном ранге новые заказы выше
+		got, total, err := repo.SearchOrders(ctx, models.SearchQuery{Text: "NIKE", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchOrders: %v", err)
+		}
+		if total != 2 || len(got) != 2 || got[0].OrderUID != "st-bag" || got[1].OrderUID != "st-shoes" {
+			t.Fatalf("expected [st-bag st-shoes] of 2, got %d: %+v", total, got)
+		}
+		if len(got[0].Snippets) != 1 || got[0].Snippets[0].Field != "items" || !strings.Contains(got[0].Snippets[0].Text, "<mark>Nike</mark>") {
+			t.Fatalf("expected highlighted items snippet, got %+v", got[0].Snippets)
+		}
+
+		page, total, err := repo.SearchOrders(ctx, models.SearchQuery{Text: "nike", Limit: 1, Offset: 1})
+		if err != nil || total != 2 || len(page) != 1 || page[0].OrderUID != "st-shoes" {
+			t.Fatalf("expected second page [st-shoes] of 2, got %d: %+v, %v", total, page, err)
+		}
+
+		got, _, err = repo.SearchOrders(ctx, models.SearchQuery{Text: "air ma", Prefix: true, Limit: 10})
+		if err != nil || len(got) != 1 || got[0].OrderUID != "st-shoes" {
+			t.Fatalf("expected st-shoes by prefix, got %+v, %v", got, err)
+		}
+		got, _, err = repo.SearchOrders(ctx, models.SearchQuery{Text: "air ma", Limit: 10})
+		if err != nil || len(got) != 0 {
+			t.Fatalf("expected no results without prefix, got %+v, %v", got, err)
+		}
+
+		got, _, err = repo.SearchOrders(ctx, models.SearchQuery{Text: "petrov", Limit: 10})
+		if err != nil || len(got) != 1 || got[0].Snippets[0].Field != "delivery.name" {
+			t.Fatalf("expected st-shoes by delivery name, got %+v, %v", got, err)
+		}
+
+		// все слова должны найтись в одном поле
+		got, _, err = repo.SearchOrders(ctx, models.SearchQuery{Text: "nike petrov", Limit: 10})
+		if err != nil || len(got) != 0 {
+			t.Fatalf("expected no results for words from different fields, got %+v, %v", got, err)
+		}
+
+		if _, _, err := repo.SearchOrders(ctx, models.SearchQuery{Text: " -!- ", Limit: 10}); !errors.Is(err, storage.ErrEmptySearch) {
+			t.Fatalf("expected ErrEmptySearch, got %v", err)
+		}
+	})
+
+	t.Run("CustomerHistory", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		o1 := Order("st-h-1", base)
+		o2 := Order("st-h-2", base.Add(24*time.Hour))
+		o2.Payment.Amount = 15
+		o3 := Order("st-h-3", base.Add(48*time.Hour))
+		o3.Payment.Currency = "EUR"
+		o3.Payment.Amount = 10
+		o3.DeliveryService = "dhl"
+		other := Order("st-h-other", base)
+		other.CustomerID = "someone-else"
+		for _, o := range []*models.Order{o1, o2, o3, other} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+
+		stats, err := repo.CustomerStats(ctx, "cust")
+		if err != nil {
+			t.Fatalf("CustomerStats: %v", err)
+		}
+		want := map[string]int64{"USD": 1817 + 15, "EUR": 10}
+		if stats.TotalOrders != 3 || !reflect.DeepEqual(stats.TotalSpent, want) || stats.TopDeliveryService != "meest" {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+		if stats.FirstOrderAt == nil || !stats.FirstOrderAt.Equal(base) ||
+			stats.LastOrderAt == nil || !stats.LastOrderAt.Equal(base.Add(48*time.Hour)) {
+			t.Fatalf("unexpected order dates: %v, %v", stats.FirstOrderAt, stats.LastOrderAt)
+		}
+
+		page, err := repo.CustomerOrders(ctx, "cust", 2, 0)
+		if err != nil {
+			t.Fatalf("CustomerOrders: %v", err)
+		}
+		if len(page) != 2 || page[0].OrderUID != "st-h-3" || page[1].OrderUID != "st-h-2" {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+		if p := page[0]; p.Currency != "EUR" || p.Amount != 10 || p.Items != 2 || p.DeliveryService != "dhl" || !p.DateCreated.Equal(o3.DateCreated) {
+			t.Fatalf("unexpected customer order: %+v", p)
+		}
+		page, err = repo.CustomerOrders(ctx, "cust", 2, 2)
+		if err != nil || len(page) != 1 || page[0].OrderUID != "st-h-1" {
+			t.Fatalf("unexpected last page: %+v, %v", page, err)
+		}
+
+		empty, err := repo.CustomerStats(ctx, "nobody")
+		if err != nil {
+			t.Fatalf("CustomerStats for unknown customer: %v", err)
+		}
+		if empty.TotalOrders != 0 || empty.FirstOrderAt != nil || len(empty.TotalSpent) != 0 {
+			t.Fatalf("expected empty stats, got %+v", empty)
+		}
+		if page, err := repo.CustomerOrders(ctx, "nobody", 10, 0); err != nil || page == nil || len(page) != 0 {
+			t.Fatalf("expected empty page, got %+v, %v", page, err)
+		}
+	})
+
+	t.Run("Analytics", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		day := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC) // среда
+		o1 := Order("st-a-1", day.Add(time.Hour))
+		o1.Payment.Amount = 30
+		o2 := Order("st-a-2", day.Add(2*time.Hour))
+		o2.Payment.Amount = 50
+		o2.DeliveryService = "dhl"
+		o3 := Order("st-a-3", day.Add(26*time.Hour))
+		o3.Payment.Amount = 10
+		o4 := Order("st-a-4", day.Add(3*time.Hour))
+		o4.Payment.Currency = "EUR"
+		o4.Payment.Amount = 10
+		for _, o := range []*models.Order{o1, o2, o3, o4} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+
+		q := models.AnalyticsQuery{
+			Report:   models.ReportSales,
+			Bucket:   models.BucketDay,
+			From:     day,
+			To:       day.Add(48 * time.Hour),
+			Currency: "USD",
+		}
+		rows, err := repo.Analytics(ctx, q)
+		if err != nil {
+			t.Fatalf("sales: %v", err)
+		}
+		if len(rows) != 2 || !rows[0].Bucket.Equal(day) || rows[0].Orders != 2 || rows[0].Items != 4 ||
+			rows[0].Revenue != 80 || rows[0].AvgBasket != 40 || !rows[1].Bucket.Equal(day.Add(24*time.Hour)) || rows[1].Orders != 1 {
+			t.Fatalf("unexpected sales: %+v", rows)
+		}
+
+		// неделя начинается в понедельник
+		q.Bucket = models.BucketWeek
+		rows, err = repo.Analytics(ctx, q)
+		if err != nil {
+			t.Fatalf("weekly sales: %v", err)
+		}
+		if len(rows) != 1 || !rows[0].Bucket.Equal(day.AddDate(0, 0, -2)) || rows[0].Orders != 3 || rows[0].Revenue != 90 {
+			t.Fatalf("unexpected weekly sales: %+v", rows)
+		}
+
+		q.Report = models.ReportBrands
+		q.Bucket = models.BucketDay
+		q.To = day.Add(24 * time.Hour)
+		q.Top = 1
+		rows, err = repo.Analytics(ctx, q)
+		if err != nil {
+			t.Fatalf("brands: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Key != "b" || rows[0].Revenue != 2*317 || rows[0].Orders != 2 || rows[0].Items != 2 {
+			t.Fatalf("unexpected brands: %+v", rows)
+		}
+
+		q.Report = models.ReportDeliveryServices
+		q.Currency = ""
+		q.Top = 0
+		rows, err = repo.Analytics(ctx, q)
+		if err != nil {
+			t.Fatalf("delivery services: %v", err)
+		}
+		if len(rows) != 3 || rows[0].Currency != "EUR" || rows[1].Key != "dhl" || rows[2].Key != "meest" || rows[2].Revenue != 30 {
+			t.Fatalf("unexpected delivery services: %+v", rows)
+		}
+
+		if _, err := repo.Analytics(ctx, models.AnalyticsQuery{Report: "unknown", Bucket: models.BucketDay}); err == nil {
+			t.Fatal("expected error for unknown report")
+		}
+	})
+
+	t.Run("ExportAndAnonymizeCustomer", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		now := time.Now()
+		older, newer, other := Order("st-p-1", now.Add(-2*time.Hour)), Order("st-p-2", now.Add(-time.Hour)), Order("st-p-3", now)
+		older.CustomerID, newer.CustomerID, other.CustomerID = "st-p", "st-p", "st-other"
+		for _, o := range []*models.Order{newer, older, other} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+
+		orders, err := repo.ExportCustomer(ctx, "st-p", "admin")
+		if err != nil {
+			t.Fatalf("ExportCustomer: %v", err)
+		}
+		if got := uids(orders); !reflect.DeepEqual(got, []string{"st-p-1", "st-p-2"}) {
+			t.Fatalf("expected orders from oldest to newest, got %v", got)
+		}
+		assertOrder(t, orders[0], *older)
+		if orders, err := repo.ExportCustomer(ctx, "st-nobody", "admin"); err != nil || len(orders) != 0 {
+			t.Fatalf("unknown customer: %v, %v", uids(orders), err)
+		}
+
+		anonymized, err := repo.AnonymizeCustomer(ctx, "st-p", "admin")
+		if err != nil {
+			t.Fatalf("AnonymizeCustomer: %v", err)
+		}
+		if !reflect.DeepEqual(anonymized, []string{"st-p-1", "st-p-2"}) {
+			t.Fatalf("unexpected anonymized orders %v", anonymized)
+		}
+		got, err := repo.GetOrder(ctx, "st-p-1")
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		if got.Delivery != (models.Delivery{}) || got.Payment != older.Payment || len(got.Items) != 2 {
+			t.Fatalf("only delivery must be erased: %+v", got)
+		}
+		if got, err := repo.GetOrder(ctx, "st-p-3"); err != nil || got.Delivery != other.Delivery {
+			t.Fatalf("other customer must not change: %+v, %v", got.Delivery, err)
+		}
+		if uids, err := repo.AnonymizeCustomer(ctx, "st-nobody", "admin"); err != nil || len(uids) != 0 {
+			t.Fatalf("unknown customer: %v, %v", uids, err)
+		}
+	})
+
+	t.Run("ArchiveAndRestore", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		now := time.Now()
+		first, second, recent := Order("st-ar-1", now.Add(-72*time.Hour)), Order("st-ar-2", now.Add(-48*time.Hour)), Order("st-ar-3", now)
+		second.CustomerID = "st-ar"
+		for _, o := range []*models.Order{second, recent, first} {
+			if err := repo.InsertOrder(ctx, o); err != nil {
+				t.Fatalf("InsertOrder %s: %v", o.OrderUID, err)
+			}
+		}
+		cutoff := now.Add(-24 * time.Hour)
+
+		failed := errors.New("storage is down")
+		if _, err := repo.ArchiveOrders(ctx, cutoff, 10, func(context.Context, []models.ArchivedOrder) (models.ArchiveObject, error) {
+			return models.ArchiveObject{}, failed
+		}); !errors.Is(err, failed) {
+			t.Fatalf("expected upload error, got %v", err)
+		}
+		if _, err := repo.GetOrder(ctx, "st-ar-1"); err != nil {
+			t.Fatalf("orders must stay after failed upload: %v", err)
+		}
+
+		var uploaded []models.ArchivedOrder
+		upload := func(_ context.Context, orders []models.ArchivedOrder) (models.ArchiveObject, error) {
+			uploaded = orders
+			return models.ArchiveObject{Key: "st/archive.ndjson.gz", Storage: "local", Location: "st", Format: "ndjson", Size: 1, SHA256: "sum"}, nil
+		}
+		archived, err := repo.ArchiveOrders(ctx, cutoff, 10, upload)
+		if err != nil {
+			t.Fatalf("ArchiveOrders: %v", err)
+		}
+		if !reflect.DeepEqual(archived, []string{"st-ar-1", "st-ar-2"}) || len(uploaded) != 2 {
+			t.Fatalf("expected old orders from oldest to newest, got %v", archived)
+		}
+		assertOrder(t, uploaded[0].Order, *first)
+		if _, err := repo.GetOrder(ctx, "st-ar-1"); !errors.Is(err, storage.ErrOrderNotFound) {
+			t.Fatalf("archived order must be deleted, got %v", err)
+		}
+		if _, err := repo.GetOrder(ctx, "st-ar-3"); err != nil {
+			t.Fatalf("recent order must stay: %v", err)
+		}
+
+		manifests, err := repo.FindArchiveManifests(ctx, []string{"st-ar-2", "st-missing"})
+		if err != nil {
+			t.Fatalf("FindArchiveManifests: %v", err)
+		}
+		if len(manifests) != 1 || manifests[0].Status != models.ArchiveStatusArchived || manifests[0].Orders != 2 ||
+			manifests[0].Key != "st/archive.ndjson.gz" || !manifests[0].MinDateCreated.Equal(first.DateCreated) {
+			t.Fatalf("unexpected manifests %+v", manifests)
+		}
+		id := manifests[0].ID
+		if m, err := repo.ArchiveManifest(ctx, id); err != nil || m.Key != manifests[0].Key {
+			t.Fatalf("ArchiveManifest: %+v, %v", m, err)
+		}
+		if _, err := repo.ArchiveManifest(ctx, id+1000); !errors.Is(err, storage.ErrManifestNotFound) {
+			t.Fatalf("expected ErrManifestNotFound, got %v", err)
+		}
+
+		// архивные заказы обезличенного клиента затираются при восстановлении
+		if anonymized, err := repo.AnonymizeCustomer(ctx, "st-ar", "admin"); err != nil || !reflect.DeepEqual(anonymized, []string{"st-ar-2"}) {
+			t.Fatalf("AnonymizeCustomer: %v, %v", anonymized, err)
+		}
+		if restored, anonymized, err := repo.RestoreOrder(ctx, uploaded[0], id, "admin"); err != nil || !restored || anonymized {
+			t.Fatalf("RestoreOrder: restored %v, anonymized %v, %v", restored, anonymized, err)
+		}
+		if restored, _, err := repo.RestoreOrder(ctx, uploaded[0], id, "admin"); err != nil || restored {
+			t.Fatalf("repeated RestoreOrder: restored %v, %v", restored, err)
+		}
+		if err := repo.UpdateArchiveStatus(ctx, []int64{id}); err != nil {
+			t.Fatalf("UpdateArchiveStatus: %v", err)
+		}
+		if m, _ := repo.ArchiveManifest(ctx, id); m.Status != models.ArchiveStatusArchived {
+			t.Fatalf("manifest with orders still archived must keep status, got %s", m.Status)
+		}
+		if restored, anonymized, err := repo.RestoreOrder(ctx, uploaded[1], id, "admin"); err != nil || !restored || !anonymized {
+			t.Fatalf("RestoreOrder of anonymized customer: restored %v, anonymized %v, %v", restored, anonymized, err)
+		}
+		if got, err := repo.GetOrder(ctx, "st-ar-2"); err != nil || got.Delivery != (models.Delivery{}) {
+			t.Fatalf("restored order of anonymized customer: %+v, %v", got.Delivery, err)
+		}
+		if err := repo.UpdateArchiveStatus(ctx, []int64{id}); err != nil {
+			t.Fatalf("UpdateArchiveStatus: %v", err)
+		}
+		if m, _ := repo.ArchiveManifest(ctx, id); m.Status != models.ArchiveStatusRestored {
+			t.Fatalf("expected restored manifest, got %s", m.Status)
+		}
+
+		// восстановленные после cutoff заказы не архивируются снова с тем же cutoff
+		if archived, err := repo.ArchiveOrders(ctx, cutoff, 10, upload); err != nil || len(archived) != 0 {
+			t.Fatalf("restored orders archived again: %v, %v", archived, err)
+		}
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+		entries := []models.AuditEntry{
+			{Time: start, Principal: "apikey:alice", Action: "read", Method: "GET", Route: "/order/{order_uid}", Path: "/order/a", OrderUID: "a", Status: 200, SourceIP: "10.0.0.1"},
+			{Time: start.Add(time.Second), Principal: "jwt:bob", Action: "read", Method: "GET", Route: "/order/{order_uid}", Path: "/order/b", OrderUID: "b", Status: 404, SourceIP: "10.0.0.2"},
+			{Time: start.Add(2 * time.Second), Principal: "apikey:alice", Action: "search", Method: "GET", Route: "/orders/search", Path: "/orders/search", Status: 200, SourceIP: "10.0.0.1"},
+		}
+		if err := repo.InsertAuditEntries(ctx, entries); err != nil {
+			t.Fatalf("InsertAuditEntries: %v", err)
+		}
+
+		for name, tc := range map[string]struct {
+			filter models.AuditFilter
+			paths  []string
+			total  int
+		}{
+			"all":       {models.AuditFilter{Limit: 10}, []string{"/orders/search", "/order/b", "/order/a"}, 3},
+			"principal": {models.AuditFilter{Principal: "apikey:alice", Limit: 1}, []string{"/orders/search"}, 2},
+			"order":     {models.AuditFilter{OrderUID: "a", Limit: 10}, []string{"/order/a"}, 1},
+			"period":    {models.AuditFilter{From: start.Add(time.Second), To: start.Add(2 * time.Second), Limit: 10}, []string{"/order/b"}, 1},
+			"offset":    {models.AuditFilter{Limit: 10, Offset: 5}, nil, 3},
+		} {
+			got, total, err := repo.QueryAudit(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("%s: QueryAudit: %v", name, err)
+			}
+			var paths []string
+			for _, e := range got {
+				paths = append(paths, e.Path)
+			}
+			if total != tc.total || !reflect.DeepEqual(paths, tc.paths) || got == nil {
+				t.Fatalf("%s: got %v of %d, want %v of %d", name, paths, total, tc.paths, tc.total)
+			}
+		}
+
+		got, _, _ := repo.QueryAudit(ctx, models.AuditFilter{OrderUID: "b", Limit: 1})
+		want := entries[1]
+		want.ID = got[0].ID
+		got[0].Time = got[0].Time.UTC()
+		if want.ID == 0 || got[0] != want {
+			t.Fatalf("entry mismatch:\ngot  %+v\nwant %+v", got[0], want)
+		}
+	})
+
+	t.Run("ReturnedOrderIsCopy", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx := context.Background()
+		want := Order("st-copy", time.Now())
+		if err := repo.InsertOrder(ctx, want); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		// изменения у вызывающего не должны попадать в хранилище
+		want.Items[0].Name = "changed"
+		got, err := repo.GetOrder(ctx, "st-copy")
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		got.Items[0].Brand = "changed"
+		again, err := repo.GetOrder(ctx, "st-copy")
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		if again.Items[0].Name != "n" || again.Items[0].Brand != "b" {
+			t.Fatalf("stored order was modified: %+v", again.Items[0])
+		}
+	})
+}
+
+// Order тестовый заказ. Время обрезается до микросекунд — точности timestamp в Postgres
+func Order(uid string, created time.Time) *models.Order {
+	return &models.Order{
+		OrderUID:        uid,
+		TrackNumber:     "T-" + uid,
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "cust",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     created.UTC().Truncate(time.Microsecond),
+		OofShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "N",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{
+			{ChrtID: 1, TrackNumber: "T-" + uid, Price: 453, RID: "r1", Name: "n", Sale: 30, Size: "0", TotalPrice: 317, NmID: 1, Brand: "b", Status: 202},
+			{ChrtID: 2, TrackNumber: "T-" + uid, Price: 100, RID: "r2", Name: "m", Size: "0", TotalPrice: 100, NmID: 2, Brand: "c", Status: 202},
+		},
+	}
+}
+
+func assertOrder(t *testing.T, got, want models.Order) {
+	t.Helper()
+	if !got.DateCreated.Equal(want.DateCreated) {
+		t.Fatalf("date_created: got %v want %v", got.DateCreated, want.DateCreated)
+	}
+	got.DateCreated, want.DateCreated = time.Time{}, time.Time{}
+	got.Items, want.Items = sortedItems(got.Items), sortedItems(want.Items)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+// sortedItems порядок товаров хранилища не гарантируют
+func sortedItems(items []models.Item) []models.Item {
+	sorted := append([]models.Item(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ChrtID < sorted[j].ChrtID })
+	return sorted
+}
+
+func uids(orders []models.Order) []string {
+	var res []string
+	for _, o := range orders {
+		res = append(res, o.OrderUID)
+	}
+	return res
+}
@@ -2,14 +2,19 @@ package testutils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
 	embedded "github.com/fergusstrange/embedded-postgres"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -73,3 +78,23 @@ func StartEmbeddedPG(t *testing.T) (*embedded.EmbeddedPostgres, *pgxpool.Pool) {
 
 	return epg, pool
 }
+
+// ApplyMigrations накатывает миграции из db/migrations на тестовую базу
+func ApplyMigrations(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "db", "migrations")
+
+	cfg := pool.Config().ConnConfig
+	uri := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+
+	m, err := migrate.New("file://"+filepath.ToSlash(dir), uri)
+	if err != nil {
+		t.Fatalf("migrate init: %v", err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// kafkaHeaders передает контекст трассы через заголовки сообщения Kafka
+type kafkaHeaders struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaHeaders) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeaders) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeaders) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// InjectKafka добавляет контекст трассы из ctx в заголовки сообщения
+func InjectKafka(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, kafkaHeaders{headers: &msg.Headers})
+}
+
+// ExtractKafka возвращает ctx с контекстом трассы продюсера из заголовков сообщения
+func ExtractKafka(ctx context.Context, msg kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, kafkaHeaders{headers: &msg.Headers})
+}
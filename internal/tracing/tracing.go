@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"demoserv/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры спанов
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// ShutdownFunc дописывает накопленные спаны и останавливает экспорт
+type ShutdownFunc func(ctx context.Context) error
+
+// New настраивает глобальный TracerProvider и передачу контекста трассы
+// в формате W3C (traceparent). stdout экспортер пишет спаны в w.
+// Если трассировка выключена, спаны не записываются,
+// но контекст трассы из входящих запросов и сообщений передается дальше
+func New(ctx context.Context, cfg models.TracingConfig, w io.Writer) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio must be in [0, 1], got %v", cfg.SampleRatio)
+	}
+
+	exporter, err := newExporter(ctx, cfg, w)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// если у входящей трассы уже есть решение о записи, следуем ему
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg models.TracingConfig, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(cfg.Exporter) {
+	case "", ExporterOTLP:
+		var opts []otlptracehttp.Option
+		// ENDPOINT может быть host:port или полным URL
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("stdout exporter: %w", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (supported: otlp, stdout)", cfg.Exporter)
+	}
+}
+
+// Tracer трассировщик для пакета name из глобального TracerProvider
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End помечает спан ошибкой, если err не nil, и завершает его
+func End(span trace.Span, err error) {
+	if err != nil {
+		SetError(span, err)
+	}
+	span.End()
+}
+
+// SetError записывает ошибку в спан и ставит ему статус Error
+func SetError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
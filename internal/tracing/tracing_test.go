@@ -0,0 +1,74 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"demoserv/internal/models"
+	"demoserv/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew_StdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := tracing.New(context.Background(), models.TracingConfig{
+		Enabled:     true,
+		Exporter:    tracing.ExporterStdout,
+		ServiceName: "demoserv-test",
+		SampleRatio: 1,
+	}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "kafka.consume")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"Name":"kafka.consume"`) || !strings.Contains(buf.String(), "demoserv-test") {
+		t.Fatalf("span is not exported: %s", buf.String())
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cases := []models.TracingConfig{
+		{Enabled: true, Exporter: "zipkin", SampleRatio: 1},
+		{Enabled: true, Exporter: tracing.ExporterStdout, SampleRatio: 2},
+	}
+	for _, cfg := range cases {
+		if _, err := tracing.New(context.Background(), cfg, &bytes.Buffer{}); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestKafkaHeaders_Propagation(t *testing.T) {
+	// выключенная трассировка все равно передает контекст
+	if _, err := tracing.New(context.Background(), models.TracingConfig{}, nil); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "content-type", Value: []byte("application/json")}}}
+	tracing.InjectKafka(trace.ContextWithSpanContext(context.Background(), parent), &msg)
+
+	if len(msg.Headers) != 2 || msg.Headers[1].Key != "traceparent" {
+		t.Fatalf("traceparent header is not added: %v", msg.Headers)
+	}
+
+	got := trace.SpanContextFromContext(tracing.ExtractKafka(context.Background(), msg))
+	if got.TraceID() != parent.TraceID() || got.SpanID() != parent.SpanID() || !got.IsRemote() {
+		t.Fatalf("expected parent %v, got %v", parent, got)
+	}
+}